@@ -1,29 +1,253 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/graphql-go/handler"
 	"github.com/timpamungkas/loangraphql/graphqlhandler" // Import the local package
 )
 
+// logLevel maps the LOG_LEVEL environment variable (debug, info, warn, error) to a slog level.
+func logLevel() slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(os.Getenv("LOG_LEVEL")))); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// newAuthenticator configures JWT validation from the environment: JWT_HS256_SECRET
+// and/or JWT_JWKS_FILE, plus optional JWT_ISSUER and JWT_AUDIENCE. It returns nil when
+// AUTH_DISABLED=true.
+func newAuthenticator() (*graphqlhandler.Authenticator, error) {
+	if os.Getenv("AUTH_DISABLED") == "true" {
+		slog.Warn("authentication is disabled, all requests run as the local developer principal")
+		return nil, nil
+	}
+	return graphqlhandler.NewAuthenticator(graphqlhandler.AuthConfig{
+		HS256Secret: []byte(os.Getenv("JWT_HS256_SECRET")),
+		JWKSFile:    os.Getenv("JWT_JWKS_FILE"),
+		Issuer:      os.Getenv("JWT_ISSUER"),
+		Audience:    os.Getenv("JWT_AUDIENCE"),
+	})
+}
+
+// withAuthentication wraps next with JWT validation, or runs every request as a
+// local developer when authenticator is nil.
+func withAuthentication(authenticator *graphqlhandler.Authenticator, next http.Handler) http.Handler {
+	if authenticator == nil {
+		return graphqlhandler.StaticPrincipalMiddleware(&graphqlhandler.Principal{UserID: "local-developer", Roles: []string{graphqlhandler.RoleAdmin}}, next)
+	}
+	return authenticator.Middleware(next)
+}
+
+// listEnv splits a comma separated environment variable.
+func listEnv(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// eventRelays builds a relay for every domain event sink: in-process subscribers and,
+// from EVENTS_JSONL_FILE, EVENTS_NATS_URL and EVENTS_NATS_SUBJECT_PREFIX, a JSONL file
+// and NATS. Each relay acknowledges events on its own, so one sink being down does not
+// replay events into the others.
+func eventRelays(interval time.Duration) ([]*graphqlhandler.EventRelay, error) {
+	inProcess := graphqlhandler.NewInProcessPublisher()
+	inProcess.Subscribe(func(event graphqlhandler.DomainEvent) {
+		slog.Debug("domain event", "type", event.Type, "event_id", event.ID, "application_uuid", event.ApplicationUUID)
+	})
+	relays := []*graphqlhandler.EventRelay{graphqlhandler.NewEventRelay("in-process", inProcess, interval)}
+	if path := os.Getenv("EVENTS_JSONL_FILE"); path != "" {
+		file, err := graphqlhandler.NewJSONLFilePublisher(path)
+		if err != nil {
+			return nil, err
+		}
+		relays = append(relays, graphqlhandler.NewEventRelay("jsonl", file, interval))
+	}
+	if natsURL := os.Getenv("EVENTS_NATS_URL"); natsURL != "" {
+		nats, err := graphqlhandler.NewNATSPublisher(natsURL, os.Getenv("EVENTS_NATS_SUBJECT_PREFIX"))
+		if err != nil {
+			return nil, err
+		}
+		relays = append(relays, graphqlhandler.NewEventRelay("nats", nats, interval))
+	}
+	return relays, nil
+}
+
+// durationEnv reads a positive duration such as "1h" from the environment, falling back to
+// def. Zero and negative values are rejected because tickers panic on them.
+func durationEnv(name string, def time.Duration) time.Duration {
+	return parseDurationEnv(name, def, false)
+}
+
+// ruleDurationEnv reads a duration such as "720h" where 0 disables a rule, falling back to def.
+func ruleDurationEnv(name string, def time.Duration) time.Duration {
+	return parseDurationEnv(name, def, true)
+}
+
+func parseDurationEnv(name string, def time.Duration, allowZero bool) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 || (d == 0 && !allowZero) {
+		slog.Warn("ignoring invalid duration", "variable", name, "value", value)
+		return def
+	}
+	return d
+}
+
+// int64Env reads an integer such as a byte limit from the environment, falling back to def.
+func int64Env(name string, def int64) int64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		slog.Warn("ignoring invalid number", "variable", name, "value", value)
+		return def
+	}
+	return n
+}
+
 func main() {
+	// Structured JSON logs; every record logged with a request context carries its request_id.
+	logger := slog.New(graphqlhandler.NewContextLogHandler(
+		slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel()}),
+	))
+	slog.SetDefault(logger)
+
 	// Initialize the GraphQL schema.
 	// The schema is defined in graphqlhandler/schema.go and loaded in its init() function.
 	// We just need to make sure the package is imported so its init() runs.
 	// graphqlhandler.Schema will be available after this.
 
 	graphqlGQLHandler := handler.New(&handler.Config{
-		Schema:   &graphqlhandler.Schema,
-		Pretty:   true, // For pretty JSON output
-		GraphiQL: true, // Enable GraphiQL interface (optional)
+		Schema:           &graphqlhandler.Schema,
+		Pretty:           true, // For pretty JSON output
+		GraphiQL:         true, // Enable GraphiQL interface (optional)
+		ResultCallbackFn: graphqlhandler.LogResult,
 	})
 
-	// Register the GraphQL handler
-	http.Handle("/graphql", graphqlGQLHandler)
+	// ENCRYPTION_KEY_FILE holds the keys protecting customer PII at rest.
+	if path := os.Getenv("ENCRYPTION_KEY_FILE"); path != "" {
+		if err := graphqlhandler.UseKeyFile(path); err != nil {
+			logger.Error("failed to load encryption keys", "error", err)
+			os.Exit(1)
+		}
+	} else {
+		logger.Warn("ENCRYPTION_KEY_FILE is not set, customer data is encrypted with ephemeral keys")
+	}
+
+	// STORAGE_MODE=event-sourced keeps every change as an event instead of updating in place.
+	if err := graphqlhandler.SetStorageMode(os.Getenv("STORAGE_MODE")); err != nil {
+		logger.Error("invalid storage mode", "error", err)
+		os.Exit(1)
+	}
+
+	// TENANTS_FILE configures the partner lenders; without it a single "default" tenant is used.
+	if path := os.Getenv("TENANTS_FILE"); path != "" {
+		if err := graphqlhandler.LoadTenantsFile(path); err != nil {
+			logger.Error("failed to load tenants", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// REGIONS_FILE lists region datasets that replace the bundled samples, e.g. the complete Indonesian one.
+	for _, path := range listEnv("REGIONS_FILE") {
+		if err := graphqlhandler.LoadRegionsFile(path); err != nil {
+			logger.Error("failed to load regions", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// TRUST_FORWARDED_FOR takes the client IP recorded with terms acceptances from X-Forwarded-For.
+	graphqlhandler.SetTrustForwardedFor(os.Getenv("TRUST_FORWARDED_FOR") == "true")
+
+	// VEHICLE_CATALOG_FILE replaces the bundled vehicle catalog; admin edits are saved to it.
+	if path := os.Getenv("VEHICLE_CATALOG_FILE"); path != "" {
+		if err := graphqlhandler.LoadVehicleCatalogFile(path); err != nil {
+			logger.Error("failed to load vehicle catalog", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	graphqlhandler.SetIdempotencyKeyTTL(durationEnv("IDEMPOTENCY_KEY_TTL", 24*time.Hour))
+
+	authenticator, err := newAuthenticator()
+	if err != nil {
+		logger.Error("failed to configure authentication", "error", err)
+		os.Exit(1)
+	}
+
+	// Subscriptions are served on the same path over WebSocket (graphql-transport-ws).
+	// WS_ALLOWED_ORIGINS lists browser origins allowed to connect besides the server's own.
+	subscriptions := graphqlhandler.NewSubscriptionHandler(&graphqlhandler.Schema, authenticator, listEnv("WS_ALLOWED_ORIGINS"))
+
+	// Uploaded documents are kept below DOCUMENT_STORE_DIR; multipart requests carrying
+	// files larger than DOCUMENT_MAX_BYTES are rejected.
+	if dir := os.Getenv("DOCUMENT_STORE_DIR"); dir != "" {
+		graphqlhandler.SetBlobStore(graphqlhandler.NewFileBlobStore(dir))
+	} else {
+		logger.Warn("DOCUMENT_STORE_DIR is not set, uploaded documents are kept in the temporary directory")
+	}
+	maxUploadBytes := int64Env("DOCUMENT_MAX_BYTES", graphqlhandler.DefaultMaxUploadBytes)
+	subscriptions.MaxMessageBytes = graphqlhandler.MaxRequestBytes(maxUploadBytes)
+
+	// The tenant middleware needs the principal, so it runs inside authentication.
+	authenticated := withAuthentication(authenticator, graphqlhandler.TenantMiddleware(
+		graphqlhandler.UploadMiddleware(maxUploadBytes, graphqlhandler.IdempotencyKeyMiddleware(
+			graphqlhandler.WithSubscriptions(subscriptions, graphqlGQLHandler),
+		)),
+	))
+
+	// Register the GraphQL handler. The request ID middleware runs first so auth failures are correlated too;
+	// the locale middleware selects the language of error messages.
+	http.Handle("/graphql", graphqlhandler.RequestIDMiddleware(graphqlhandler.LocaleMiddleware(authenticated)))
+
+	// Document content is downloaded over plain HTTP with the same authentication and tenant resolution.
+	http.Handle("/documents/", graphqlhandler.RequestIDMiddleware(withAuthentication(authenticator,
+		graphqlhandler.TenantMiddleware(graphqlhandler.DocumentDownloadHandler()),
+	)))
+
+	// Background retention: expire stale drafts and anonymize closed applications.
+	retention := graphqlhandler.NewRetentionWorker(graphqlhandler.RetentionPolicy{
+		DraftExpiry:          ruleDurationEnv("RETENTION_DRAFT_EXPIRY", graphqlhandler.DefaultRetentionPolicy.DraftExpiry),
+		AnonymizeClosedAfter: ruleDurationEnv("RETENTION_ANONYMIZE_CLOSED_AFTER", graphqlhandler.DefaultRetentionPolicy.AnonymizeClosedAfter),
+	}, durationEnv("RETENTION_INTERVAL", time.Hour))
+	go retention.Run(context.Background())
+
+	// Webhook deliveries are drained from the transactional outbox of every tenant.
+	go graphqlhandler.NewWebhookDispatcher(durationEnv("WEBHOOK_POLL_INTERVAL", time.Second)).Run(context.Background())
+
+	// Domain events go to in-process subscribers and, if configured, to a JSONL file and NATS.
+	relays, err := eventRelays(durationEnv("EVENTS_POLL_INTERVAL", time.Second))
+	if err != nil {
+		logger.Error("failed to configure event publishing", "error", err)
+		os.Exit(1)
+	}
+	for _, relay := range relays {
+		go relay.Run(context.Background())
+	}
+	// Metrics (expvar) are served at /debug/vars on the default mux.
 
 	port := "8080"
-	log.Printf("GraphQL server starting on http://localhost:%s/graphql", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	logger.Info("GraphQL server starting", "url", "http://localhost:"+port+"/graphql")
+	if err := http.ListenAndServe(":"+port, nil); err != nil {
+		logger.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
 }
@@ -10,3 +10,9 @@ require (
 )
 
 require github.com/graphql-go/handler v0.2.3
+
+require github.com/golang-jwt/jwt/v5 v5.2.2
+
+require github.com/gorilla/websocket v1.5.3
+
+require golang.org/x/text v0.27.0
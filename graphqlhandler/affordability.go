@@ -0,0 +1,201 @@
+package graphqlhandler
+
+import (
+	"math"
+
+	"github.com/graphql-go/graphql"
+)
+
+// Employment types of a customer.
+const (
+	EmploymentSalaried      = "SALARIED"
+	EmploymentCivilServant  = "CIVIL_SERVANT"
+	EmploymentSelfEmployed  = "SELF_EMPLOYED"
+	EmploymentBusinessOwner = "BUSINESS_OWNER"
+	EmploymentRetired       = "RETIRED"
+	EmploymentUnemployed    = "UNEMPLOYED"
+)
+
+var employmentTypes = []string{
+	EmploymentSalaried, EmploymentCivilServant, EmploymentSelfEmployed,
+	EmploymentBusinessOwner, EmploymentRetired, EmploymentUnemployed,
+}
+
+// What happens to applications whose debt-to-income ratio exceeds MaxDebtToIncome.
+const (
+	DebtToIncomeBlock = "BLOCK" // the draft is rejected and the application is not eligible
+	DebtToIncomeFlag  = "FLAG"  // the application is flagged for manual review and scores lower
+)
+
+const maxDependents = 20
+
+// financialFields are the CustomerInput fields describing income and obligations.
+var financialFields = []string{"employment_type", "employer", "monthly_income", "monthly_obligations", "dependents"}
+
+// validateFinancialInput validates the optional financial data of a customer. Once any
+// of it is sent, employment_type and monthly_income are required.
+func validateFinancialInput(input map[string]interface{}) error {
+	provided := false
+	for _, field := range financialFields {
+		if input[field] != nil {
+			provided = true
+		}
+	}
+	if !provided {
+		return nil
+	}
+	employmentType, _ := input["employment_type"].(string)
+	employer, _ := input["employer"].(string)
+	income, hasIncome := input["monthly_income"].(float64)
+	obligations, _ := input["monthly_obligations"].(float64)
+	dependents, _ := input["dependents"].(int)
+
+	if employmentType == "" {
+		return invalidInput("employment_type", MsgRequiredWithFinancials, "employment_type")
+	}
+	if !hasIncome {
+		return invalidInput("monthly_income", MsgRequiredWithFinancials, "monthly_income")
+	}
+	if income < 0 {
+		return invalidInput("monthly_income", MsgNotNegative, "monthly_income")
+	}
+	switch employmentType {
+	case EmploymentSalaried, EmploymentCivilServant:
+		if employer == "" {
+			return invalidInput("employer", MsgRequiredForEmployment, "employer", employmentType)
+		}
+		if err := validateText("employer", employer, 1, 100); err != nil {
+			return err
+		}
+	case EmploymentUnemployed:
+		if employer != "" {
+			return invalidInput("employer", MsgNotForEmployment, "employer", employmentType)
+		}
+	default:
+		if err := validateText("employer", employer, 0, 100); err != nil {
+			return err
+		}
+	}
+	if employmentType != EmploymentUnemployed && income == 0 {
+		return invalidInput("monthly_income", MsgPositiveForEmployment, "monthly_income", employmentType)
+	}
+	if obligations < 0 {
+		return invalidInput("monthly_obligations", MsgNotNegative, "monthly_obligations")
+	}
+	if dependents < 0 || dependents > maxDependents {
+		return invalidInput("dependents", MsgOutOfRange, "dependents", 0, maxDependents)
+	}
+	return nil
+}
+
+// hasFinancials reports whether the customer provided financial data.
+func (c CustomerData) hasFinancials() bool {
+	return c.EmploymentType != ""
+}
+
+// monthlyInstallment returns the annuity installment of the loan at its annual
+// interest rate.
+func (l ProposedLoanData) monthlyInstallment() float64 {
+	if l.Tenure <= 0 {
+		return 0
+	}
+	rate := l.InterestRate / 100 / 12
+	if rate == 0 {
+		return l.Amount / float64(l.Tenure)
+	}
+	return l.Amount * rate / (1 - math.Pow(1+rate, -float64(l.Tenure)))
+}
+
+// Affordability combines the income and obligations of the borrowers, the primary
+// party and co-applicants, with the installment of the proposed loan. Guarantors only
+// pay when the borrowers default, so their finances do not count.
+type Affordability struct {
+	MonthlyInstallment float64
+	MonthlyIncome      float64
+	MonthlyObligations float64 // existing obligations, without the proposed loan
+	Known              bool    // false when no borrower provided financial data
+	DebtToIncome       float64 // percent, valid when Known and MonthlyIncome > 0
+	MaxDebtToIncome    float64
+	Action             string
+}
+
+// assessAffordability computes the debt-to-income ratio of an application.
+func assessAffordability(app *LoanApplicationData, rules BusinessRules) Affordability {
+	result := Affordability{
+		MonthlyInstallment: app.ProposedLoan.monthlyInstallment(),
+		MaxDebtToIncome:    rules.MaxDebtToIncome,
+		Action:             rules.DebtToIncomeAction,
+	}
+	for _, party := range app.allParties() {
+		if !isBorrower(party.Role) || !party.Customer.hasFinancials() {
+			continue
+		}
+		result.Known = true
+		result.MonthlyIncome += party.Customer.MonthlyIncome
+		result.MonthlyObligations += party.Customer.MonthlyObligations
+	}
+	if result.MonthlyIncome > 0 {
+		result.DebtToIncome = (result.MonthlyObligations + result.MonthlyInstallment) / result.MonthlyIncome * 100
+	}
+	return result
+}
+
+// computable reports whether the ratio could be computed.
+func (a Affordability) computable() bool {
+	return a.Known && a.MonthlyIncome > 0
+}
+
+// exceeded reports whether the debt-to-income ratio is above the maximum. Borrowers
+// without any income always exceed it.
+func (a Affordability) exceeded() bool {
+	if !a.Known {
+		return false
+	}
+	return !a.computable() || a.DebtToIncome > a.MaxDebtToIncome
+}
+
+// flagged reports whether the application needs a manual affordability review.
+func (a Affordability) flagged() bool {
+	return a.Action == DebtToIncomeFlag && a.exceeded()
+}
+
+// validateAffordability rejects drafts above the maximum debt-to-income ratio when the
+// tenant blocks them.
+func validateAffordability(app *LoanApplicationData, rules BusinessRules) error {
+	affordability := assessAffordability(app, rules)
+	if affordability.Action != DebtToIncomeBlock || !affordability.exceeded() {
+		return nil
+	}
+	if !affordability.computable() {
+		return invalidField("proposed_loan", invalidInput("", MsgNoBorrowerIncome))
+	}
+	return invalidField("proposed_loan", invalidInput("", MsgDebtToIncomeExceeded,
+		affordability.MonthlyInstallment, affordability.DebtToIncome, affordability.MaxDebtToIncome))
+}
+
+var affordabilityResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	app, ok := p.Source.(*LoanApplicationData)
+	if !ok {
+		return nil, nil
+	}
+	return assessAffordability(app, businessRulesOf(p)), nil
+}
+
+var monthlyInstallmentResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	loan, ok := p.Source.(ProposedLoanData)
+	if !ok {
+		return nil, nil
+	}
+	return loan.monthlyInstallment(), nil
+}
+
+// affordabilityField resolves a field of an Affordability.
+func affordabilityField(get func(a Affordability) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		affordability, ok := p.Source.(Affordability)
+		if !ok {
+			return nil, nil
+		}
+		return get(affordability), nil
+	}
+}
@@ -0,0 +1,74 @@
+package graphqlhandler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Audit actions recorded for loan application lifecycle changes.
+const (
+	AuditActionCreated   = "APPLICATION_CREATED"
+	AuditActionSubmitted = "APPLICATION_SUBMITTED"
+	AuditActionCancelled = "APPLICATION_CANCELLED"
+	AuditActionApproved  = "APPLICATION_APPROVED"
+	AuditActionRejected  = "APPLICATION_REJECTED"
+	AuditActionExpired   = "APPLICATION_EXPIRED"
+
+	AuditActionDataExported = "CUSTOMER_DATA_EXPORTED"
+	AuditActionDataErased   = "CUSTOMER_DATA_ERASED"
+	AuditActionAnonymized   = "CUSTOMER_DATA_ANONYMIZED" // by the retention worker
+
+	AuditActionDocumentUploaded   = "DOCUMENT_UPLOADED"
+	AuditActionDocumentReviewed   = "DOCUMENT_REVIEWED"
+	AuditActionDocumentDownloaded = "DOCUMENT_DOWNLOADED"
+)
+
+// AuditEvent is an append-only record of who did what to which application.
+type AuditEvent struct {
+	At              time.Time `json:"at"`
+	TenantID        string    `json:"tenant_id"`
+	ActorID         string    `json:"actor_id"`
+	Action          string    `json:"action"`
+	ApplicationUUID string    `json:"application_uuid"`
+	RequestID       string    `json:"request_id,omitempty"`
+}
+
+// In-memory audit trail, like loanApplications in data.go.
+var (
+	auditEvents      []AuditEvent
+	auditEventsMutex = &sync.RWMutex{}
+)
+
+// recordAudit appends an audit event attributed to the principal in ctx.
+func recordAudit(ctx context.Context, action, appUUID string) {
+	event := AuditEvent{
+		At:              time.Now(),
+		TenantID:        tenantID(ctx),
+		ActorID:         actorID(ctx),
+		Action:          action,
+		ApplicationUUID: appUUID,
+		RequestID:       RequestIDFromContext(ctx),
+	}
+
+	auditEventsMutex.Lock()
+	auditEvents = append(auditEvents, event)
+	auditEventsMutex.Unlock()
+
+	slog.InfoContext(ctx, "audit", "action", action, "application_uuid", appUUID)
+}
+
+// auditEventsFor returns the audit history of a single application in order.
+func auditEventsFor(appUUID string) []AuditEvent {
+	auditEventsMutex.RLock()
+	defer auditEventsMutex.RUnlock()
+
+	events := []AuditEvent{}
+	for _, event := range auditEvents {
+		if event.ApplicationUUID == appUUID {
+			events = append(events, event)
+		}
+	}
+	return events
+}
@@ -0,0 +1,246 @@
+package graphqlhandler
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID   string   `json:"user_id"`
+	Roles    []string `json:"roles"`
+	Branch   string   `json:"branch,omitempty"`
+	TenantID string   `json:"tenant_id,omitempty"` // Partner lender the caller belongs to, if bound to one
+}
+
+// HasRole reports whether the principal was granted the given role.
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the given principal.
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated principal of the request, if any.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// actorID identifies the caller in logs and audit events.
+func actorID(ctx context.Context) string {
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		return principal.UserID
+	}
+	return "anonymous"
+}
+
+// requirePrincipal is used by resolvers that must not run for anonymous callers.
+func requirePrincipal(ctx context.Context) (*Principal, error) {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return nil, newLocalizedAPIError(ErrCodeUnauthenticated, MsgAuthenticationRequired)
+	}
+	return principal, nil
+}
+
+// AuthConfig configures JWT validation. At least one of HS256Secret or JWKSFile must be set.
+type AuthConfig struct {
+	HS256Secret []byte // shared secret for HS256 tokens
+	JWKSFile    string // path to a local JWKS file holding RSA public keys for RS256 tokens
+	Issuer      string // optional expected "iss" claim
+	Audience    string // optional expected "aud" claim
+}
+
+// tokenClaims are the claims we read from an access token. "sub" is the user ID.
+type tokenClaims struct {
+	Roles  []string `json:"roles"`
+	Branch string   `json:"branch"`
+	Tenant string   `json:"tenant"`
+	jwt.RegisteredClaims
+}
+
+// Authenticator validates bearer tokens and turns them into a Principal.
+type Authenticator struct {
+	hsSecret []byte
+	rsaKeys  map[string]*rsa.PublicKey // by "kid"
+	parser   *jwt.Parser
+}
+
+// NewAuthenticator builds an Authenticator, loading the JWKS file if configured.
+func NewAuthenticator(cfg AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{hsSecret: cfg.HS256Secret}
+	var methods []string
+	if len(cfg.HS256Secret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKSFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		a.rsaKeys = keys
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("auth: an HS256 secret or a JWKS file is required")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	a.parser = jwt.NewParser(opts...)
+	return a, nil
+}
+
+func (a *Authenticator) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		return a.hsSecret, nil
+	case jwt.SigningMethodRS256.Alg():
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := a.rsaKeys[kid]; ok {
+			return key, nil
+		}
+		// A JWKS with a single key may be used by tokens without a kid.
+		if kid == "" && len(a.rsaKeys) == 1 {
+			for _, key := range a.rsaKeys {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+}
+
+// Authenticate validates a raw token string and returns its principal.
+func (a *Authenticator) Authenticate(raw string) (*Principal, error) {
+	claims := &tokenClaims{}
+	if _, err := a.parser.ParseWithClaims(raw, claims, a.keyFunc); err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	return &Principal{
+		UserID:   claims.Subject,
+		Roles:    claims.Roles,
+		Branch:   claims.Branch,
+		TenantID: claims.Tenant,
+	}, nil
+}
+
+// Middleware authenticates "Authorization: Bearer <jwt>" headers. Requests without the
+// header continue anonymously (resolvers decide what anonymous callers may do);
+// requests with an invalid token are rejected with 401.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		raw, found := strings.CutPrefix(header, "Bearer ")
+		if !found {
+			writeUnauthenticated(w, r, MsgBearerScheme)
+			return
+		}
+		principal, err := a.Authenticate(strings.TrimSpace(raw))
+		if err != nil {
+			slog.WarnContext(r.Context(), "rejected access token", "error", err)
+			writeUnauthenticated(w, r, MsgInvalidAccessToken)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// StaticPrincipalMiddleware attaches a fixed principal to every request.
+// It is meant for local development with authentication disabled.
+func StaticPrincipalMiddleware(principal *Principal, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// writeUnauthenticated rejects a request whose credentials could not be verified.
+func writeUnauthenticated(w http.ResponseWriter, r *http.Request, messageCode string) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	writeHTTPError(w, r, http.StatusUnauthorized, newLocalizedAPIError(ErrCodeUnauthenticated, messageCode))
+}
+
+// jwk is the subset of RFC 7517 fields needed for RSA signature keys.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func loadJWKSFile(path string) (map[string]*rsa.PublicKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("auth: read JWKS file: %w", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("auth: parse JWKS file: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("auth: key %q has invalid modulus: %w", key.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, fmt.Errorf("auth: key %q has invalid exponent: %w", key.Kid, err)
+		}
+		keys[key.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("auth: JWKS file %s contains no RSA signing keys", path)
+	}
+	return keys, nil
+}
@@ -0,0 +1,292 @@
+package graphqlhandler
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Collateral categories.
+const (
+	CollateralCategoryCar                 = "CAR"
+	CollateralCategoryMotorcycle          = "MOTORCYCLE"
+	CollateralCategoryTruck               = "TRUCK"
+	CollateralCategoryHeavyEquipment      = "HEAVY_EQUIPMENT"
+	CollateralCategoryPropertyCertificate = "PROPERTY_CERTIFICATE" // land or building title
+	CollateralCategoryGold                = "GOLD"
+)
+
+var collateralCategories = []string{
+	CollateralCategoryCar, CollateralCategoryMotorcycle, CollateralCategoryTruck,
+	CollateralCategoryHeavyEquipment, CollateralCategoryPropertyCertificate, CollateralCategoryGold,
+}
+
+func isCollateralCategory(category string) bool {
+	for _, known := range collateralCategories {
+		if known == category {
+			return true
+		}
+	}
+	return false
+}
+
+// isVehicleCategory reports whether items of the category are registered road vehicles.
+func isVehicleCategory(category string) bool {
+	return category == CollateralCategoryCar || category == CollateralCategoryMotorcycle || category == CollateralCategoryTruck
+}
+
+// hasMakeAndModel reports whether items of the category have a brand, variant and
+// manufacturing year.
+func hasMakeAndModel(category string) bool {
+	return isVehicleCategory(category) || category == CollateralCategoryHeavyEquipment
+}
+
+// detailsField returns the CollateralInput field holding the details of the category.
+func detailsField(category string) string {
+	switch {
+	case isVehicleCategory(category):
+		return "vehicle"
+	case category == CollateralCategoryHeavyEquipment:
+		return "heavy_equipment"
+	case category == CollateralCategoryPropertyCertificate:
+		return "property_certificate"
+	case category == CollateralCategoryGold:
+		return "gold"
+	}
+	return ""
+}
+
+var detailsFields = []string{"vehicle", "heavy_equipment", "property_certificate", "gold"}
+
+// VehicleDetails identifies a car, motorcycle or truck.
+type VehicleDetails struct {
+	PlateNumber          string `json:"plate_number"`   // e.g. "B 1234 XYZ"
+	ChassisNumber        string `json:"chassis_number"` // VIN / nomor rangka
+	EngineNumber         string `json:"engine_number"`
+	GrossVehicleWeightKg int    `json:"gross_vehicle_weight_kg,omitempty"` // Trucks only
+}
+
+// HeavyEquipmentDetails identifies construction, mining or materials handling equipment.
+type HeavyEquipmentDetails struct {
+	EquipmentType  string `json:"equipment_type"`
+	SerialNumber   string `json:"serial_number"`
+	OperatingHours int    `json:"operating_hours"`
+}
+
+// PropertyCertificateDetails describes a land or building title.
+type PropertyCertificateDetails struct {
+	CertificateType   string  `json:"certificate_type"` // SHM, SHGB or SHMSRS
+	CertificateNumber string  `json:"certificate_number"`
+	LandArea          float64 `json:"land_area"`     // square metres
+	BuildingArea      float64 `json:"building_area"` // square metres, 0 for bare land
+}
+
+// GoldDetails describes pledged gold.
+type GoldDetails struct {
+	Form              string  `json:"form"` // BAR, COIN or JEWELRY
+	WeightGrams       float64 `json:"weight_grams"`
+	Karat             int     `json:"karat"`
+	CertificateNumber string  `json:"certificate_number,omitempty"` // Assay certificate of bars and coins
+}
+
+// Property certificate types.
+const (
+	PropertyCertificateSHM    = "SHM"    // Sertifikat Hak Milik, freehold
+	PropertyCertificateSHGB   = "SHGB"   // Sertifikat Hak Guna Bangunan, right to build
+	PropertyCertificateSHMSRS = "SHMSRS" // Sertifikat Hak Milik Satuan Rumah Susun, strata title
+)
+
+var (
+	plateNumberPattern   = regexp.MustCompile(`^[A-Z]{1,2} [0-9]{1,4}( [A-Z]{1,3})?$`)
+	chassisNumberPattern = regexp.MustCompile(`^[A-HJ-NPR-Z0-9]{17}$`)
+	engineNumberPattern  = regexp.MustCompile(`^[A-Z0-9]{5,20}$`)
+	serialNumberPattern  = regexp.MustCompile(`^[A-Z0-9-]{5,30}$`)
+	certificatePattern   = regexp.MustCompile(`^[A-Z0-9./-]{1,50}$`)
+)
+
+const (
+	minTruckWeightKg   = 3500
+	maxTruckWeightKg   = 60000
+	maxOperatingHours  = 100000
+	maxGoldWeightGrams = 10000
+)
+
+// normalizeIdentifier upper-cases an identifier and collapses its whitespace.
+func normalizeIdentifier(value string) string {
+	return strings.Join(strings.Fields(strings.ToUpper(value)), " ")
+}
+
+// validateCollateralDetails checks that only the details of the item's category are
+// sent and validates them. Details are optional for CAR and MOTORCYCLE, which were
+// accepted without them before, and required for every other category.
+func validateCollateralDetails(input map[string]interface{}) error {
+	category, _ := input["category"].(string)
+	field := detailsField(category)
+	for _, other := range detailsFields {
+		if input[other] != nil && other != field {
+			return invalidInput(other, MsgNotForCategory, other, category)
+		}
+	}
+	details, ok := input[field].(map[string]interface{})
+	if !ok {
+		if category == CollateralCategoryCar || category == CollateralCategoryMotorcycle {
+			return nil
+		}
+		return invalidInput(field, MsgRequiredForCategory, field, category)
+	}
+
+	var err error
+	switch field {
+	case "vehicle":
+		err = validateVehicleDetails(category, details)
+	case "heavy_equipment":
+		err = validateHeavyEquipmentDetails(details)
+	case "property_certificate":
+		err = validatePropertyCertificateDetails(details)
+	case "gold":
+		err = validateGoldDetails(details)
+	}
+	if err != nil {
+		return invalidField(field, err)
+	}
+	return nil
+}
+
+func validateVehicleDetails(category string, input map[string]interface{}) error {
+	plate, _ := input["plate_number"].(string)
+	chassis, _ := input["chassis_number"].(string)
+	engine, _ := input["engine_number"].(string)
+	weight, hasWeight := input["gross_vehicle_weight_kg"].(int)
+
+	if !plateNumberPattern.MatchString(normalizeIdentifier(plate)) {
+		return invalidInput("plate_number", MsgPlateNumberFormat)
+	}
+	if !chassisNumberPattern.MatchString(normalizeIdentifier(chassis)) {
+		return invalidInput("chassis_number", MsgChassisNumberFormat)
+	}
+	if !engineNumberPattern.MatchString(normalizeIdentifier(engine)) {
+		return invalidInput("engine_number", MsgEngineNumberFormat)
+	}
+	switch {
+	case category == CollateralCategoryTruck && (!hasWeight || weight < minTruckWeightKg || weight > maxTruckWeightKg):
+		return invalidInput("gross_vehicle_weight_kg", MsgTruckWeightOutOfRange, "gross_vehicle_weight_kg", minTruckWeightKg, maxTruckWeightKg)
+	case category != CollateralCategoryTruck && hasWeight:
+		return invalidInput("gross_vehicle_weight_kg", MsgTrucksOnly, "gross_vehicle_weight_kg")
+	}
+	return nil
+}
+
+func validateHeavyEquipmentDetails(input map[string]interface{}) error {
+	serial, _ := input["serial_number"].(string)
+	hours, _ := input["operating_hours"].(int)
+
+	if !serialNumberPattern.MatchString(normalizeIdentifier(serial)) {
+		return invalidInput("serial_number", MsgSerialNumberFormat)
+	}
+	if hours < 0 || hours > maxOperatingHours {
+		return invalidInput("operating_hours", MsgOutOfRange, "operating_hours", 0, maxOperatingHours)
+	}
+	// equipment_type is an enum, handled by the GraphQL type system.
+	return nil
+}
+
+func validatePropertyCertificateDetails(input map[string]interface{}) error {
+	certType, _ := input["certificate_type"].(string)
+	number, _ := input["certificate_number"].(string)
+	landArea, _ := input["land_area"].(float64)
+	buildingArea, _ := input["building_area"].(float64)
+
+	if !certificatePattern.MatchString(normalizeIdentifier(number)) {
+		return invalidInput("certificate_number", MsgCertificateNumberFormat)
+	}
+	if buildingArea < 0 {
+		return invalidInput("building_area", MsgNotNegative, "building_area")
+	}
+	// A strata title covers a unit, not a plot of land.
+	if certType == PropertyCertificateSHMSRS {
+		if buildingArea <= 0 {
+			return invalidInput("building_area", MsgRequiredForCertificate, "building_area", certType)
+		}
+		return nil
+	}
+	if landArea <= 0 {
+		return invalidInput("land_area", MsgRequiredForCertificate, "land_area", certType)
+	}
+	return nil
+}
+
+func validateGoldDetails(input map[string]interface{}) error {
+	form, _ := input["form"].(string)
+	weight, _ := input["weight_grams"].(float64)
+	karat, _ := input["karat"].(int)
+	certificate, _ := input["certificate_number"].(string)
+
+	if weight <= 0 || weight > maxGoldWeightGrams {
+		return invalidInput("weight_grams", MsgGoldWeightOutOfRange, "weight_grams", maxGoldWeightGrams)
+	}
+	if karat < 8 || karat > 24 {
+		return invalidInput("karat", MsgOutOfRange, "karat", 8, 24)
+	}
+	if certificate != "" && !certificatePattern.MatchString(normalizeIdentifier(certificate)) {
+		return invalidInput("certificate_number", MsgCertificateNumberFormat)
+	}
+	if form == "JEWELRY" && certificate != "" {
+		return invalidInput("certificate_number", MsgBarsAndCoinsOnly, "certificate_number")
+	}
+	return nil
+}
+
+// applyCollateralDetails maps the validated details input onto the item.
+func applyCollateralDetails(item *CollateralData, input map[string]interface{}) {
+	details, ok := input[detailsField(item.Category)].(map[string]interface{})
+	if !ok {
+		return
+	}
+	switch {
+	case isVehicleCategory(item.Category):
+		weight, _ := details["gross_vehicle_weight_kg"].(int)
+		item.Vehicle = &VehicleDetails{
+			PlateNumber:          normalizeIdentifier(details["plate_number"].(string)),
+			ChassisNumber:        normalizeIdentifier(details["chassis_number"].(string)),
+			EngineNumber:         normalizeIdentifier(details["engine_number"].(string)),
+			GrossVehicleWeightKg: weight,
+		}
+	case item.Category == CollateralCategoryHeavyEquipment:
+		item.HeavyEquipment = &HeavyEquipmentDetails{
+			EquipmentType:  details["equipment_type"].(string),
+			SerialNumber:   normalizeIdentifier(details["serial_number"].(string)),
+			OperatingHours: details["operating_hours"].(int),
+		}
+	case item.Category == CollateralCategoryPropertyCertificate:
+		landArea, _ := details["land_area"].(float64)
+		buildingArea, _ := details["building_area"].(float64)
+		item.PropertyCertificate = &PropertyCertificateDetails{
+			CertificateType:   details["certificate_type"].(string),
+			CertificateNumber: normalizeIdentifier(details["certificate_number"].(string)),
+			LandArea:          landArea,
+			BuildingArea:      buildingArea,
+		}
+	case item.Category == CollateralCategoryGold:
+		certificate, _ := details["certificate_number"].(string)
+		item.Gold = &GoldDetails{
+			Form:              details["form"].(string),
+			WeightGrams:       details["weight_grams"].(float64),
+			Karat:             details["karat"].(int),
+			CertificateNumber: normalizeIdentifier(certificate),
+		}
+	}
+}
+
+// details returns the category specific details of the item, or nil.
+func (item CollateralData) details() interface{} {
+	switch {
+	case item.Vehicle != nil:
+		return item.Vehicle
+	case item.HeavyEquipment != nil:
+		return item.HeavyEquipment
+	case item.PropertyCertificate != nil:
+		return item.PropertyCertificate
+	case item.Gold != nil:
+		return item.Gold
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package graphqlhandler
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+)
+
+// maxCollaterals limits the collateral items pledged for one application.
+const maxCollaterals = 10
+
+// primaryCollateral returns the first collateral item, which decides the rate card
+// category and is what the deprecated singular collateral field shows.
+func (app *LoanApplicationData) primaryCollateral() CollateralData {
+	if len(app.Collaterals) == 0 {
+		return CollateralData{}
+	}
+	return app.Collaterals[0]
+}
+
+// collateralValue returns the aggregate appraised value of the collateral items, and
+// false when an item has no valuation.
+func (app *LoanApplicationData) collateralValue() (float64, bool) {
+	total := 0.0
+	for _, item := range app.Collaterals {
+		if item.Value <= 0 {
+			return total, false
+		}
+		total += item.Value
+	}
+	return total, len(app.Collaterals) > 0
+}
+
+// loanToValue returns the proposed amount as a percentage of the aggregate collateral
+// value, and false when it cannot be computed because an item has no valuation.
+func (app *LoanApplicationData) loanToValue() (float64, bool) {
+	value, ok := app.collateralValue()
+	if !ok {
+		return 0, false
+	}
+	return app.ProposedLoan.Amount / value * 100, true
+}
+
+// documentsComplete reports whether every collateral item has its required documents.
+func (app *LoanApplicationData) documentsComplete() bool {
+	for _, item := range app.Collaterals {
+		if !item.IsDocumentComplete {
+			return false
+		}
+	}
+	return len(app.Collaterals) > 0
+}
+
+// findCollateral returns the collateral item with the given ID.
+func (app *LoanApplicationData) findCollateral(id string) (CollateralData, bool) {
+	for _, item := range app.Collaterals {
+		if item.ID == id {
+			return item, true
+		}
+	}
+	return CollateralData{}, false
+}
+
+func newCollateralData(input map[string]interface{}) CollateralData {
+	value, _ := input["value"].(float64) // Optional for the deprecated singular field
+	brand, _ := input["brand"].(string)  // Only for categories with a make and model
+	variant, _ := input["variant"].(string)
+	mfgYear, _ := input["manufacturing_year"].(int)
+	model, _ := input["model"].(string)
+	category := input["category"].(string)
+	if hasMakeAndModel(category) {
+		// Store the catalog's spelling; validateCollateralInput checked the match.
+		entry, _ := vehicles.match(category, brand, model, variant)
+		brand, model, variant = entry.Brand, entry.Model, entry.Variant
+	}
+	item := CollateralData{
+		ID:                uuid.New().String(),
+		Category:          category,
+		Brand:             brand,
+		Model:             model,
+		Variant:           variant,
+		ManufacturingYear: mfgYear,
+		Value:             value,
+	}
+	applyCollateralDetails(&item, input)
+	return item
+}
+
+// draftCollaterals validates the collateral items of a draft. Drafts either send the
+// deprecated collateral field, whose valuation is optional, or a collaterals list where
+// every item must be valued.
+func draftCollaterals(draft map[string]interface{}, rules BusinessRules) ([]CollateralData, error) {
+	collateralInput, hasCollateral := draft["collateral"].(map[string]interface{})
+	collateralsInput, hasCollaterals := draft["collaterals"].([]interface{})
+	if hasCollateral == hasCollaterals {
+		return nil, invalidInput("", MsgOneOf, "collaterals", "collateral")
+	}
+	if hasCollateral {
+		if err := validateCollateralInput(collateralInput, rules); err != nil {
+			return nil, invalidField("collateral", err)
+		}
+		if value, ok := collateralInput["value"].(float64); ok && value <= 0 {
+			return nil, invalidField("collateral", invalidInput("value", MsgNotPositive, "value"))
+		}
+		return []CollateralData{newCollateralData(collateralInput)}, nil
+	}
+
+	if len(collateralsInput) == 0 || len(collateralsInput) > maxCollaterals {
+		return nil, invalidInput("collaterals", MsgCollateralCount, maxCollaterals)
+	}
+	items := make([]CollateralData, 0, len(collateralsInput))
+	for i, raw := range collateralsInput {
+		input, _ := raw.(map[string]interface{})
+		if err := validateCollateralInput(input, rules); err != nil {
+			return nil, invalidField(fmt.Sprintf("collaterals[%d]", i), err)
+		}
+		if value, _ := input["value"].(float64); value <= 0 {
+			return nil, invalidField(fmt.Sprintf("collaterals[%d]", i), invalidInput("value", MsgNotPositive, "value"))
+		}
+		items = append(items, newCollateralData(input))
+	}
+	return items, nil
+}
+
+// validateLoanToValue rejects a proposed amount above the tenant's maximum share of the
+// aggregate collateral value. Applications with an unvalued item cannot be checked here;
+// their eligibility reports the missing valuation instead.
+func validateLoanToValue(app *LoanApplicationData, rules BusinessRules) error {
+	ltv, ok := app.loanToValue()
+	if !ok || ltv <= rules.MaxLoanToValue {
+		return nil
+	}
+	value, _ := app.collateralValue()
+	return invalidField("proposed_loan", invalidInput("amount", MsgLoanToValueExceeded, rules.MaxLoanToValue, value))
+}
+
+// collateralsResolver returns the collateral items of an application.
+var collateralsResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	app, ok := p.Source.(*LoanApplicationData)
+	if !ok {
+		return nil, nil
+	}
+	return app.Collaterals, nil
+}
+
+var primaryCollateralResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	app, ok := p.Source.(*LoanApplicationData)
+	if !ok {
+		return nil, nil
+	}
+	return app.primaryCollateral(), nil
+}
+
+var collateralValueResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	app, ok := p.Source.(*LoanApplicationData)
+	if !ok {
+		return nil, nil
+	}
+	value, _ := app.collateralValue()
+	return value, nil
+}
+
+var loanToValueResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	app, ok := p.Source.(*LoanApplicationData)
+	if !ok {
+		return nil, nil
+	}
+	ltv, ok := app.loanToValue()
+	if !ok {
+		return nil, nil
+	}
+	return ltv, nil
+}
@@ -0,0 +1,92 @@
+package graphqlhandler
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Genders, used to cross-check national ID numbers that encode them.
+const (
+	GenderMale   = "MALE"
+	GenderFemale = "FEMALE"
+)
+
+// CountryValidator validates the country specific parts of customer data. Validators
+// are registered per ISO 3166-1 alpha-2 country code with RegisterCountryValidator and
+// selected by the country of the customer (national ID and phone) or of the address
+// (postal code).
+type CountryValidator interface {
+	// ValidateNationalID checks an ID number against the customer's date of birth
+	// (YYYY-MM-DD, already validated) and gender, which may be empty.
+	ValidateNationalID(idNumber, dateOfBirth, gender string) error
+	// NormalizePhone validates a phone number and returns it in E.164 format.
+	NormalizePhone(phone string) (string, error)
+	ValidatePostalCode(code string) error
+}
+
+var (
+	countryValidatorsMu sync.RWMutex
+	countryValidators   = map[string]CountryValidator{}
+)
+
+// RegisterCountryValidator makes a validator available for a country code.
+func RegisterCountryValidator(country string, validator CountryValidator) {
+	countryValidatorsMu.Lock()
+	defer countryValidatorsMu.Unlock()
+	countryValidators[strings.ToUpper(country)] = validator
+}
+
+var countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// countryValidatorFor returns the validator of a country. Customers without a country,
+// and countries without a plugin, use the generic rules.
+func countryValidatorFor(country string) (CountryValidator, error) {
+	if country == "" {
+		return genericValidator{}, nil
+	}
+	if !countryCodePattern.MatchString(country) {
+		return nil, invalidInput("country", MsgInvalidCountry)
+	}
+	countryValidatorsMu.RLock()
+	defer countryValidatorsMu.RUnlock()
+	if validator, ok := countryValidators[country]; ok {
+		return validator, nil
+	}
+	return genericValidator{}, nil
+}
+
+// phoneSeparators are removed before a phone number is validated.
+var phoneSeparators = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "")
+
+var (
+	e164Pattern        = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+	legacyPhonePattern = regexp.MustCompile(`^[0-9]{6,30}$`)
+)
+
+// genericValidator keeps the rules that applied before country validation existed.
+// Phones in international format are normalized to E.164; national numbers cannot be
+// without knowing the country and are kept as digits.
+type genericValidator struct{}
+
+func (genericValidator) ValidateNationalID(idNumber, _, _ string) error {
+	return validateText("id_number", idNumber, 1, 25)
+}
+
+func (genericValidator) NormalizePhone(phone string) (string, error) {
+	phone = phoneSeparators.Replace(phone)
+	if strings.HasPrefix(phone, "+") {
+		if !e164Pattern.MatchString(phone) {
+			return "", invalidInput("phone", MsgPhoneInternational)
+		}
+		return phone, nil
+	}
+	if !legacyPhonePattern.MatchString(phone) {
+		return "", invalidInput("phone", MsgPhoneDigits)
+	}
+	return phone, nil
+}
+
+func (genericValidator) ValidatePostalCode(code string) error {
+	return validateText("zipcode", code, 3, 10)
+}
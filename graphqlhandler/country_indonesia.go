@@ -0,0 +1,121 @@
+package graphqlhandler
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterCountryValidator("ID", indonesiaValidator{})
+}
+
+// indonesiaValidator validates NIK (Nomor Induk Kependudukan) numbers, mobile phones
+// and postal codes of Indonesia.
+type indonesiaValidator struct{}
+
+var (
+	nikPattern          = regexp.MustCompile(`^[0-9]{16}$`)
+	idPostalPattern     = regexp.MustCompile(`^[1-9][0-9]{4}$`)
+	idSubscriberPattern = regexp.MustCompile(`^8[0-9]{8,11}$`) // after the 62 country code
+)
+
+// nikProvinces are the province codes that start a NIK.
+var nikProvinces = map[string]bool{
+	// Sumatra and the Riau Islands
+	"11": true, "12": true, "13": true, "14": true, "15": true, "16": true, "17": true, "18": true, "19": true, "21": true,
+	// Java
+	"31": true, "32": true, "33": true, "34": true, "35": true, "36": true,
+	// Bali and Nusa Tenggara
+	"51": true, "52": true, "53": true,
+	// Kalimantan
+	"61": true, "62": true, "63": true, "64": true, "65": true,
+	// Sulawesi
+	"71": true, "72": true, "73": true, "74": true, "75": true, "76": true,
+	// Maluku and Papua
+	"81": true, "82": true, "91": true, "92": true, "93": true, "94": true, "95": true, "96": true,
+}
+
+// idMobilePrefixes are the operator prefixes of Indonesian mobile numbers, without the
+// leading 0 of the national format.
+var idMobilePrefixes = map[string]bool{
+	// Telkomsel
+	"811": true, "812": true, "813": true, "821": true, "822": true, "823": true, "851": true, "852": true, "853": true,
+	// Indosat
+	"814": true, "815": true, "816": true, "855": true, "856": true, "857": true, "858": true,
+	// XL and Axis
+	"817": true, "818": true, "819": true, "859": true, "877": true, "878": true, "831": true, "832": true, "833": true, "838": true,
+	// Smartfren
+	"881": true, "882": true, "883": true, "884": true, "885": true, "886": true, "887": true, "888": true, "889": true,
+	// Tri
+	"895": true, "896": true, "897": true, "898": true, "899": true,
+}
+
+// ValidateNationalID checks the structure of a 16 digit NIK: a province, regency and
+// district code (6 digits), the date of birth as DDMMYY with 40 added to the day for
+// women, and a serial number that is not 0000.
+func (indonesiaValidator) ValidateNationalID(nik, dateOfBirth, gender string) error {
+	if !nikPattern.MatchString(nik) {
+		return invalidInput("id_number", MsgNIKFormat)
+	}
+	if !nikProvinces[nik[:2]] {
+		return invalidInput("id_number", MsgNIKProvince)
+	}
+	day, _ := strconv.Atoi(nik[6:8])
+	month, _ := strconv.Atoi(nik[8:10])
+	year, _ := strconv.Atoi(nik[10:12])
+	female := day > 40
+	if female {
+		day -= 40
+	}
+	if day < 1 || day > 31 || month < 1 || month > 12 {
+		return invalidInput("id_number", MsgNIKBirthDate)
+	}
+	if nik[12:] == "0000" {
+		return invalidInput("id_number", MsgNIKSerial)
+	}
+	dob, err := time.Parse("2006-01-02", dateOfBirth)
+	if err != nil {
+		return nil // reported by the date_of_birth check
+	}
+	if dob.Day() != day || int(dob.Month()) != month || dob.Year()%100 != year {
+		return invalidInput("id_number", MsgNIKDateOfBirthMismatch)
+	}
+	switch {
+	case gender == GenderFemale && !female:
+		return invalidInput("id_number", MsgNIKMaleMismatch, gender)
+	case gender == GenderMale && female:
+		return invalidInput("id_number", MsgNIKFemaleMismatch, gender)
+	}
+	return nil
+}
+
+// NormalizePhone accepts mobile numbers in national (08...), international (+628...)
+// or bare (628...) format and returns them as +628...
+func (indonesiaValidator) NormalizePhone(phone string) (string, error) {
+	phone = phoneSeparators.Replace(phone)
+	var subscriber string
+	switch {
+	case strings.HasPrefix(phone, "+62"):
+		subscriber = phone[3:]
+	case strings.HasPrefix(phone, "62"):
+		subscriber = phone[2:]
+	case strings.HasPrefix(phone, "0"):
+		subscriber = phone[1:]
+	default:
+		return "", invalidInput("phone", MsgIndonesianPhone)
+	}
+	if !idSubscriberPattern.MatchString(subscriber) || !idMobilePrefixes[subscriber[:3]] {
+		return "", invalidInput("phone", MsgIndonesianPhonePrefix)
+	}
+	return "+62" + subscriber, nil
+}
+
+// ValidatePostalCode accepts the 5 digit kode pos.
+func (indonesiaValidator) ValidatePostalCode(code string) error {
+	if !idPostalPattern.MatchString(code) {
+		return invalidInput("zipcode", MsgIndonesianPostalCode)
+	}
+	return nil
+}
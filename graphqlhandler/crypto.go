@@ -0,0 +1,481 @@
+package graphqlhandler
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyProvider supplies the key-encryption keys (KEKs) used for envelope encryption of
+// customer PII, and the key of the id_number blind index.
+type KeyProvider interface {
+	// CurrentKeyID is the KEK new data keys are wrapped with.
+	CurrentKeyID() string
+	// WrapKey encrypts a data key with the KEK identified by keyID.
+	WrapKey(keyID string, dataKey []byte) ([]byte, error)
+	// UnwrapKey decrypts a data key previously wrapped with keyID.
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+	// BlindIndexKey is the HMAC key for searchable indexes. It must never rotate,
+	// otherwise existing indexes stop matching.
+	BlindIndexKey() []byte
+}
+
+// localKeyProvider keeps KEKs in memory, loaded from a local key file. It is meant for
+// development; production deployments plug in a KMS backed KeyProvider.
+type localKeyProvider struct {
+	currentKeyID  string
+	keys          map[string][]byte
+	blindIndexKey []byte
+}
+
+func (p *localKeyProvider) CurrentKeyID() string {
+	return p.currentKeyID
+}
+
+func (p *localKeyProvider) WrapKey(keyID string, dataKey []byte) ([]byte, error) {
+	kek, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("encryption: unknown key %q", keyID)
+	}
+	return sealAESGCM(kek, dataKey, []byte(keyID))
+}
+
+func (p *localKeyProvider) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	kek, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("encryption: unknown key %q", keyID)
+	}
+	return openAESGCM(kek, wrapped, []byte(keyID))
+}
+
+func (p *localKeyProvider) BlindIndexKey() []byte {
+	return p.blindIndexKey
+}
+
+// LoadKeyFile reads a local key file:
+//
+//	{"current_key_id": "2026-01", "keys": {"2026-01": "<base64, 32 bytes>"}, "blind_index_key": "<base64, 32 bytes>"}
+//
+// Rotate by adding a new key, pointing current_key_id at it and calling the
+// reencryptCustomerData mutation, which reloads the file before re-wrapping.
+// Old keys must stay in the file until then.
+func LoadKeyFile(path string) (KeyProvider, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("encryption: read key file: %w", err)
+	}
+	var file struct {
+		CurrentKeyID  string            `json:"current_key_id"`
+		Keys          map[string]string `json:"keys"`
+		BlindIndexKey string            `json:"blind_index_key"`
+	}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("encryption: parse key file: %w", err)
+	}
+
+	provider := &localKeyProvider{currentKeyID: file.CurrentKeyID, keys: make(map[string][]byte, len(file.Keys))}
+	for id, encoded := range file.Keys {
+		key, err := decode256BitKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption: key %q: %w", id, err)
+		}
+		provider.keys[id] = key
+	}
+	if _, ok := provider.keys[provider.currentKeyID]; !ok {
+		return nil, fmt.Errorf("encryption: current key %q is not defined", file.CurrentKeyID)
+	}
+	if provider.blindIndexKey, err = decode256BitKey(file.BlindIndexKey); err != nil {
+		return nil, fmt.Errorf("encryption: blind_index_key: %w", err)
+	}
+	return provider, nil
+}
+
+func decode256BitKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// newEphemeralKeyProvider generates throwaway keys, used when no key file is configured.
+// Data encrypted with it cannot be read after a restart.
+func newEphemeralKeyProvider() KeyProvider {
+	kek := make([]byte, 32)
+	indexKey := make([]byte, 32)
+	if _, err := rand.Read(kek); err != nil {
+		panic(fmt.Sprintf("encryption: generate key: %v", err))
+	}
+	if _, err := rand.Read(indexKey); err != nil {
+		panic(fmt.Sprintf("encryption: generate key: %v", err))
+	}
+	return &localKeyProvider{currentKeyID: "ephemeral", keys: map[string][]byte{"ephemeral": kek}, blindIndexKey: indexKey}
+}
+
+var (
+	keyProvider      = newEphemeralKeyProvider()
+	keyFilePath      string // set when keyProvider was loaded by UseKeyFile
+	keyProviderMutex = &sync.RWMutex{}
+)
+
+// SetKeyProvider installs the key provider used for customer PII. Call it before serving requests.
+func SetKeyProvider(provider KeyProvider) {
+	keyProviderMutex.Lock()
+	defer keyProviderMutex.Unlock()
+	keyProvider = provider
+	keyFilePath = ""
+}
+
+// UseKeyFile installs a local key file provider and remembers the path so that
+// key rotation can reload it.
+func UseKeyFile(path string) error {
+	provider, err := LoadKeyFile(path)
+	if err != nil {
+		return err
+	}
+	keyProviderMutex.Lock()
+	defer keyProviderMutex.Unlock()
+	keyProvider = provider
+	keyFilePath = path
+	return nil
+}
+
+// reloadKeyFile re-reads the key file installed by UseKeyFile, if any.
+func reloadKeyFile() error {
+	keyProviderMutex.RLock()
+	path := keyFilePath
+	keyProviderMutex.RUnlock()
+	if path == "" {
+		return nil
+	}
+	return UseKeyFile(path)
+}
+
+func currentKeyProvider() KeyProvider {
+	keyProviderMutex.RLock()
+	defer keyProviderMutex.RUnlock()
+	return keyProvider
+}
+
+// blindIndex returns the HMAC-SHA256 of a normalized id_number. Equal ID numbers give
+// equal indexes, so applications can be looked up without decrypting every record.
+func blindIndex(idNumber string) string {
+	mac := hmac.New(sha256.New, currentKeyProvider().BlindIndexKey())
+	mac.Write([]byte(strings.ToUpper(strings.TrimSpace(idNumber))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func sealAESGCM(key, plaintext, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func openAESGCM(key, sealed, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("encryption: ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// --- Encrypted store ---
+
+// EncryptionEnvelope records which KEK wrapped the data key of a customer record.
+type EncryptionEnvelope struct {
+	KeyID      string `json:"key_id"`
+	WrappedKey []byte `json:"wrapped_key"`
+}
+
+const encryptedPrefix = "enc:"
+
+// encryptedFields lists the CustomerData fields encrypted at rest.
+func encryptedFields(c *CustomerData) map[string]*string {
+	return map[string]*string{
+		"id_number":     &c.IDNumber,
+		"phone":         &c.Phone,
+		"email":         &c.Email,
+		"date_of_birth": &c.DateOfBirth,
+		"employer":      &c.Employer,
+		"gender":        &c.Gender,
+	}
+}
+
+// encryptCustomer replaces the sensitive fields with ciphertext under a fresh data key.
+// Each field is bound to its application and field name through the GCM additional data.
+func encryptCustomer(appUUID string, c *CustomerData) error {
+	if c.Encryption != nil {
+		return nil // already encrypted
+	}
+	provider := currentKeyProvider()
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return err
+	}
+	for name, field := range encryptedFields(c) {
+		if *field == "" {
+			continue
+		}
+		sealed, err := sealAESGCM(dataKey, []byte(*field), []byte(appUUID+"/"+name))
+		if err != nil {
+			return err
+		}
+		*field = encryptedPrefix + base64.StdEncoding.EncodeToString(sealed)
+	}
+	keyID := provider.CurrentKeyID()
+	wrapped, err := provider.WrapKey(keyID, dataKey)
+	if err != nil {
+		return err
+	}
+	c.Encryption = &EncryptionEnvelope{KeyID: keyID, WrappedKey: wrapped}
+	return nil
+}
+
+func decryptCustomer(appUUID string, c *CustomerData) error {
+	if c.Encryption == nil {
+		return nil // stored before encryption was enabled, or erased
+	}
+	dataKey, err := currentKeyProvider().UnwrapKey(c.Encryption.KeyID, c.Encryption.WrappedKey)
+	if err != nil {
+		return err
+	}
+	for name, field := range encryptedFields(c) {
+		encoded, ok := strings.CutPrefix(*field, encryptedPrefix)
+		if !ok {
+			continue
+		}
+		sealed, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("encryption: field %s: %w", name, err)
+		}
+		plaintext, err := openAESGCM(dataKey, sealed, []byte(appUUID+"/"+name))
+		if err != nil {
+			return fmt.Errorf("encryption: field %s: %w", name, err)
+		}
+		*field = string(plaintext)
+	}
+	c.Encryption = nil
+	return nil
+}
+
+// encryptedStore wraps any LoanApplicationStore so that customer PII only ever
+// reaches the underlying backend encrypted.
+type encryptedStore struct {
+	inner LoanApplicationStore
+}
+
+func newEncryptedStore(inner LoanApplicationStore) *encryptedStore {
+	return &encryptedStore{inner: inner}
+}
+
+func (s *encryptedStore) decrypt(app *LoanApplicationData) (*LoanApplicationData, error) {
+	if err := forEachCustomer(app, decryptCustomer); err != nil {
+		return nil, fmt.Errorf("loan application '%s': %w", app.UUID, err)
+	}
+	return app, nil
+}
+
+func (s *encryptedStore) decryptAll(apps []*LoanApplicationData) []*LoanApplicationData {
+	out := make([]*LoanApplicationData, 0, len(apps))
+	for _, app := range apps {
+		decrypted, err := s.decrypt(app)
+		if err != nil {
+			slog.Error("skipping undecryptable application", "error", err)
+			continue
+		}
+		out = append(out, decrypted)
+	}
+	return out
+}
+
+func (s *encryptedStore) Get(uuid string) (*LoanApplicationData, bool) {
+	app, ok := s.inner.Get(uuid)
+	if !ok {
+		return nil, false
+	}
+	decrypted, err := s.decrypt(app)
+	if err != nil {
+		slog.Error("failed to decrypt application", "error", err)
+		return nil, false
+	}
+	return decrypted, true
+}
+
+func (s *encryptedStore) Create(app *LoanApplicationData) error {
+	stored := app.clone()
+	if err := forEachCustomer(stored, encryptCustomer); err != nil {
+		return err
+	}
+	return s.inner.Create(stored)
+}
+
+func (s *encryptedStore) Update(uuid string, expectedVersion int, mutate func(app *LoanApplicationData) error) (*LoanApplicationData, error) {
+	var result *LoanApplicationData
+	updated, err := s.inner.Update(uuid, expectedVersion, func(stored *LoanApplicationData) error {
+		sealed := stored.clone()
+		if _, err := s.decrypt(stored); err != nil {
+			return err
+		}
+		plain := stored.clone()
+		if err := mutate(stored); err != nil {
+			return err
+		}
+		result = stored.clone()
+		return forEachCustomer(stored, func(scope string, customer *CustomerData) error {
+			// Keep the existing ciphertext so unchanged customers are not rewritten.
+			if before, ok := customerInScope(plain, scope); ok && reflect.DeepEqual(*before, *customer) {
+				unchanged, _ := customerInScope(sealed, scope)
+				*customer = *unchanged
+				return nil
+			}
+			return encryptCustomer(scope, customer)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Version = updated.Version
+	return result, nil
+}
+
+func (s *encryptedStore) List() []*LoanApplicationData {
+	return s.decryptAll(s.inner.List())
+}
+
+func (s *encryptedStore) FindByIDNumberIndex(index string) []*LoanApplicationData {
+	return s.decryptAll(s.inner.FindByIDNumberIndex(index))
+}
+
+// customerInScope finds the customer data encrypted under scope (see forEachCustomer).
+func customerInScope(app *LoanApplicationData, scope string) (*CustomerData, bool) {
+	var found *CustomerData
+	forEachCustomer(app, func(candidate string, customer *CustomerData) error {
+		if candidate == scope {
+			found = customer
+		}
+		return nil
+	})
+	return found, found != nil
+}
+
+// GetAsOf decrypts a past state of an application; the backend must keep history.
+func (s *encryptedStore) GetAsOf(uuid string, at time.Time) (*LoanApplicationData, bool, error) {
+	history, ok := backendStore(s.inner).(historicalStore)
+	if !ok {
+		return nil, false, errNoHistory
+	}
+	app, found, err := history.GetAsOf(uuid, at)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	decrypted, err := s.decrypt(app)
+	if err != nil {
+		return nil, false, err
+	}
+	return decrypted, true, nil
+}
+
+func (s *encryptedStore) Unwrap() LoanApplicationStore { return s.inner }
+
+// Outbox events carry no customer data and pass through unchanged.
+
+func (s *encryptedStore) PendingEvents(consumer string, limit int) []OutboxEvent {
+	return s.inner.PendingEvents(consumer, limit)
+}
+
+func (s *encryptedStore) AckEvent(consumer, id string) {
+	s.inner.AckEvent(consumer, id)
+}
+
+// envelopeStore is implemented by backends that can replace the key envelopes of every
+// version they keep in place, without recording a change.
+type envelopeStore interface {
+	// rewrapEnvelopes calls rewrap on each stored version of each application; rewrap
+	// reports whether it changed anything. It returns the number of applications changed.
+	rewrapEnvelopes(rewrap func(app *LoanApplicationData) (bool, error)) (int, error)
+}
+
+// rewrapKeys re-wraps the data keys of every record not yet under the current KEK,
+// including those of uploaded documents and, in event-sourced storage, the history.
+// Ciphertext is untouched because the data keys themselves do not change, and so is the
+// version of the application: a rotation is not a change clients need to see.
+func (s *encryptedStore) rewrapKeys() (int, error) {
+	provider := currentKeyProvider()
+	current := provider.CurrentKeyID()
+	rewrap := func(envelope *EncryptionEnvelope) (*EncryptionEnvelope, error) {
+		if envelope == nil || envelope.KeyID == current {
+			return envelope, nil
+		}
+		dataKey, err := provider.UnwrapKey(envelope.KeyID, envelope.WrappedKey)
+		if err != nil {
+			return nil, err
+		}
+		wrapped, err := provider.WrapKey(current, dataKey)
+		if err != nil {
+			return nil, err
+		}
+		return &EncryptionEnvelope{KeyID: current, WrappedKey: wrapped}, nil
+	}
+	backend, ok := backendStore(s.inner).(envelopeStore)
+	if !ok {
+		return 0, fmt.Errorf("encryption: the store cannot re-wrap keys")
+	}
+	return backend.rewrapEnvelopes(func(app *LoanApplicationData) (bool, error) {
+		changed := false
+		err := forEachCustomer(app, func(_ string, customer *CustomerData) error {
+			envelope, err := rewrap(customer.Encryption)
+			if err != nil {
+				return err
+			}
+			changed = changed || envelope != customer.Encryption
+			customer.Encryption = envelope
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+		for i := range app.Documents {
+			envelope, err := rewrap(app.Documents[i].Encryption)
+			if err != nil {
+				return false, fmt.Errorf("document %s: %w", app.Documents[i].ID, err)
+			}
+			changed = changed || envelope != app.Documents[i].Encryption
+			app.Documents[i].Encryption = envelope
+		}
+		return changed, nil
+	})
+}
@@ -0,0 +1,86 @@
+package graphqlhandler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeKeyFile writes a local key file holding keys, each a fresh random key taken from
+// generated so that a key keeps its value across rewrites.
+func writeKeyFile(t *testing.T, path, current string, keys []string, generated map[string][]byte) {
+	t.Helper()
+	encoded := map[string]string{}
+	for _, id := range append(keys, "blind-index") {
+		if generated[id] == nil {
+			generated[id] = make([]byte, 32)
+			rand.Read(generated[id])
+		}
+		encoded[id] = base64.StdEncoding.EncodeToString(generated[id])
+	}
+	indexKey := encoded["blind-index"]
+	delete(encoded, "blind-index")
+	raw, _ := json.Marshal(map[string]interface{}{"current_key_id": current, "keys": encoded, "blind_index_key": indexKey})
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := UseKeyFile(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyRotationRewrapsHistory(t *testing.T) {
+	previous := currentKeyProvider()
+	t.Cleanup(func() { SetKeyProvider(previous) })
+	useEventSourcedTenants(t, "acme")
+	useTempBlobs(t)
+	store := tenants.store("acme")
+	backendStore(store).(*eventSourcedStore).snapshotEvery = 2
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	keys := map[string][]byte{}
+	writeKeyFile(t, path, "a", []string{"a"}, keys)
+	uuid := submittedJointApplication(t, "ID310000001", "ID310000002")
+	before, _ := store.Get(uuid)
+	var versions []time.Time
+	for _, event := range backendStore(store).(*eventSourcedStore).streams[uuid] {
+		versions = append(versions, event.RecordedAt)
+	}
+
+	writeKeyFile(t, path, "b", []string{"a", "b"}, keys)
+	operator := requestContext(t, &Principal{UserID: "operator", Roles: []string{RoleAdmin}}, "acme")
+	if data := mustExecute(t, operator, `mutation { reencryptCustomerData }`, nil); data["reencryptCustomerData"] != 1 {
+		t.Fatalf("re-encrypted %v applications, want 1", data["reencryptCustomerData"])
+	}
+	if app, _ := store.Get(uuid); app.Version != before.Version {
+		t.Fatalf("rotation changed the version from %d to %d", before.Version, app.Version)
+	}
+
+	// Key a can go: neither the current state nor the history needs it.
+	writeKeyFile(t, path, "b", []string{"b"}, keys)
+	backend := backendStore(store).(*eventSourcedStore)
+	raw, _ := json.Marshal([]interface{}{backend.streams, backend.snapshots, backend.projection})
+	if strings.Contains(string(raw), `"key_id":"a"`) {
+		t.Fatalf("envelopes still under key a: %s", raw)
+	}
+	app, found := store.Get(uuid)
+	if !found || app.Customer.IDNumber != "ID310000001" || app.Parties[0].Customer.IDNumber != "ID310000002" {
+		t.Fatalf("current state after rotation: %+v", app)
+	}
+	for _, at := range versions {
+		past, found, err := store.(historicalStore).GetAsOf(uuid, at)
+		if err != nil || !found || past.Customer.IDNumber != "ID310000001" {
+			t.Fatalf("GetAsOf(%v): %+v, found %v, %v", at, past, found, err)
+		}
+	}
+	data := mustExecute(t, operator, `query($u: ID!, $at: String) { getLoanApplication(uuid: $u, asOf: $at) { uuid } }`,
+		map[string]interface{}{"u": uuid, "at": versions[0].Format(time.RFC3339Nano)})
+	if data["getLoanApplication"] == nil {
+		t.Fatal("getLoanApplication(asOf:) found nothing")
+	}
+}
@@ -1,55 +1,306 @@
 package graphqlhandler
 
 import (
+	"errors"
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
 
+// LoanApplicationStore persists the loan applications of one tenant.
+// Implementations hand out copies so callers never share mutable state with the store.
+type LoanApplicationStore interface {
+	// Get returns a copy of the application, or false if it does not exist.
+	Get(uuid string) (*LoanApplicationData, bool)
+	// Create stores a new application.
+	Create(app *LoanApplicationData) error
+	// Update runs mutate on a copy of the application and stores the result with the
+	// next Version if mutate returns nil. If expectedVersion is non-zero and the stored
+	// version differs, it returns errVersionConflict without calling mutate.
+	// It returns errApplicationNotFound if the UUID is unknown.
+	Update(uuid string, expectedVersion int, mutate func(app *LoanApplicationData) error) (*LoanApplicationData, error)
+	// List returns copies of all applications, newest first.
+	List() []*LoanApplicationData
+	// FindByIDNumberIndex returns copies of the applications with a party (the customer,
+	// a co-applicant or a guarantor) that has the given id_number blind index (see
+	// blindIndex), newest first.
+	FindByIDNumberIndex(index string) []*LoanApplicationData
+	// PendingEvents returns up to limit outbox events the consumer has not acknowledged,
+	// oldest first. Create and Update record an event for every status change atomically
+	// with the change.
+	PendingEvents(consumer string, limit int) []OutboxEvent
+	// AckEvent marks an event as handled by the consumer. It leaves the outbox once
+	// every registered consumer acknowledged it.
+	AckEvent(consumer, id string)
+}
+
 // Using maps for simple in-memory storage
 // In a real app, use a database
 
-var (
-	loanApplications      = make(map[string]*LoanApplicationData)
-	loanApplicationsMutex = &sync.RWMutex{}
-)
+type memoryStore struct {
+	mu           sync.RWMutex
+	applications map[string]*LoanApplicationData
+	outboxLog    // written while mu is held, so events commit together with their change
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{applications: make(map[string]*LoanApplicationData)}
+}
+
+func (s *memoryStore) Get(uuid string) (*LoanApplicationData, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	app, ok := s.applications[uuid]
+	if !ok {
+		return nil, false
+	}
+	return app.clone(), true
+}
+
+func (s *memoryStore) Create(app *LoanApplicationData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.applications[app.UUID]; exists {
+		return fmt.Errorf("loan application with UUID '%s' already exists", app.UUID)
+	}
+	stored := app.clone()
+	stored.Version = 1
+	s.applications[app.UUID] = stored
+	s.record(newOutboxEvent("", stored, stored.CreatedBy, stored.CreatedAt))
+	return nil
+}
+
+func (s *memoryStore) Update(uuid string, expectedVersion int, mutate func(app *LoanApplicationData) error) (*LoanApplicationData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.applications[uuid]
+	if !ok {
+		return nil, errApplicationNotFound
+	}
+	if expectedVersion != 0 && current.Version != expectedVersion {
+		return nil, errVersionConflict
+	}
+	updated := current.clone()
+	if err := mutate(updated); err != nil {
+		return nil, err
+	}
+	updated.Version = current.Version + 1
+	s.applications[uuid] = updated
+	if updated.Status != current.Status {
+		s.record(newOutboxEvent(current.Status, updated, updated.UpdatedBy, updated.UpdatedAt))
+	}
+	return updated.clone(), nil
+}
+
+func (s *memoryStore) List() []*LoanApplicationData {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	apps := make([]*LoanApplicationData, 0, len(s.applications))
+	for _, app := range s.applications {
+		apps = append(apps, app.clone())
+	}
+	sortNewestFirst(apps)
+	return apps
+}
+
+// errApplicationNotFound is returned by stores for unknown UUIDs.
+var errApplicationNotFound = errors.New("loan application not found")
+
+// errVersionConflict is returned by Update when the application changed since the caller read it.
+var errVersionConflict = errors.New("loan application version conflict")
+
+func (s *memoryStore) FindByIDNumberIndex(index string) []*LoanApplicationData {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var apps []*LoanApplicationData
+	for _, app := range s.applications {
+		if app.hasIDNumberIndex(index) {
+			apps = append(apps, app.clone())
+		}
+	}
+	sortNewestFirst(apps)
+	return apps
+}
+
+// rewrapEnvelopes replaces applications in place, keeping their version.
+func (s *memoryStore) rewrapEnvelopes(rewrap func(app *LoanApplicationData) (bool, error)) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for uuid, app := range s.applications {
+		updated := app.clone()
+		changed, err := rewrap(updated)
+		if err != nil {
+			return count, fmt.Errorf("loan application '%s': %w", uuid, err)
+		}
+		if changed {
+			s.applications[uuid] = updated
+			count++
+		}
+	}
+	return count, nil
+}
+
+func sortNewestFirst(apps []*LoanApplicationData) {
+	sort.Slice(apps, func(i, j int) bool {
+		if apps[i].CreatedAt.Equal(apps[j].CreatedAt) {
+			return apps[i].UUID < apps[j].UUID
+		}
+		return apps[i].CreatedAt.After(apps[j].CreatedAt)
+	})
+}
 
 // Internal data structures for storage (matching GraphQL types but as Go structs)
 // These are separate from the graphql.Object definitions but will hold the data.
 
+// AddressData is a postal address. For countries with a region dataset (see regions.go)
+// the region names are standardized and checked against the zipcode.
 type AddressData struct {
-	Street  string `json:"street"`
-	City    string `json:"city"`
-	Zipcode string `json:"zipcode"`
+	Street      string    `json:"street"`
+	SubDistrict string    `json:"sub_district,omitempty"` // Kelurahan or desa
+	District    string    `json:"district,omitempty"`     // Kecamatan
+	City        string    `json:"city"`                   // City or regency
+	Province    string    `json:"province,omitempty"`
+	Zipcode     string    `json:"zipcode"`
+	Country     string    `json:"country,omitempty"` // ISO 3166-1 alpha-2, selects the postal code rules
+	Geo         *GeoPoint `json:"geo,omitempty"`
+}
+
+// GeoPoint is a WGS 84 coordinate.
+type GeoPoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
 }
 
 type CustomerData struct {
 	FullName    string      `json:"full_name"`
 	DateOfBirth string      `json:"date_of_birth"` // Store as string, validate in resolver
 	IDNumber    string      `json:"id_number"`
-	Email       string      `json:"email,omitempty"` // Store as string, validate in resolver
-	Phone       string      `json:"phone"`
+	Email       string      `json:"email,omitempty"`   // Store as string, validate in resolver
+	Phone       string      `json:"phone"`             // E.164 when the country is known or given in international format
+	Country     string      `json:"country,omitempty"` // ISO 3166-1 alpha-2, selects the ID and phone rules
+	Gender      string      `json:"gender,omitempty"`  // MALE or FEMALE, optional
 	Address     AddressData `json:"address"`
+
+	// Financial data, optional; see affordability.go. Employer is encrypted at rest.
+	EmploymentType     string  `json:"employment_type,omitempty"`
+	Employer           string  `json:"employer,omitempty"`
+	MonthlyIncome      float64 `json:"monthly_income,omitempty"`
+	MonthlyObligations float64 `json:"monthly_obligations,omitempty"` // Installments of existing debt
+	Dependents         int     `json:"dependents,omitempty"`
+
+	// IDNumberIndex is the blind index of IDNumber, usable for lookups while IDNumber is encrypted.
+	IDNumberIndex string `json:"id_number_index,omitempty"`
+	// Encryption is set while IDNumber, Phone, Email, DateOfBirth, Gender and Employer hold ciphertext (see crypto.go).
+	Encryption *EncryptionEnvelope `json:"encryption,omitempty"`
 }
 
 type CollateralData struct {
-	Category           string `json:"category"` // CAR, MOTORCYCLE
-	Brand              string `json:"brand"`
-	Variant            string `json:"variant"`
-	ManufacturingYear  int    `json:"manufacturing_year"`
-	IsDocumentComplete bool   `json:"is_document_complete"`
+	ID                 string   `json:"id"`
+	Category           string   `json:"category"`        // See collateraldetails.go
+	Brand              string   `json:"brand,omitempty"` // Brand, Model, Variant and ManufacturingYear are set for vehicles and heavy equipment
+	Model              string   `json:"model,omitempty"` // Empty for collaterals created before the vehicle catalog
+	Variant            string   `json:"variant,omitempty"`
+	ManufacturingYear  int      `json:"manufacturing_year,omitempty"`
+	Value              float64  `json:"value"`                       // Appraised value in the loan currency, 0 when not valued
+	IsDocumentComplete bool     `json:"is_document_complete"`        // Derived from the uploaded documents, see documents.go
+	MissingDocuments   []string `json:"missing_documents,omitempty"` // Derived together with IsDocumentComplete
+
+	// Category specific details; at most one is set.
+	Vehicle             *VehicleDetails             `json:"vehicle,omitempty"`
+	HeavyEquipment      *HeavyEquipmentDetails      `json:"heavy_equipment,omitempty"`
+	PropertyCertificate *PropertyCertificateDetails `json:"property_certificate,omitempty"`
+	Gold                *GoldDetails                `json:"gold,omitempty"`
 }
 
 type ProposedLoanData struct {
-	Tenure int     `json:"tenure"`
-	Amount float64 `json:"amount"`
+	Tenure       int     `json:"tenure"`
+	Amount       float64 `json:"amount"`
+	InterestRate float64 `json:"interest_rate"` // Annual percent from the tenant rate card at creation
 }
 
 type LoanApplicationData struct {
 	UUID         string           `json:"uuid"`
-	Status       string           `json:"status"` // DRAFT, SUBMITTED, CANCELLED
+	Status       string           `json:"status"`  // DRAFT, SUBMITTED, APPROVED, REJECTED, CANCELLED, EXPIRED
+	Version      int              `json:"version"` // Starts at 1, incremented by every store update
 	ProposedLoan ProposedLoanData `json:"proposed_loan"`
-	Collateral   CollateralData   `json:"collateral"`
-	Customer     CustomerData     `json:"customer"`
+	Collaterals  []CollateralData `json:"collaterals"`       // The first item decides the rate card category
+	Customer     CustomerData     `json:"customer"`          // The PRIMARY party
+	Consent      ConsentData      `json:"consent"`           // Consent of the PRIMARY party
+	Parties      []PartyData      `json:"parties,omitempty"` // Co-applicants and guarantors
+	Documents    []DocumentData   `json:"documents,omitempty"`
 	CreatedAt    time.Time        `json:"created_at"`
 	UpdatedAt    time.Time        `json:"updated_at"`
+	CreatedBy    string           `json:"created_by"` // Principal user ID
+	UpdatedBy    string           `json:"updated_by"`
+	OwnerID      string           `json:"owner_id"` // Principal that created the application
+	TenantID     string           `json:"tenant_id"`
+
+	TermsAcceptance  *TermsAcceptanceData `json:"terms_acceptance,omitempty"`   // Set when the application is submitted
+	CustomerErasedAt *time.Time           `json:"customer_erased_at,omitempty"` // Set when the customer's data was erased on request
+}
+
+// clonePtr copies the value a pointer refers to.
+func clonePtr[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	return &c
+}
+
+// clone returns a deep copy of the application.
+func (app *LoanApplicationData) clone() *LoanApplicationData {
+	c := *app
+	if app.CustomerErasedAt != nil {
+		erasedAt := *app.CustomerErasedAt
+		c.CustomerErasedAt = &erasedAt
+	}
+	if app.Customer.Encryption != nil {
+		envelope := *app.Customer.Encryption
+		envelope.WrappedKey = append([]byte(nil), envelope.WrappedKey...)
+		c.Customer.Encryption = &envelope
+	}
+	c.Customer.Address.Geo = clonePtr(app.Customer.Address.Geo)
+	c.TermsAcceptance = app.TermsAcceptance.clone()
+	if app.Collaterals != nil {
+		c.Collaterals = make([]CollateralData, len(app.Collaterals))
+		for i, item := range app.Collaterals {
+			item.MissingDocuments = append([]string(nil), item.MissingDocuments...)
+			item.Vehicle = clonePtr(item.Vehicle)
+			item.HeavyEquipment = clonePtr(item.HeavyEquipment)
+			item.PropertyCertificate = clonePtr(item.PropertyCertificate)
+			item.Gold = clonePtr(item.Gold)
+			c.Collaterals[i] = item
+		}
+	}
+	if app.Parties != nil {
+		c.Parties = make([]PartyData, len(app.Parties))
+		for i, party := range app.Parties {
+			if party.Customer.Encryption != nil {
+				envelope := *party.Customer.Encryption
+				envelope.WrappedKey = append([]byte(nil), envelope.WrappedKey...)
+				party.Customer.Encryption = &envelope
+			}
+			party.Customer.Address.Geo = clonePtr(party.Customer.Address.Geo)
+			c.Parties[i] = party
+		}
+	}
+	if app.Documents != nil {
+		c.Documents = make([]DocumentData, len(app.Documents))
+		for i, doc := range app.Documents {
+			if doc.Encryption != nil {
+				envelope := *doc.Encryption
+				envelope.WrappedKey = append([]byte(nil), envelope.WrappedKey...)
+				doc.Encryption = &envelope
+			}
+			if doc.ReviewedAt != nil {
+				reviewedAt := *doc.ReviewedAt
+				doc.ReviewedAt = &reviewedAt
+			}
+			c.Documents[i] = doc
+		}
+	}
+	return &c
 }
@@ -0,0 +1,510 @@
+package graphqlhandler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+)
+
+// Document types that can be attached to an application.
+const (
+	DocumentTypeIDCard              = "ID_CARD"
+	DocumentTypeVehicleRegistration = "VEHICLE_REGISTRATION" // STNK
+	DocumentTypeOwnershipBook       = "OWNERSHIP_BOOK"       // BPKB
+	DocumentTypePropertyCertificate = "PROPERTY_CERTIFICATE" // SHM, SHGB or SHMSRS
+	DocumentTypePurchaseInvoice     = "PURCHASE_INVOICE"
+)
+
+var documentTypes = []string{
+	DocumentTypeIDCard, DocumentTypeVehicleRegistration, DocumentTypeOwnershipBook,
+	DocumentTypePropertyCertificate, DocumentTypePurchaseInvoice,
+}
+
+func isDocumentType(docType string) bool {
+	for _, known := range documentTypes {
+		if known == docType {
+			return true
+		}
+	}
+	return false
+}
+
+// Document statuses. New uploads wait for an underwriter's review; rejected documents
+// do not count towards the checklist.
+const (
+	DocumentStatusUploaded = "UPLOADED"
+	DocumentStatusVerified = "VERIFIED"
+	DocumentStatusRejected = "REJECTED"
+)
+
+// RequiredDocuments lists the document types an application needs per collateral category.
+type RequiredDocuments map[string][]string
+
+var defaultRequiredDocuments = RequiredDocuments{
+	CollateralCategoryCar:                 {DocumentTypeIDCard, DocumentTypeVehicleRegistration, DocumentTypeOwnershipBook},
+	CollateralCategoryMotorcycle:          {DocumentTypeIDCard, DocumentTypeVehicleRegistration, DocumentTypeOwnershipBook},
+	CollateralCategoryTruck:               {DocumentTypeIDCard, DocumentTypeVehicleRegistration, DocumentTypeOwnershipBook},
+	CollateralCategoryHeavyEquipment:      {DocumentTypeIDCard, DocumentTypePurchaseInvoice},
+	CollateralCategoryPropertyCertificate: {DocumentTypeIDCard, DocumentTypePropertyCertificate},
+	CollateralCategoryGold:                {DocumentTypeIDCard},
+}
+
+// documentContentTypes are the accepted file formats, detected from the content.
+var documentContentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"application/pdf": ".pdf",
+}
+
+// DocumentData is the metadata of an uploaded document. The content lives in the blob
+// store under BlobKey, encrypted with its own data key (see crypto.go).
+type DocumentData struct {
+	ID              string              `json:"id"`
+	Type            string              `json:"type"`
+	Status          string              `json:"status"`
+	FileName        string              `json:"file_name"`
+	ContentType     string              `json:"content_type"`
+	Size            int64               `json:"size"`
+	SHA256          string              `json:"sha256"` // of the plaintext content
+	BlobKey         string              `json:"blob_key"`
+	Encryption      *EncryptionEnvelope `json:"encryption,omitempty"`
+	UploadedBy      string              `json:"uploaded_by"`
+	UploadedAt      time.Time           `json:"uploaded_at"`
+	ReviewedBy      string              `json:"reviewed_by,omitempty"`
+	ReviewedAt      *time.Time          `json:"reviewed_at,omitempty"`
+	RejectionReason string              `json:"rejection_reason,omitempty"`
+	CollateralID    string              `json:"collateral_id,omitempty"` // Set for collateral documents
+}
+
+// isCollateralDocument reports whether documents of the type describe one collateral
+// item, so every item needs its own. Only the ID card belongs to the application.
+func isCollateralDocument(docType string) bool {
+	return docType != DocumentTypeIDCard
+}
+
+// missingCollateralDocuments returns the document types the item's category requires
+// that have no document waiting for review or verified. Collateral documents must be
+// attached to the item itself.
+func missingCollateralDocuments(app *LoanApplicationData, item CollateralData, required RequiredDocuments) []string {
+	missing := []string{}
+	for _, docType := range required[item.Category] {
+		found := false
+		for _, doc := range app.Documents {
+			if doc.Type == docType && doc.Status != DocumentStatusRejected &&
+				(!isCollateralDocument(docType) || doc.CollateralID == item.ID) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, docType)
+		}
+	}
+	return missing
+}
+
+// missingDocuments returns the document types still needed by any collateral item.
+func missingDocuments(app *LoanApplicationData, required RequiredDocuments) []string {
+	missing := []string{}
+	seen := map[string]bool{}
+	for _, item := range app.Collaterals {
+		for _, docType := range missingCollateralDocuments(app, item, required) {
+			if !seen[docType] {
+				seen[docType] = true
+				missing = append(missing, docType)
+			}
+		}
+	}
+	return missing
+}
+
+// refreshDocumentCompleteness derives missing_documents and is_document_complete of
+// every collateral item from the checklist.
+func refreshDocumentCompleteness(app *LoanApplicationData, required RequiredDocuments) {
+	for i := range app.Collaterals {
+		item := &app.Collaterals[i]
+		item.MissingDocuments = missingCollateralDocuments(app, *item, required)
+		item.IsDocumentComplete = len(item.MissingDocuments) == 0
+	}
+}
+
+// BlobStore keeps the content of uploaded documents under opaque keys.
+type BlobStore interface {
+	Put(ctx context.Context, key string, content []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// errBlobNotFound is returned by blob stores for unknown keys.
+var errBlobNotFound = errors.New("blob not found")
+
+// FileBlobStore keeps blobs as files below Dir, one file per key.
+type FileBlobStore struct {
+	Dir string
+}
+
+func NewFileBlobStore(dir string) *FileBlobStore {
+	return &FileBlobStore{Dir: dir}
+}
+
+func (s *FileBlobStore) path(key string) (string, error) {
+	if !filepath.IsLocal(key) {
+		return "", fmt.Errorf("blob key %q is not a relative path", key)
+	}
+	return filepath.Join(s.Dir, key), nil
+}
+
+// Put writes the blob to a temporary file first, so readers never see partial content.
+func (s *FileBlobStore) Put(_ context.Context, key string, content []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileBlobStore) Get(_ context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errBlobNotFound
+	}
+	return content, err
+}
+
+func (s *FileBlobStore) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// documentBlobs stores document content; see SetBlobStore.
+var documentBlobs BlobStore = NewFileBlobStore(filepath.Join(os.TempDir(), "loan-documents"))
+
+// SetBlobStore replaces the store used for document content. Call it at startup.
+func SetBlobStore(store BlobStore) {
+	documentBlobs = store
+}
+
+// sealDocument encrypts document content with a fresh data key.
+func sealDocument(appUUID, documentID string, content []byte) ([]byte, *EncryptionEnvelope, error) {
+	provider := currentKeyProvider()
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, err
+	}
+	sealed, err := sealAESGCM(dataKey, content, []byte(appUUID+"/document/"+documentID))
+	if err != nil {
+		return nil, nil, err
+	}
+	keyID := provider.CurrentKeyID()
+	wrapped, err := provider.WrapKey(keyID, dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sealed, &EncryptionEnvelope{KeyID: keyID, WrappedKey: wrapped}, nil
+}
+
+func openDocument(appUUID string, doc *DocumentData, sealed []byte) ([]byte, error) {
+	if doc.Encryption == nil {
+		return nil, fmt.Errorf("document %s has no encryption envelope", doc.ID)
+	}
+	dataKey, err := currentKeyProvider().UnwrapKey(doc.Encryption.KeyID, doc.Encryption.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	return openAESGCM(dataKey, sealed, []byte(appUUID+"/document/"+doc.ID))
+}
+
+// deleteDocumentBlobs removes the content of documents dropped from an application.
+func deleteDocumentBlobs(ctx context.Context, docs []DocumentData) {
+	for _, doc := range docs {
+		if err := documentBlobs.Delete(ctx, doc.BlobKey); err != nil {
+			slog.ErrorContext(ctx, "failed to delete document content", "document_id", doc.ID, "error", err)
+		}
+	}
+}
+
+var uploadLoanApplicationDocumentResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	principal, err := requirePrincipal(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	tenant, store, err := requireTenant(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	uuidArg, _ := p.Args["uuid"].(string)
+	docType, _ := p.Args["type"].(string)
+	upload, err := uploadFromContext(p.Context, p.Args["file"])
+	if err != nil {
+		return nil, err
+	}
+	// Check access before anything is written to the blob store.
+	app, ok := store.Get(uuidArg)
+	if !ok || !canAccessApplication(p.Context, app) {
+		return nil, applicationNotFound(uuidArg)
+	}
+	collateralID, err := documentCollateral(app, docType, p.Args["collateralId"])
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := upload.Open()
+	if err != nil {
+		return nil, err
+	}
+	content, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(content) == 0 {
+		return nil, newLocalizedAPIError(ErrCodeBadRequest, MsgFileEmpty)
+	}
+	contentType := http.DetectContentType(content)
+	if _, ok := documentContentTypes[contentType]; !ok {
+		return nil, newLocalizedAPIError(ErrCodeBadRequest, MsgFileTypeNotAccepted, contentType)
+	}
+
+	digest := sha256.Sum256(content)
+	doc := DocumentData{
+		ID:           uuid.New().String(),
+		Type:         docType,
+		Status:       DocumentStatusUploaded,
+		FileName:     filepath.Base(filepath.Clean("/" + upload.FileName)),
+		ContentType:  contentType,
+		Size:         int64(len(content)),
+		SHA256:       hex.EncodeToString(digest[:]),
+		UploadedBy:   principal.UserID,
+		UploadedAt:   time.Now(),
+		CollateralID: collateralID,
+	}
+	if doc.FileName == "/" {
+		doc.FileName = doc.ID + documentContentTypes[contentType]
+	}
+	doc.BlobKey = tenant.ID + "/" + uuidArg + "/" + doc.ID
+	sealed, envelope, err := sealDocument(uuidArg, doc.ID, content)
+	if err != nil {
+		return nil, err
+	}
+	doc.Encryption = envelope
+	if err := documentBlobs.Put(p.Context, doc.BlobKey, sealed); err != nil {
+		return nil, fmt.Errorf("store document: %w", err)
+	}
+
+	_, err = updateLoanApplication(p, func(app *LoanApplicationData, principal *Principal) error {
+		if app.Status != "DRAFT" && app.Status != "SUBMITTED" {
+			return newLocalizedAPIError(ErrCodeConflict, MsgCannotAddDocument, app.Status)
+		}
+		app.Documents = append(app.Documents, doc)
+		refreshDocumentCompleteness(app, tenant.RequiredDocuments)
+		app.UpdatedAt = doc.UploadedAt
+		app.UpdatedBy = principal.UserID
+		return nil
+	})
+	if err != nil {
+		deleteDocumentBlobs(p.Context, []DocumentData{doc})
+		return nil, err
+	}
+	recordAudit(p.Context, AuditActionDocumentUploaded, uuidArg)
+	return &doc, nil
+}
+
+// documentCollateral returns the collateral item a new document describes. Collateral
+// documents may omit the item when the application has only one.
+func documentCollateral(app *LoanApplicationData, docType string, collateralArg interface{}) (string, error) {
+	collateralID, _ := collateralArg.(string)
+	if !isCollateralDocument(docType) {
+		if collateralID != "" {
+			return "", newLocalizedAPIError(ErrCodeBadRequest, MsgApplicationDocument, docType)
+		}
+		return "", nil
+	}
+	if collateralID == "" {
+		if len(app.Collaterals) != 1 {
+			return "", newLocalizedAPIError(ErrCodeBadRequest, MsgCollateralIDRequired, docType)
+		}
+		return app.Collaterals[0].ID, nil
+	}
+	if _, ok := app.findCollateral(collateralID); !ok {
+		return "", newLocalizedAPIError(ErrCodeNotFound, MsgCollateralNotFound, collateralID)
+	}
+	return collateralID, nil
+}
+
+var reviewLoanApplicationDocumentResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	tenant, _, err := requireTenant(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	documentID, _ := p.Args["documentId"].(string)
+	decision, _ := p.Args["decision"].(string)
+	reason, _ := p.Args["reason"].(string)
+	reason = normalizeText(reason)
+	if decision == DocumentStatusRejected && reason == "" {
+		return nil, newLocalizedAPIError(ErrCodeBadRequest, MsgRejectionReasonRequired)
+	}
+	if err := validateText("reason", reason, 0, 500); err != nil {
+		return nil, err
+	}
+
+	var reviewed *DocumentData
+	_, err = updateLoanApplication(p, func(app *LoanApplicationData, principal *Principal) error {
+		for i := range app.Documents {
+			doc := &app.Documents[i]
+			if doc.ID != documentID {
+				continue
+			}
+			now := time.Now()
+			doc.Status = decision
+			doc.ReviewedBy = principal.UserID
+			doc.ReviewedAt = &now
+			doc.RejectionReason = ""
+			if decision == DocumentStatusRejected {
+				doc.RejectionReason = reason
+			}
+			refreshDocumentCompleteness(app, tenant.RequiredDocuments)
+			app.UpdatedAt = now
+			app.UpdatedBy = principal.UserID
+			copied := *doc
+			reviewed = &copied
+			return nil
+		}
+		return newLocalizedAPIError(ErrCodeNotFound, MsgDocumentNotFound, documentID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	recordAudit(p.Context, AuditActionDocumentReviewed, p.Args["uuid"].(string))
+	return reviewed, nil
+}
+
+var documentsResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	app, ok := p.Source.(*LoanApplicationData)
+	if !ok || app.Documents == nil {
+		return []DocumentData{}, nil
+	}
+	return app.Documents, nil
+}
+
+// requiredDocumentsOf returns the checklist of the request's tenant.
+func requiredDocumentsOf(p graphql.ResolveParams) RequiredDocuments {
+	if tenant, ok := TenantFromContext(p.Context); ok {
+		return tenant.RequiredDocuments
+	}
+	return defaultRequiredDocuments
+}
+
+var missingDocumentsResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	app, ok := p.Source.(*LoanApplicationData)
+	if !ok {
+		return nil, nil
+	}
+	return missingDocuments(app, requiredDocumentsOf(p)), nil
+}
+
+// documentDownloadPath is where DocumentDownloadHandler is mounted.
+const documentDownloadPath = "/documents/"
+
+// DocumentDownloadHandler serves document content at
+// /documents/<application uuid>/<document id> to callers allowed to see raw customer
+// data. It must run behind the authentication and tenant middlewares.
+func DocumentDownloadHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeHTTPError(w, r, http.StatusMethodNotAllowed, newLocalizedAPIError(ErrCodeBadRequest, MsgMethodNotAllowed, http.MethodGet))
+			return
+		}
+		principal, err := requirePrincipal(r.Context())
+		if err != nil {
+			writeHTTPError(w, r, http.StatusUnauthorized, err.(*apiError))
+			return
+		}
+		if !hasAnyRole(principal, piiRawAccessRoles) {
+			writeHTTPError(w, r, http.StatusForbidden, newLocalizedAPIError(ErrCodeForbidden, MsgDocumentDownloadForbidden))
+			return
+		}
+		_, store, err := requireTenant(r.Context())
+		if err != nil {
+			writeHTTPError(w, r, http.StatusBadRequest, err.(*apiError))
+			return
+		}
+		appUUID, documentID, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, documentDownloadPath), "/")
+		notFound := func() {
+			writeHTTPError(w, r, http.StatusNotFound, newLocalizedAPIError(ErrCodeNotFound, MsgDocumentNotFound, documentID))
+		}
+		app, ok := store.Get(appUUID)
+		if !ok || !canAccessApplication(r.Context(), app) {
+			notFound()
+			return
+		}
+		var doc *DocumentData
+		for i := range app.Documents {
+			if app.Documents[i].ID == documentID {
+				doc = &app.Documents[i]
+			}
+		}
+		if doc == nil {
+			notFound()
+			return
+		}
+		sealed, err := documentBlobs.Get(r.Context(), doc.BlobKey)
+		if errors.Is(err, errBlobNotFound) {
+			notFound()
+			return
+		}
+		var content []byte
+		if err == nil {
+			content, err = openDocument(app.UUID, doc, sealed)
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to read document", "application_uuid", app.UUID, "document_id", doc.ID, "error", err)
+			http.Error(w, "document could not be read", http.StatusInternalServerError)
+			return
+		}
+		recordAudit(r.Context(), AuditActionDocumentDownloaded, app.UUID)
+		w.Header().Set("Content-Type", doc.ContentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": doc.FileName}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(content)
+	})
+}
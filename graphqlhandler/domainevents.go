@@ -0,0 +1,216 @@
+package graphqlhandler
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// DomainEventSchemaVersion is the version of the DomainEvent envelope and data
+// documented in README.md. Additive changes keep the version; anything that removes
+// or changes the meaning of a field increments it.
+const DomainEventSchemaVersion = 1
+
+// Domain event types.
+const (
+	DomainEventApplicationCreated   = "ApplicationCreated"
+	DomainEventApplicationSubmitted = "ApplicationSubmitted"
+	DomainEventApplicationCancelled = "ApplicationCancelled"
+	DomainEventApplicationApproved  = "ApplicationApproved"
+	DomainEventApplicationRejected  = "ApplicationRejected"
+	DomainEventApplicationExpired   = "ApplicationExpired"
+)
+
+var domainEventTypes = map[string]string{
+	EventApplicationCreated:   DomainEventApplicationCreated,
+	EventApplicationSubmitted: DomainEventApplicationSubmitted,
+	EventApplicationCancelled: DomainEventApplicationCancelled,
+	EventApplicationApproved:  DomainEventApplicationApproved,
+	EventApplicationRejected:  DomainEventApplicationRejected,
+	EventApplicationExpired:   DomainEventApplicationExpired,
+}
+
+// DomainEvent is the message published to the event stream. It never carries customer PII.
+type DomainEvent struct {
+	ID                 string               `json:"id"`
+	Type               string               `json:"type"`
+	SchemaVersion      int                  `json:"schema_version"`
+	TenantID           string               `json:"tenant_id"`
+	ApplicationUUID    string               `json:"application_uuid"`
+	ApplicationVersion int                  `json:"application_version"`
+	Actor              string               `json:"actor"`
+	OccurredAt         time.Time            `json:"occurred_at"`
+	Data               ApplicationEventData `json:"data"`
+}
+
+// ApplicationEventData is the type specific part of a DomainEvent.
+type ApplicationEventData struct {
+	PreviousStatus string `json:"previous_status,omitempty"`
+	Status         string `json:"status"`
+
+	// Set for ApplicationCreated only.
+	ProposedLoan       *ProposedLoanData `json:"proposed_loan,omitempty"`
+	CollateralCategory string            `json:"collateral_category,omitempty"`
+}
+
+// newDomainEvent converts an outbox event. The outbox event ID is kept so consumers
+// can deduplicate redelivered messages.
+func newDomainEvent(event OutboxEvent) (DomainEvent, error) {
+	eventType, ok := domainEventTypes[event.Type]
+	if !ok {
+		return DomainEvent{}, fmt.Errorf("no domain event for %q", event.Type)
+	}
+	return DomainEvent{
+		ID:                 event.ID,
+		Type:               eventType,
+		SchemaVersion:      DomainEventSchemaVersion,
+		TenantID:           event.TenantID,
+		ApplicationUUID:    event.ApplicationUUID,
+		ApplicationVersion: event.Version,
+		Actor:              event.Actor,
+		OccurredAt:         event.OccurredAt,
+		Data: ApplicationEventData{
+			PreviousStatus:     event.PreviousStatus,
+			Status:             event.Status,
+			ProposedLoan:       event.ProposedLoan,
+			CollateralCategory: event.CollateralCategory,
+		},
+	}, nil
+}
+
+// EventPublisher sends domain events to a sink. Publish must not return before the
+// sink has accepted the event; a failed event is retried, so sinks see it at least once.
+type EventPublisher interface {
+	Publish(ctx context.Context, event DomainEvent) error
+	Close() error
+}
+
+// InProcessPublisher hands events to handlers registered in the same process.
+type InProcessPublisher struct {
+	mu       sync.RWMutex
+	handlers []func(DomainEvent)
+}
+
+func NewInProcessPublisher() *InProcessPublisher {
+	return &InProcessPublisher{}
+}
+
+// Subscribe registers a handler called synchronously for every published event.
+func (p *InProcessPublisher) Subscribe(handler func(DomainEvent)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers = append(p.handlers, handler)
+}
+
+func (p *InProcessPublisher) Publish(_ context.Context, event DomainEvent) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, handler := range p.handlers {
+		handler(event)
+	}
+	return nil
+}
+
+func (p *InProcessPublisher) Close() error { return nil }
+
+// JSONLFilePublisher appends one JSON document per line to a file, for local runs.
+type JSONLFilePublisher struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewJSONLFilePublisher opens (or creates) path for appending.
+func NewJSONLFilePublisher(path string) (*JSONLFilePublisher, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("events: open %s: %w", path, err)
+	}
+	return &JSONLFilePublisher{file: file}, nil
+}
+
+func (p *JSONLFilePublisher) Publish(_ context.Context, event DomainEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return p.file.Sync()
+}
+
+func (p *JSONLFilePublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.file.Close()
+}
+
+// eventRelayMetrics is published at /debug/vars under "domain_events".
+var eventRelayMetrics = expvar.NewMap("domain_events")
+
+// EventRelay drains the outbox of every tenant into one sink. Events of a tenant are
+// published in order; after a failure the relay stops for that tenant and retries on
+// the next run, so an event is acknowledged only after the publisher accepted it.
+// Every sink gets its own relay and outbox consumer, so a sink that is down neither
+// holds back the others nor makes them receive events again.
+type EventRelay struct {
+	Publisher EventPublisher
+	Interval  time.Duration
+	BatchSize int
+
+	sink     string
+	consumer string
+}
+
+// NewEventRelay returns a relay into publisher; sink names it in the outbox and in logs.
+func NewEventRelay(sink string, publisher EventPublisher, interval time.Duration) *EventRelay {
+	consumer := outboxConsumerDomainEvents + ":" + sink
+	registerOutboxConsumer(consumer)
+	return &EventRelay{Publisher: publisher, Interval: interval, BatchSize: 100, sink: sink, consumer: consumer}
+}
+
+// Run relays immediately and then every Interval until ctx is cancelled, then closes the publisher.
+func (r *EventRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	defer r.Publisher.Close()
+	for {
+		r.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce publishes the pending events of every tenant and returns how many were published.
+func (r *EventRelay) RunOnce(ctx context.Context) int {
+	published := 0
+	for id, store := range tenants.allStores() {
+		for _, outboxEvent := range store.PendingEvents(r.consumer, r.BatchSize) {
+			event, err := newDomainEvent(outboxEvent)
+			if err != nil {
+				slog.ErrorContext(ctx, "skipping unpublishable event", "sink", r.sink, "event_id", outboxEvent.ID, "error", err)
+				store.AckEvent(r.consumer, outboxEvent.ID)
+				continue
+			}
+			if err := r.Publisher.Publish(ctx, event); err != nil {
+				eventRelayMetrics.Add("failures", 1)
+				slog.WarnContext(ctx, "failed to publish domain event, will retry", "sink", r.sink, "tenant", id, "event_id", event.ID, "error", err)
+				break
+			}
+			store.AckEvent(r.consumer, outboxEvent.ID)
+			published++
+		}
+	}
+	eventRelayMetrics.Add("published", int64(published))
+	return published
+}
@@ -0,0 +1,112 @@
+package graphqlhandler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestJSONLFilePublisherAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	for _, id := range []string{"event-1", "event-2"} {
+		// Every run reopens the file, as after a restart.
+		publisher, err := NewJSONLFilePublisher(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := publisher.Publish(context.Background(), testDomainEvent(id)); err != nil {
+			t.Fatal(err)
+		}
+		publisher.Close()
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var ids []string
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		var event DomainEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		ids = append(ids, event.ID)
+	}
+	if !slices.Equal(ids, []string{"event-1", "event-2"}) {
+		t.Fatalf("file holds %v", ids)
+	}
+}
+
+func TestEventRelayAcksAfterPublish(t *testing.T) {
+	server := startNATSStandIn(t)
+	publisher, _ := NewNATSPublisher(server.url(), "")
+	relay := NewEventRelay("test-nats", publisher, time.Second)
+	defer publisher.Close()
+	useTenants(t, "acme")
+	store := tenants.store("acme")
+	createDraft(t, requestContext(t, agentOf("acme"), ""), testDraft("ID380000001"))
+	pending := store.PendingEvents(relay.consumer, 10)
+	if len(pending) != 1 {
+		t.Fatalf("%d pending events, want 1", len(pending))
+	}
+
+	server.reject.Store(true)
+	if published := relay.RunOnce(context.Background()); published != 0 {
+		t.Fatalf("%d events published while NATS rejects them", published)
+	}
+	if len(store.PendingEvents(relay.consumer, 10)) != 1 {
+		t.Fatal("event acknowledged although the publish failed")
+	}
+
+	server.reject.Store(false)
+	if published := relay.RunOnce(context.Background()); published != 1 {
+		t.Fatalf("%d events published, want 1", published)
+	}
+	if left := store.PendingEvents(relay.consumer, 10); len(left) != 0 {
+		t.Fatalf("%d events still pending after the publish", len(left))
+	}
+	relay.RunOnce(context.Background())
+	if ids := server.msgIDs(); !slices.Equal(ids, []string{pending[0].ID}) {
+		t.Fatalf("NATS accepted %v, want the event once", ids)
+	}
+}
+
+func TestEventRelaySinksAreIndependent(t *testing.T) {
+	var delivered []string
+	inProcess := NewInProcessPublisher()
+	inProcess.Subscribe(func(event DomainEvent) { delivered = append(delivered, event.ID) })
+	server := startNATSStandIn(t)
+	nats, _ := NewNATSPublisher(server.url(), "")
+	defer nats.Close()
+	relays := []*EventRelay{NewEventRelay("test-in-process", inProcess, time.Second), NewEventRelay("test-nats-down", nats, time.Second)}
+	useTenants(t, "acme")
+	createDraft(t, requestContext(t, agentOf("acme"), ""), testDraft("ID380000002"))
+
+	// While NATS is down, the in-process subscribers get the event exactly once.
+	server.reject.Store(true)
+	for i := 0; i < 3; i++ {
+		for _, relay := range relays {
+			relay.RunOnce(context.Background())
+		}
+	}
+	if len(delivered) != 1 {
+		t.Fatalf("in-process subscribers got %v, want the event once", delivered)
+	}
+
+	server.reject.Store(false)
+	for _, relay := range relays {
+		relay.RunOnce(context.Background())
+	}
+	if ids := server.msgIDs(); !slices.Equal(ids, delivered) {
+		t.Fatalf("NATS accepted %v once it was back, want %v", ids, delivered)
+	}
+	if len(delivered) != 1 {
+		t.Fatalf("in-process subscribers got %v after NATS recovered", delivered)
+	}
+}
@@ -0,0 +1,147 @@
+package graphqlhandler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// Eligibility is the outcome of assessing an application against the tenant's rules.
+// Reasons explain every failed rule; an application is eligible when there are none.
+type Eligibility struct {
+	Eligible bool     `json:"eligible"`
+	Score    int      `json:"score"`
+	Reasons  []string `json:"reasons"`
+}
+
+// Scorecard weights. The score starts at eligibilityBaseScore and is kept between 0 and 100.
+const (
+	eligibilityBaseScore     = 50
+	scoreCoApplicant         = 15 // a second borrower shares the liability
+	scoreGuarantor           = 10
+	scoreBorrowerPrimeAge    = 10 // a borrower aged 25 to 50 at application
+	scoreDocumentsComplete   = 15
+	scoreComfortableDTI      = 10  // debt-to-income at or below comfortableDTI
+	scoreFlaggedDTI          = -20 // debt-to-income above the maximum, FLAG action
+	primeAgeMin, primeAgeMax = 25, 50
+	comfortableDTI           = 30
+)
+
+// ageOn returns the age in whole years on the given day, or false for an invalid date of birth.
+func ageOn(dateOfBirth string, day time.Time) (int, bool) {
+	dob, err := time.Parse("2006-01-02", dateOfBirth)
+	if err != nil {
+		return 0, false
+	}
+	age := day.Year() - dob.Year()
+	if day.Month() < dob.Month() || (day.Month() == dob.Month() && day.Day() < dob.Day()) {
+		age--
+	}
+	return age, true
+}
+
+func isBorrower(role string) bool {
+	return role == PartyRolePrimary || role == PartyRoleCoApplicant
+}
+
+func partyLabel(party PartyData) string {
+	return strings.ToLower(strings.ReplaceAll(party.Role, "_", "-"))
+}
+
+// assessEligibility evaluates the combined profile of all parties. Every party must be
+// of age and have consented to the credit check and data processing. The loan must end
+// before MaxAgeAtMaturity for at least one borrower, so a younger co-applicant can
+// carry a loan the primary party alone could not.
+func assessEligibility(app *LoanApplicationData, rules BusinessRules) Eligibility {
+	result := Eligibility{Score: eligibilityBaseScore, Reasons: []string{}}
+	if app.CustomerErasedAt != nil {
+		result.Score = 0
+		result.Reasons = append(result.Reasons, "customer data was erased")
+		return result
+	}
+
+	maturity := app.CreatedAt.AddDate(0, app.ProposedLoan.Tenure, 0)
+	repaidInTime, primeAge := false, false
+	coApplicant, guarantor := false, false
+	for _, party := range app.allParties() {
+		label := partyLabel(party)
+		age, ok := ageOn(party.Customer.DateOfBirth, app.CreatedAt)
+		switch {
+		case !ok:
+			result.Reasons = append(result.Reasons, fmt.Sprintf("%s has no valid date of birth", label))
+		case age < rules.MinApplicantAge:
+			result.Reasons = append(result.Reasons, fmt.Sprintf("%s is younger than %d", label, rules.MinApplicantAge))
+		}
+		if !party.Consent.complete() {
+			result.Reasons = append(result.Reasons, fmt.Sprintf("%s has not consented to the credit check and data processing", label))
+		}
+		switch party.Role {
+		case PartyRoleCoApplicant:
+			coApplicant = true
+		case PartyRoleGuarantor:
+			guarantor = true
+		}
+		if !ok || !isBorrower(party.Role) {
+			continue
+		}
+		if ageAtMaturity, _ := ageOn(party.Customer.DateOfBirth, maturity); ageAtMaturity <= rules.MaxAgeAtMaturity {
+			repaidInTime = true
+		}
+		if age >= primeAgeMin && age <= primeAgeMax {
+			primeAge = true
+		}
+	}
+	if ltv, ok := app.loanToValue(); !ok {
+		result.Reasons = append(result.Reasons, "the collateral has no valuation")
+	} else if ltv > rules.MaxLoanToValue {
+		result.Reasons = append(result.Reasons, fmt.Sprintf("the loan is %.1f%% of the collateral value, more than %g%%", ltv, rules.MaxLoanToValue))
+	}
+	affordability := assessAffordability(app, rules)
+	switch {
+	case !affordability.Known:
+		result.Reasons = append(result.Reasons, "no borrower has provided income and obligations")
+	case affordability.exceeded() && affordability.Action == DebtToIncomeBlock:
+		result.Reasons = append(result.Reasons, fmt.Sprintf("the debt-to-income ratio is above %g%%", rules.MaxDebtToIncome))
+	case affordability.exceeded():
+		result.Score += scoreFlaggedDTI
+	case affordability.DebtToIncome <= comfortableDTI:
+		result.Score += scoreComfortableDTI
+	}
+	if !repaidInTime {
+		result.Reasons = append(result.Reasons, fmt.Sprintf("every borrower would be older than %d when the loan ends", rules.MaxAgeAtMaturity))
+	}
+
+	if coApplicant {
+		result.Score += scoreCoApplicant
+	}
+	if guarantor {
+		result.Score += scoreGuarantor
+	}
+	if primeAge {
+		result.Score += scoreBorrowerPrimeAge
+	}
+	if app.documentsComplete() {
+		result.Score += scoreDocumentsComplete
+	}
+	result.Score = max(0, min(result.Score, 100))
+	result.Eligible = len(result.Reasons) == 0
+	return result
+}
+
+// businessRulesOf returns the business rules of the request's tenant.
+func businessRulesOf(p graphql.ResolveParams) BusinessRules {
+	if tenant, ok := TenantFromContext(p.Context); ok {
+		return tenant.BusinessRules
+	}
+	return defaultBusinessRules
+}
+
+var eligibilityResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	app, ok := p.Source.(*LoanApplicationData)
+	if !ok {
+		return nil, nil
+	}
+	return assessEligibility(app, businessRulesOf(p)), nil
+}
@@ -0,0 +1,151 @@
+package graphqlhandler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error codes returned in the "extensions.code" field of GraphQL errors.
+// Clients should branch on these codes rather than on the message text.
+const (
+	ErrCodeUnauthenticated = "UNAUTHENTICATED"
+	ErrCodeForbidden       = "FORBIDDEN"
+	ErrCodeNotFound        = "NOT_FOUND"
+	ErrCodeTenantRequired  = "TENANT_REQUIRED"
+	ErrCodeUnknownTenant   = "UNKNOWN_TENANT"
+	ErrCodeBadRequest      = "BAD_REQUEST"
+	ErrCodeConflict        = "CONFLICT"
+
+	ErrCodeIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+)
+
+// apiError is an error carrying a stable machine readable code.
+// graphql-go copies Extensions() into the formatted GraphQL error.
+type apiError struct {
+	Code    string
+	Message string
+	// MessageCode and Args select a localized message from the catalogs; errors
+	// without a MessageCode are reported in English in every locale.
+	MessageCode string
+	Args        []interface{}
+}
+
+// newLocalizedAPIError returns an apiError whose message is looked up in the catalogs.
+func newLocalizedAPIError(code, messageCode string, args ...interface{}) *apiError {
+	return &apiError{Code: code, Message: catalogMessage(LocaleEnglish, messageCode, args), MessageCode: messageCode, Args: args}
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+func (e *apiError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+// applicationNotFound is returned both for unknown UUIDs and for applications the
+// caller may not access, so that UUIDs cannot be enumerated.
+func applicationNotFound(appUUID string) error {
+	return newLocalizedAPIError(ErrCodeNotFound, MsgApplicationNotFound, appUUID)
+}
+
+// validationError reports invalid input. Code selects the message in the catalogs and
+// is returned as extensions.validation_code; Path leads from the mutation argument to
+// the input object holding Field, e.g. ["customer", "address"] and "zipcode".
+type validationError struct {
+	Code  string
+	Path  []string
+	Field string
+	Args  []interface{}
+}
+
+// invalidInput returns a validationError about field, which may be empty when the
+// error concerns the input object as a whole.
+func invalidInput(field, code string, args ...interface{}) error {
+	return &validationError{Code: code, Field: field, Args: args}
+}
+
+// invalidField places err inside the input object field. Validation errors keep their
+// code; other errors are wrapped as "invalid <field>: ...".
+func invalidField(field string, err error) error {
+	if v, ok := err.(*validationError); ok {
+		wrapped := *v
+		wrapped.Path = append([]string{field}, v.Path...)
+		return &wrapped
+	}
+	return fmt.Errorf("invalid %s: %w", field, err)
+}
+
+func (e *validationError) Error() string {
+	return e.message(LocaleEnglish)
+}
+
+// message renders the error in a locale, prefixed with the input object path.
+func (e *validationError) message(locale string) string {
+	message := catalogMessage(locale, e.Code, e.Args)
+	if len(e.Path) == 0 {
+		return message
+	}
+	return catalogMessage(locale, MsgInvalidField, []interface{}{strings.Join(e.Path, "."), message})
+}
+
+func (e *validationError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": ErrCodeBadRequest, "validation_code": e.Code}
+	field := strings.Join(e.Path, ".")
+	if field != "" && e.Field != "" {
+		field += "."
+	}
+	if field += e.Field; field != "" {
+		extensions["field"] = field
+	}
+	return extensions
+}
+
+// localizedError is an error rendered for the locale of a request.
+type localizedError struct {
+	message    string
+	extensions map[string]interface{}
+	err        error
+}
+
+func (e *localizedError) Error() string                      { return e.message }
+func (e *localizedError) Extensions() map[string]interface{} { return e.extensions }
+func (e *localizedError) Unwrap() error                      { return e.err }
+
+// localizeError renders validation errors and catalogued API errors in the locale of
+// the request. Other errors, including wrapped ones, are returned unchanged.
+func localizeError(ctx context.Context, err error) error {
+	locale := localeFromContext(ctx)
+	if locale == LocaleEnglish {
+		return err
+	}
+	switch e := err.(type) {
+	case *validationError:
+		return &localizedError{message: e.message(locale), extensions: e.Extensions(), err: err}
+	case *apiError:
+		if e.MessageCode != "" {
+			return &localizedError{message: catalogMessage(locale, e.MessageCode, e.Args), extensions: e.Extensions(), err: err}
+		}
+	}
+	return err
+}
+
+// writeHTTPError answers a request rejected by middleware with a GraphQL shaped error
+// body in the locale the request asks for. Middleware may run before LocaleMiddleware,
+// so the locale is negotiated from the request itself.
+func writeHTTPError(w http.ResponseWriter, r *http.Request, status int, apiErr *apiError) {
+	message := apiErr.Message
+	if apiErr.MessageCode != "" {
+		message = catalogMessage(requestLocale(r), apiErr.MessageCode, apiErr.Args)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]interface{}{
+			{"message": message, "extensions": apiErr.Extensions()},
+		},
+	})
+}
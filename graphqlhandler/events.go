@@ -0,0 +1,166 @@
+package graphqlhandler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoanApplicationEvent is published whenever a loan application enters a new status,
+// including creation as a DRAFT. It carries no customer data.
+type LoanApplicationEvent struct {
+	ID              string    `json:"id"`
+	TenantID        string    `json:"tenant_id"`
+	ApplicationUUID string    `json:"application_uuid"`
+	PreviousStatus  string    `json:"previous_status,omitempty"` // Empty for newly created applications
+	Status          string    `json:"status"`
+	Version         int       `json:"version"`
+	ChangedBy       string    `json:"changed_by"`
+	ChangedAt       time.Time `json:"changed_at"`
+
+	OwnerID string `json:"-"` // Used to filter events for customer subscribers
+}
+
+// subscriberBufferSize bounds how far a subscriber may fall behind before events are dropped.
+const subscriberBufferSize = 64
+
+type eventSubscriber struct {
+	events chan *LoanApplicationEvent
+	filter func(*LoanApplicationEvent) bool
+}
+
+// eventBus fans out status events to in-process subscribers. Publishing never blocks:
+// a subscriber whose buffer is full misses the event.
+type eventBus struct {
+	mu          sync.RWMutex
+	nextID      int
+	subscribers map[int]*eventSubscriber
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: make(map[int]*eventSubscriber)}
+}
+
+// statusEvents receives every status transition of every tenant.
+var statusEvents = newEventBus()
+
+// subscribe returns a channel of the events accepted by filter and a function that
+// unsubscribes and closes the channel.
+func (b *eventBus) subscribe(filter func(*LoanApplicationEvent) bool) (<-chan *LoanApplicationEvent, func()) {
+	sub := &eventSubscriber{events: make(chan *LoanApplicationEvent, subscriberBufferSize), filter: filter}
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = sub
+	b.mu.Unlock()
+
+	var once sync.Once
+	return sub.events, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, id)
+			close(sub.events)
+			b.mu.Unlock()
+		})
+	}
+}
+
+func (b *eventBus) publish(event *LoanApplicationEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, sub := range b.subscribers {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			slog.Warn("dropping event for slow subscriber", "application_uuid", event.ApplicationUUID, "status", event.Status)
+		}
+	}
+}
+
+// subscribeStatusEvents adapts the bus to graphql-go subscriptions, which expect a
+// chan interface{}. The subscription ends when ctx is cancelled.
+func subscribeStatusEvents(ctx context.Context, filter func(*LoanApplicationEvent) bool) chan interface{} {
+	events, unsubscribe := statusEvents.subscribe(filter)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// publishingStore publishes a status event for every stored status change, so that
+// no code path can change a status without subscribers noticing.
+type publishingStore struct {
+	LoanApplicationStore
+	bus *eventBus
+}
+
+func newPublishingStore(inner LoanApplicationStore, bus *eventBus) *publishingStore {
+	return &publishingStore{LoanApplicationStore: inner, bus: bus}
+}
+
+func (s *publishingStore) Unwrap() LoanApplicationStore { return s.LoanApplicationStore }
+
+func (s *publishingStore) Create(app *LoanApplicationData) error {
+	if err := s.LoanApplicationStore.Create(app); err != nil {
+		return err
+	}
+	s.bus.publish(&LoanApplicationEvent{
+		ID:              uuid.New().String(),
+		TenantID:        app.TenantID,
+		ApplicationUUID: app.UUID,
+		Status:          app.Status,
+		Version:         1,
+		ChangedBy:       app.CreatedBy,
+		ChangedAt:       app.CreatedAt,
+		OwnerID:         app.OwnerID,
+	})
+	return nil
+}
+
+func (s *publishingStore) Update(appUUID string, expectedVersion int, mutate func(app *LoanApplicationData) error) (*LoanApplicationData, error) {
+	var previous string
+	updated, err := s.LoanApplicationStore.Update(appUUID, expectedVersion, func(app *LoanApplicationData) error {
+		previous = app.Status
+		return mutate(app)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if updated.Status != previous {
+		s.bus.publish(&LoanApplicationEvent{
+			ID:              uuid.New().String(),
+			TenantID:        updated.TenantID,
+			ApplicationUUID: updated.UUID,
+			PreviousStatus:  previous,
+			Status:          updated.Status,
+			Version:         updated.Version,
+			ChangedBy:       updated.UpdatedBy,
+			ChangedAt:       updated.UpdatedAt,
+			OwnerID:         updated.OwnerID,
+		})
+	}
+	return updated, nil
+}
@@ -0,0 +1,479 @@
+package graphqlhandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Storage modes, selected with SetStorageMode.
+const (
+	StorageModeMemory       = "memory"        // applications are mutated in place
+	StorageModeEventSourced = "event-sourced" // applications are rebuilt from an append-only event log
+)
+
+// Types of the entries in the application event log.
+const (
+	logEventApplicationCreated  = "ApplicationCreated"
+	logEventStatusChanged       = "StatusChanged"
+	logEventCustomerDataErased  = "CustomerDataErased"
+	logEventCustomerDataChanged = "CustomerDataChanged"
+	logEventApplicationUpdated  = "ApplicationUpdated"
+	defaultSnapshotEvery        = 20
+)
+
+// applicationEvent is one entry of the append-only log. Changes holds the new JSON
+// value of every top level LoanApplicationData field the event changed (null when a
+// field was cleared), so replaying the events of an application in order rebuilds it.
+type applicationEvent struct {
+	Sequence        int64                      `json:"sequence"`
+	ApplicationUUID string                     `json:"application_uuid"`
+	Version         int                        `json:"version"`
+	Type            string                     `json:"type"`
+	RecordedAt      time.Time                  `json:"recorded_at"`
+	Actor           string                     `json:"actor"`
+	Changes         map[string]json.RawMessage `json:"changes"`
+}
+
+// applicationSnapshot is the state of an application after the event with Version.
+type applicationSnapshot struct {
+	Version    int
+	RecordedAt time.Time
+	State      *LoanApplicationData
+}
+
+// eventSourcedStore keeps every change of an application as an event. The current state
+// of every application is a projection of the log, used by Get, List and
+// FindByIDNumberIndex; it can be rebuilt from the log at any time. Snapshots taken every
+// snapshotEvery versions bound the number of events replayed for point-in-time reads.
+//
+// The log is append-only with one exception: erasing a customer's data also redacts
+// the customer from the earlier events and snapshots of that application.
+type eventSourcedStore struct {
+	mu            sync.RWMutex
+	sequence      int64
+	streams       map[string][]*applicationEvent // per application, in version order
+	snapshots     map[string][]applicationSnapshot
+	projection    map[string]*LoanApplicationData
+	snapshotEvery int
+	outboxLog     // written while mu is held, so events commit together with their change
+}
+
+func newEventSourcedStore() *eventSourcedStore {
+	return &eventSourcedStore{
+		streams:       make(map[string][]*applicationEvent),
+		snapshots:     make(map[string][]applicationSnapshot),
+		projection:    make(map[string]*LoanApplicationData),
+		snapshotEvery: defaultSnapshotEvery,
+	}
+}
+
+// applicationFields splits an application into its top level JSON fields.
+func applicationFields(app *LoanApplicationData) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if app == nil {
+		return fields, nil
+	}
+	raw, err := json.Marshal(app)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// diffApplications returns the fields of after that differ from before.
+func diffApplications(before, after *LoanApplicationData) (map[string]json.RawMessage, error) {
+	old, err := applicationFields(before)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := applicationFields(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]json.RawMessage{}
+	for key, value := range updated {
+		if !bytes.Equal(old[key], value) {
+			changes[key] = value
+		}
+	}
+	for key := range old {
+		if _, ok := updated[key]; !ok {
+			changes[key] = json.RawMessage("null")
+		}
+	}
+	return changes, nil
+}
+
+// applyEvent returns a new state with the changes of event applied to state (which may be nil).
+func applyEvent(state *LoanApplicationData, event *applicationEvent) (*LoanApplicationData, error) {
+	fields, err := applicationFields(state)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range event.Changes {
+		fields[key] = value
+	}
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	next := &LoanApplicationData{}
+	if err := json.Unmarshal(raw, next); err != nil {
+		return nil, fmt.Errorf("apply event %d of '%s': %w", event.Version, event.ApplicationUUID, err)
+	}
+	return next, nil
+}
+
+func classifyChange(before, after *LoanApplicationData, changes map[string]json.RawMessage) string {
+	switch {
+	case before.CustomerErasedAt == nil && after.CustomerErasedAt != nil, len(erasedPartyIDs(before, after)) > 0:
+		return logEventCustomerDataErased
+	case before.Status != after.Status:
+		return logEventStatusChanged
+	case changes["customer"] != nil || changes["parties"] != nil:
+		return logEventCustomerDataChanged
+	}
+	return logEventApplicationUpdated
+}
+
+// append records an event and advances the projection; the caller holds the write lock.
+func (s *eventSourcedStore) append(current *LoanApplicationData, eventType, actor string, changes map[string]json.RawMessage, version int, appUUID string) (*LoanApplicationData, error) {
+	event := &applicationEvent{
+		Sequence:        s.sequence + 1,
+		ApplicationUUID: appUUID,
+		Version:         version,
+		Type:            eventType,
+		RecordedAt:      time.Now(),
+		Actor:           actor,
+		Changes:         changes,
+	}
+	state, err := applyEvent(current, event)
+	if err != nil {
+		return nil, err
+	}
+	s.sequence = event.Sequence
+	s.streams[appUUID] = append(s.streams[appUUID], event)
+	s.projection[appUUID] = state
+	if s.snapshotEvery > 0 && version%s.snapshotEvery == 0 {
+		s.snapshots[appUUID] = append(s.snapshots[appUUID], applicationSnapshot{Version: version, RecordedAt: event.RecordedAt, State: state.clone()})
+	}
+	return state, nil
+}
+
+func (s *eventSourcedStore) Get(uuid string) (*LoanApplicationData, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	app, ok := s.projection[uuid]
+	if !ok {
+		return nil, false
+	}
+	return app.clone(), true
+}
+
+func (s *eventSourcedStore) Create(app *LoanApplicationData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.streams[app.UUID]; exists {
+		return fmt.Errorf("loan application with UUID '%s' already exists", app.UUID)
+	}
+	initial := app.clone()
+	initial.Version = 1
+	changes, err := diffApplications(nil, initial)
+	if err != nil {
+		return err
+	}
+	state, err := s.append(nil, logEventApplicationCreated, initial.CreatedBy, changes, 1, app.UUID)
+	if err != nil {
+		return err
+	}
+	s.record(newOutboxEvent("", state, state.CreatedBy, state.CreatedAt))
+	return nil
+}
+
+func (s *eventSourcedStore) Update(uuid string, expectedVersion int, mutate func(app *LoanApplicationData) error) (*LoanApplicationData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.projection[uuid]
+	if !ok {
+		return nil, errApplicationNotFound
+	}
+	if expectedVersion != 0 && current.Version != expectedVersion {
+		return nil, errVersionConflict
+	}
+	updated := current.clone()
+	if err := mutate(updated); err != nil {
+		return nil, err
+	}
+	updated.Version = current.Version + 1
+	changes, err := diffApplications(current, updated)
+	if err != nil {
+		return nil, err
+	}
+	eventType := classifyChange(current, updated, changes)
+	state, err := s.append(current, eventType, updated.UpdatedBy, changes, updated.Version, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if eventType == logEventCustomerDataErased {
+		s.redactCustomer(uuid, state, erasedPartyIDs(current, state))
+	}
+	if state.Status != current.Status {
+		s.record(newOutboxEvent(current.Status, state, state.UpdatedBy, state.UpdatedAt))
+	}
+	return state.clone(), nil
+}
+
+// redactCustomer replaces the erased parties recorded in earlier events and snapshots of
+// an application with their erased form, so erasure also reaches the history. When the
+// primary party is among them, the uploaded documents are dropped and the client address
+// of the terms acceptance is blanked as well.
+func (s *eventSourcedStore) redactCustomer(uuid string, erased *LoanApplicationData, partyIDs map[string]bool) {
+	primary := partyIDs[uuid]
+	redactParties := func(parties []PartyData) {
+		for i := range parties {
+			if partyIDs[parties[i].ID] {
+				parties[i].Customer = CustomerData{FullName: erasedName}
+			}
+		}
+	}
+	customer, err := json.Marshal(erased.Customer)
+	if err != nil {
+		return
+	}
+	acceptance, err := json.Marshal(erased.TermsAcceptance)
+	if err != nil {
+		return
+	}
+	for _, event := range s.streams[uuid] {
+		if raw, ok := event.Changes["parties"]; ok {
+			var parties []PartyData
+			if err := json.Unmarshal(raw, &parties); err == nil {
+				redactParties(parties)
+				if redacted, err := json.Marshal(parties); err == nil {
+					event.Changes["parties"] = redacted
+				}
+			}
+		}
+		if !primary {
+			continue
+		}
+		if _, ok := event.Changes["customer"]; ok {
+			event.Changes["customer"] = customer
+		}
+		if _, ok := event.Changes["documents"]; ok {
+			event.Changes["documents"] = json.RawMessage("null")
+		}
+		if _, ok := event.Changes["terms_acceptance"]; ok {
+			event.Changes["terms_acceptance"] = acceptance
+		}
+	}
+	for i := range s.snapshots[uuid] {
+		state := s.snapshots[uuid][i].State
+		redactParties(state.Parties)
+		if !primary {
+			continue
+		}
+		state.Customer = erased.Customer
+		state.Documents = nil
+		if state.TermsAcceptance != nil {
+			state.TermsAcceptance.IPAddress = ""
+			state.TermsAcceptance.UserAgent = ""
+		}
+	}
+}
+
+func (s *eventSourcedStore) List() []*LoanApplicationData {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	apps := make([]*LoanApplicationData, 0, len(s.projection))
+	for _, app := range s.projection {
+		apps = append(apps, app.clone())
+	}
+	sortNewestFirst(apps)
+	return apps
+}
+
+func (s *eventSourcedStore) FindByIDNumberIndex(index string) []*LoanApplicationData {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var apps []*LoanApplicationData
+	for _, app := range s.projection {
+		if app.hasIDNumberIndex(index) {
+			apps = append(apps, app.clone())
+		}
+	}
+	sortNewestFirst(apps)
+	return apps
+}
+
+// GetAsOf rebuilds the application as it was at the given time, starting from the
+// latest snapshot taken before it.
+func (s *eventSourcedStore) GetAsOf(uuid string, at time.Time) (*LoanApplicationData, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var state *LoanApplicationData
+	from := 0
+	snapshots := s.snapshots[uuid]
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if !snapshots[i].RecordedAt.After(at) {
+			state = snapshots[i].State.clone()
+			from = snapshots[i].Version
+			break
+		}
+	}
+	for _, event := range s.streams[uuid] {
+		if event.Version <= from {
+			continue
+		}
+		if event.RecordedAt.After(at) {
+			break
+		}
+		next, err := applyEvent(state, event)
+		if err != nil {
+			return nil, false, err
+		}
+		state = next
+	}
+	return state, state != nil, nil
+}
+
+// RebuildProjections replays the whole log, ignoring snapshots, and replaces the
+// projection used by Get and List. It returns the number of applications rebuilt.
+func (s *eventSourcedStore) RebuildProjections() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	projection := make(map[string]*LoanApplicationData, len(s.streams))
+	for uuid, events := range s.streams {
+		var state *LoanApplicationData
+		for _, event := range events {
+			next, err := applyEvent(state, event)
+			if err != nil {
+				return 0, err
+			}
+			state = next
+		}
+		projection[uuid] = state
+	}
+	s.projection = projection
+	return len(projection), nil
+}
+
+// encryptedFieldsOfEvents are the fields of an event that may hold key envelopes.
+var encryptedFieldsOfEvents = []string{"customer", "parties", "documents"}
+
+// rewrapEnvelopes is the second exception to the append-only log: key envelopes are
+// replaced in the projection, the snapshots and the events of every application, so past
+// states stay readable once an old key is removed. No event is appended and no version
+// changes.
+func (s *eventSourcedStore) rewrapEnvelopes(rewrap func(app *LoanApplicationData) (bool, error)) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for uuid, events := range s.streams {
+		changed, err := s.rewrapStream(uuid, events, rewrap)
+		if err != nil {
+			return count, fmt.Errorf("loan application '%s': %w", uuid, err)
+		}
+		if changed {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// rewrapStream rewraps one application; the caller holds the write lock.
+func (s *eventSourcedStore) rewrapStream(uuid string, events []*applicationEvent, rewrap func(app *LoanApplicationData) (bool, error)) (bool, error) {
+	changed := false
+	for _, event := range events {
+		// Decode only the fields that may hold envelopes and write back those that changed.
+		partial := map[string]json.RawMessage{}
+		for _, key := range encryptedFieldsOfEvents {
+			if raw, ok := event.Changes[key]; ok {
+				partial[key] = raw
+			}
+		}
+		if len(partial) == 0 {
+			continue
+		}
+		raw, err := json.Marshal(partial)
+		if err != nil {
+			return changed, err
+		}
+		state := &LoanApplicationData{UUID: uuid}
+		if err := json.Unmarshal(raw, state); err != nil {
+			return changed, fmt.Errorf("event %d: %w", event.Version, err)
+		}
+		rewrapped, err := rewrap(state)
+		if err != nil {
+			return changed, fmt.Errorf("event %d: %w", event.Version, err)
+		}
+		if !rewrapped {
+			continue
+		}
+		fields, err := applicationFields(state)
+		if err != nil {
+			return changed, err
+		}
+		for key := range partial {
+			if value, ok := fields[key]; ok {
+				event.Changes[key] = value
+			} else {
+				event.Changes[key] = json.RawMessage("null")
+			}
+		}
+		changed = true
+	}
+	for i := range s.snapshots[uuid] {
+		state := s.snapshots[uuid][i].State.clone()
+		rewrapped, err := rewrap(state)
+		if err != nil {
+			return changed, fmt.Errorf("snapshot %d: %w", s.snapshots[uuid][i].Version, err)
+		}
+		if rewrapped {
+			s.snapshots[uuid][i].State = state
+			changed = true
+		}
+	}
+	if current, ok := s.projection[uuid]; ok {
+		state := current.clone()
+		rewrapped, err := rewrap(state)
+		if err != nil {
+			return changed, err
+		}
+		if rewrapped {
+			s.projection[uuid] = state
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+// historicalStore is implemented by stores that can reconstruct past states.
+type historicalStore interface {
+	GetAsOf(uuid string, at time.Time) (*LoanApplicationData, bool, error)
+}
+
+// projectionStore is implemented by stores that serve reads from a rebuildable projection.
+type projectionStore interface {
+	RebuildProjections() (int, error)
+}
+
+// errNoHistory is returned for point-in-time reads on stores without an event log.
+var errNoHistory = newLocalizedAPIError(ErrCodeBadRequest, MsgStorageModeRequired, "asOf", StorageModeEventSourced)
+
+// backendStore returns the store at the bottom of a chain of decorators.
+func backendStore(store LoanApplicationStore) LoanApplicationStore {
+	for {
+		wrapper, ok := store.(interface{ Unwrap() LoanApplicationStore })
+		if !ok {
+			return store
+		}
+		store = wrapper.Unwrap()
+	}
+}
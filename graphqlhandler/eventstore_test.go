@@ -0,0 +1,88 @@
+package graphqlhandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func TestEventSourcedStoreReplay(t *testing.T) {
+	store := newEventSourcedStore()
+	const uuid = "6f1c2b9e-0000-4000-8000-000000000001"
+	created := time.Now()
+	err := store.Create(&LoanApplicationData{
+		UUID:         uuid,
+		Status:       "DRAFT",
+		ProposedLoan: ProposedLoanData{Tenure: 12, Amount: 1000},
+		Customer:     CustomerData{FullName: "John Doe", IDNumber: "ID600000001", IDNumberIndex: blindIndex("ID600000001")},
+		CreatedAt:    created,
+		UpdatedAt:    created,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// states[v] is the application as of recordedAt[v], right after version v was stored.
+	states := map[int]string{}
+	recordedAt := map[int]time.Time{}
+	app, _ := store.Get(uuid)
+	states[1], recordedAt[1] = mustJSON(t, app), time.Now()
+
+	const updates = 2*defaultSnapshotEvery + 5
+	for i := 0; i < updates; i++ {
+		app, err := store.Update(uuid, 0, func(app *LoanApplicationData) error {
+			app.ProposedLoan.Amount += 100
+			if i%7 == 0 {
+				app.Customer.Phone = fmt.Sprintf("+62812000%04d", i)
+			}
+			if i == defaultSnapshotEvery {
+				app.Status = "SUBMITTED"
+			}
+			app.UpdatedAt = time.Now()
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		states[app.Version], recordedAt[app.Version] = mustJSON(t, app), time.Now()
+	}
+	live, _ := store.Get(uuid)
+	if live.Version != updates+1 {
+		t.Fatalf("version %d, want %d", live.Version, updates+1)
+	}
+	if got := len(store.snapshots[uuid]); got != 2 {
+		t.Fatalf("%d snapshots, want 2", got)
+	}
+
+	if n, err := store.RebuildProjections(); err != nil || n != 1 {
+		t.Fatalf("RebuildProjections = %d, %v", n, err)
+	}
+	rebuilt, _ := store.Get(uuid)
+	if mustJSON(t, rebuilt) != mustJSON(t, live) {
+		t.Fatalf("rebuilt projection differs:\n%s\nlive:\n%s", mustJSON(t, rebuilt), mustJSON(t, live))
+	}
+
+	// Every version, including those just before, at and after a snapshot.
+	for version := 1; version <= live.Version; version++ {
+		past, found, err := store.GetAsOf(uuid, recordedAt[version])
+		if err != nil || !found {
+			t.Fatalf("GetAsOf(version %d): found %v, %v", version, found, err)
+		}
+		if got := mustJSON(t, past); got != states[version] {
+			t.Fatalf("GetAsOf(version %d):\n%s\nwant:\n%s", version, got, states[version])
+		}
+	}
+	if _, found, _ := store.GetAsOf(uuid, created.Add(-time.Second)); found {
+		t.Fatal("application found before it was created")
+	}
+}
@@ -0,0 +1,129 @@
+package graphqlhandler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+// useTenants replaces the tenant registry with fresh in-memory tenants for one test.
+func useTenants(t *testing.T, ids ...string) {
+	t.Helper()
+	previous := tenants
+	configs := make([]TenantConfig, 0, len(ids))
+	for _, id := range ids {
+		configs = append(configs, TenantConfig{ID: id, Name: id})
+	}
+	tenants = newTenantRegistry(configs, "")
+	t.Cleanup(func() { tenants = previous })
+}
+
+// requestContext builds the context the HTTP middleware chain gives resolvers for a
+// principal that requested a tenant with X-Tenant-ID.
+func requestContext(t *testing.T, principal *Principal, requestedTenant string) context.Context {
+	t.Helper()
+	ctx := WithPrincipal(context.Background(), principal)
+	cfg, apiErr := resolveTenant(principal, requestedTenant)
+	if apiErr != nil {
+		t.Fatalf("resolve tenant: %v", apiErr)
+	}
+	if cfg != nil {
+		ctx = context.WithValue(ctx, tenantKey{}, cfg)
+	}
+	return ctx
+}
+
+// agentOf returns a sales agent bound to a tenant.
+func agentOf(tenant string) *Principal {
+	return &Principal{UserID: "agent-" + tenant, Roles: []string{RoleSalesAgent}, TenantID: tenant}
+}
+
+func execute(ctx context.Context, query string, variables map[string]interface{}) *graphql.Result {
+	return graphql.Do(graphql.Params{Schema: Schema, RequestString: query, VariableValues: variables, Context: ctx})
+}
+
+// mustExecute runs an operation that must succeed.
+func mustExecute(t *testing.T, ctx context.Context, query string, variables map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	result := execute(ctx, query, variables)
+	if result.HasErrors() {
+		t.Fatalf("%s: %v", query, result.Errors)
+	}
+	return result.Data.(map[string]interface{})
+}
+
+// errorCode returns the extension code of the first error of a result.
+func errorCode(result *graphql.Result) string {
+	if len(result.Errors) == 0 {
+		return ""
+	}
+	code, _ := result.Errors[0].Extensions["code"].(string)
+	return code
+}
+
+// testDraft returns draft input that passes validation for a customer.
+func testDraft(idNumber string) map[string]interface{} {
+	return map[string]interface{}{
+		"proposed_loan": map[string]interface{}{"tenure": 12, "amount": 5000.0},
+		"collateral": map[string]interface{}{
+			"category": "CAR", "brand": "Toyota", "model": "Camry", "variant": "2.5 V", "manufacturing_year": 2021,
+		},
+		"customer": map[string]interface{}{
+			"full_name":     "John Doe",
+			"date_of_birth": "1990-01-15",
+			"id_number":     idNumber,
+			"email":         "john@example.com",
+			"phone":         "1234567890",
+			"address":       map[string]interface{}{"street": "1 Main Street", "city": "Springfield", "zipcode": "12345"},
+		},
+	}
+}
+
+const createDraftMutation = `mutation($d: LoanApplicationDraftInput!) { createLoanApplicationDraft(data: $d) }`
+
+// createDraft creates a draft and returns its UUID.
+func createDraft(t *testing.T, ctx context.Context, draft map[string]interface{}) string {
+	t.Helper()
+	data := mustExecute(t, ctx, createDraftMutation, map[string]interface{}{"d": draft})
+	return data["createLoanApplicationDraft"].(string)
+}
+
+// useEventSourcedTenants is useTenants with event-sourced backends, which keep history.
+func useEventSourcedTenants(t *testing.T, ids ...string) {
+	t.Helper()
+	previous := newTenantBackend
+	newTenantBackend = func() LoanApplicationStore { return newEventSourcedStore() }
+	t.Cleanup(func() { newTenantBackend = previous })
+	useTenants(t, ids...)
+}
+
+// adminOf returns an administrator bound to a tenant.
+func adminOf(tenant string) *Principal {
+	return &Principal{UserID: "admin-" + tenant, Roles: []string{RoleAdmin}, TenantID: tenant}
+}
+
+// testJointDraft returns draft input with the testDraft customer as PRIMARY party and a
+// co-applicant.
+func testJointDraft(primaryIDNumber, coApplicantIDNumber string) map[string]interface{} {
+	draft := testDraft(primaryIDNumber)
+	primary := draft["customer"].(map[string]interface{})
+	delete(draft, "customer")
+	coApplicant := testDraft(coApplicantIDNumber)["customer"].(map[string]interface{})
+	coApplicant["full_name"] = "Jane Roe"
+	coApplicant["email"] = "jane@example.com"
+	consent := map[string]interface{}{"credit_check": true, "data_processing": true}
+	draft["parties"] = []interface{}{
+		map[string]interface{}{"role": PartyRolePrimary, "customer": primary, "consent": consent},
+		map[string]interface{}{"role": PartyRoleCoApplicant, "customer": coApplicant, "consent": consent},
+	}
+	return draft
+}
+
+// useTempBlobs stores uploaded documents in a temporary directory for one test.
+func useTempBlobs(t *testing.T) {
+	t.Helper()
+	previous := documentBlobs
+	documentBlobs = NewFileBlobStore(t.TempDir())
+	t.Cleanup(func() { documentBlobs = previous })
+}
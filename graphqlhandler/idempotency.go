@@ -0,0 +1,234 @@
+package graphqlhandler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// IdempotencyKeyHeader lets clients retry mutations safely.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// clientMutationIDArg is the per-mutation alternative to the Idempotency-Key header.
+const clientMutationIDArg = "clientMutationId"
+
+const maxIdempotencyKeyLength = 255
+
+// idempotencyEntry remembers the outcome of one keyed mutation.
+type idempotencyEntry struct {
+	fingerprint string
+	expiresAt   time.Time
+	done        chan struct{} // closed once the mutation finished
+	succeeded   bool
+	result      interface{}
+}
+
+// idempotencyCache holds keyed mutation results until they expire.
+type idempotencyCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*idempotencyEntry
+}
+
+var idempotencyKeys = &idempotencyCache{ttl: 24 * time.Hour, entries: make(map[string]*idempotencyEntry)}
+
+// SetIdempotencyKeyTTL configures how long idempotency keys are remembered.
+func SetIdempotencyKeyTTL(ttl time.Duration) {
+	idempotencyKeys.mu.Lock()
+	defer idempotencyKeys.mu.Unlock()
+	idempotencyKeys.ttl = ttl
+}
+
+// begin registers a key. It returns the existing entry if the key was seen before
+// (and has not expired), or a new entry the caller must complete with finish or abort.
+func (c *idempotencyCache) begin(key, fingerprint string) (entry *idempotencyEntry, existing bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	if e, ok := c.entries[key]; ok {
+		return e, true
+	}
+	e := &idempotencyEntry{fingerprint: fingerprint, expiresAt: now.Add(c.ttl), done: make(chan struct{})}
+	c.entries[key] = e
+	return e, false
+}
+
+func (c *idempotencyCache) finish(entry *idempotencyEntry, result interface{}) {
+	entry.result = result
+	entry.succeeded = true
+	close(entry.done)
+}
+
+// abort forgets a key whose mutation failed so that the client can retry it.
+func (c *idempotencyCache) abort(key string, entry *idempotencyEntry) {
+	c.mu.Lock()
+	if c.entries[key] == entry {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	close(entry.done)
+}
+
+type idempotencyKeyCtxKey struct{}
+
+// IdempotencyKeyMiddleware makes the Idempotency-Key header available to mutation resolvers.
+func IdempotencyKeyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeHTTPError(w, r, http.StatusBadRequest, newLocalizedAPIError(ErrCodeBadRequest, MsgTooLong, IdempotencyKeyHeader, maxIdempotencyKeyLength))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), idempotencyKeyCtxKey{}, key)))
+	})
+}
+
+// idempotencyKey returns the key for a mutation call from clientMutationId or the header.
+func idempotencyKey(p graphql.ResolveParams) (string, error) {
+	argKey, _ := p.Args[clientMutationIDArg].(string)
+	headerKey, _ := p.Context.Value(idempotencyKeyCtxKey{}).(string)
+	if argKey != "" && headerKey != "" && argKey != headerKey {
+		return "", newLocalizedAPIError(ErrCodeBadRequest, MsgIdempotencyKeysDiffer, clientMutationIDArg, IdempotencyKeyHeader)
+	}
+	if len(argKey) > maxIdempotencyKeyLength {
+		return "", newLocalizedAPIError(ErrCodeBadRequest, MsgTooLong, clientMutationIDArg, maxIdempotencyKeyLength)
+	}
+	if argKey != "" {
+		return argKey, nil
+	}
+	return headerKey, nil
+}
+
+// payloadFingerprint hashes the mutation name and arguments. encoding/json sorts
+// map keys, so equal payloads always give equal fingerprints. An Upload argument only
+// names a part of the multipart request, so the SHA-256 of the file content is hashed
+// in its place.
+func payloadFingerprint(ctx context.Context, field string, args map[string]interface{}, uploadArgs []string) (string, error) {
+	payload := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		if k != clientMutationIDArg {
+			payload[k] = v
+		}
+	}
+	for _, name := range uploadArgs {
+		if payload[name] == nil {
+			continue
+		}
+		upload, err := uploadFromContext(ctx, payload[name])
+		if err != nil {
+			return "", err
+		}
+		digest, err := upload.digest()
+		if err != nil {
+			return "", err
+		}
+		payload[name] = "sha256:" + digest
+	}
+	raw, err := json.Marshal(map[string]interface{}{"field": field, "args": payload})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func isUploadType(t graphql.Input) bool {
+	if nonNull, ok := t.(*graphql.NonNull); ok {
+		t = nonNull.OfType
+	}
+	return t == uploadScalar
+}
+
+// idempotent wraps a mutation resolver. A repeated key with the same payload returns
+// the original result without running the mutation again; a different payload is
+// rejected. Failed mutations are not remembered. Keys are scoped to tenant and caller.
+func idempotent(field string, uploadArgs []string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		key, err := idempotencyKey(p)
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			return resolve(p)
+		}
+		fingerprint, err := payloadFingerprint(p.Context, field, p.Args, uploadArgs)
+		if err != nil {
+			return nil, err
+		}
+		scopedKey := tenantID(p.Context) + "\x00" + actorID(p.Context) + "\x00" + field + "\x00" + key
+
+		for {
+			entry, existing := idempotencyKeys.begin(scopedKey, fingerprint)
+			if !existing {
+				return runIdempotent(p, resolve, scopedKey, entry)
+			}
+			if entry.fingerprint != fingerprint {
+				return nil, newLocalizedAPIError(ErrCodeIdempotencyKeyReused, MsgIdempotencyKeyReused)
+			}
+			// Wait for a concurrent attempt with the same key to finish.
+			select {
+			case <-entry.done:
+			case <-p.Context.Done():
+				return nil, p.Context.Err()
+			}
+			if entry.succeeded {
+				return entry.result, nil
+			}
+			// The other attempt failed and was forgotten; run the mutation ourselves.
+		}
+	}
+}
+
+// runIdempotent executes the mutation for a newly registered key and records its outcome.
+// The deferred abort also releases waiting retries if the resolver panics.
+func runIdempotent(p graphql.ResolveParams, resolve graphql.FieldResolveFn, scopedKey string, entry *idempotencyEntry) (result interface{}, err error) {
+	finished := false
+	defer func() {
+		if !finished {
+			idempotencyKeys.abort(scopedKey, entry)
+		}
+	}()
+	result, err = resolve(p)
+	if err != nil {
+		return result, err
+	}
+	idempotencyKeys.finish(entry, result)
+	finished = true
+	return result, nil
+}
+
+// withIdempotency adds the clientMutationId argument to every mutation and wraps its resolver.
+func withIdempotency(fields graphql.Fields) graphql.Fields {
+	for name, field := range fields {
+		if field.Args == nil {
+			field.Args = graphql.FieldConfigArgument{}
+		}
+		field.Args[clientMutationIDArg] = &graphql.ArgumentConfig{
+			Type:        graphql.String,
+			Description: "Idempotency key, alternative to the Idempotency-Key header.",
+		}
+		var uploadArgs []string
+		for argName, arg := range field.Args {
+			if isUploadType(arg.Type) {
+				uploadArgs = append(uploadArgs, argName)
+			}
+		}
+		field.Resolve = idempotent(name, uploadArgs, field.Resolve)
+	}
+	return fields
+}
@@ -0,0 +1,102 @@
+package graphqlhandler
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// withUpload returns ctx carrying content as the multipart file part "0", the way
+// UploadMiddleware passes it on.
+func withUpload(t *testing.T, ctx context.Context, content []byte) context.Context {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("0", "id-card.pdf")
+	part.Write(content)
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/graphql", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { req.MultipartForm.RemoveAll() })
+	header := req.MultipartForm.File["0"][0]
+	upload := &Upload{FileName: header.Filename, Size: header.Size, header: header}
+	return context.WithValue(ctx, uploadsKey{}, map[string]*Upload{"0": upload})
+}
+
+func TestIdempotentDocumentUpload(t *testing.T) {
+	useTenants(t, "acme")
+	useTempBlobs(t)
+	ctx := requestContext(t, agentOf("acme"), "")
+	uuid := createDraft(t, ctx, testDraft("ID500000001"))
+
+	const upload = `mutation($u: ID!, $f: Upload!, $k: String) {
+		uploadLoanApplicationDocument(uuid: $u, type: ID_CARD, file: $f, clientMutationId: $k) { id }
+	}`
+	vars := map[string]interface{}{"u": uuid, "f": "0", "k": "upload-1"}
+	first := mustExecute(t, withUpload(t, ctx, []byte("%PDF-1.4 first")), upload, vars)
+	replay := mustExecute(t, withUpload(t, ctx, []byte("%PDF-1.4 first")), upload, vars)
+	if first["uploadLoanApplicationDocument"].(map[string]interface{})["id"] != replay["uploadLoanApplicationDocument"].(map[string]interface{})["id"] {
+		t.Fatalf("replay returned another document: %v, %v", first, replay)
+	}
+
+	// The file part has the same name, but the content differs.
+	result := execute(withUpload(t, ctx, []byte("%PDF-1.4 second")), upload, vars)
+	if code := errorCode(result); code != ErrCodeIdempotencyKeyReused {
+		t.Fatalf("other file with the same key: code %q, want %s", code, ErrCodeIdempotencyKeyReused)
+	}
+	if app, _ := tenants.store("acme").Get(uuid); len(app.Documents) != 1 {
+		t.Fatalf("%d documents stored, want 1", len(app.Documents))
+	}
+}
+
+func TestIdempotentConcurrentRequests(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	resolve := idempotent("testMutation", nil, func(p graphql.ResolveParams) (interface{}, error) {
+		calls.Add(1)
+		<-release
+		return p.Args["value"], nil
+	})
+	ctx := WithPrincipal(context.Background(), &Principal{UserID: "concurrent-caller"})
+	params := func(value int) graphql.ResolveParams {
+		return graphql.ResolveParams{Context: ctx, Args: map[string]interface{}{"value": value, clientMutationIDArg: "concurrent-1"}}
+	}
+
+	var wg sync.WaitGroup
+	results := make([]interface{}, 5)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = resolve(params(1))
+		}()
+	}
+	time.Sleep(50 * time.Millisecond) // let every request reach the key
+
+	// A different payload is rejected at once while the first request is in flight.
+	if _, err := resolve(params(2)); err == nil {
+		t.Fatal("different payload accepted while the key is in flight")
+	}
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("mutation ran %d times, want 1", calls.Load())
+	}
+	for i, result := range results {
+		if result != 1 {
+			t.Fatalf("request %d got %v, want the original result", i, result)
+		}
+	}
+}
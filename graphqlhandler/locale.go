@@ -0,0 +1,59 @@
+package graphqlhandler
+
+import (
+	"context"
+	"net/http"
+
+	"golang.org/x/text/language"
+)
+
+// Locales with a message catalog (see messages.go).
+const (
+	LocaleEnglish    = "en"
+	LocaleIndonesian = "id"
+)
+
+// LocaleParam is the URL query parameter that overrides Accept-Language.
+const LocaleParam = "locale"
+
+// supportedLocales are matched against the client's preferences; the first is the
+// fallback.
+var (
+	supportedLocales = []string{LocaleEnglish, LocaleIndonesian}
+	localeMatcher    = language.NewMatcher([]language.Tag{language.English, language.Indonesian})
+)
+
+type localeKey struct{}
+
+// WithLocale returns a context whose errors are reported in the given locale.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// localeFromContext returns the locale of the request, English by default.
+func localeFromContext(ctx context.Context) string {
+	if locale, ok := ctx.Value(localeKey{}).(string); ok {
+		return locale
+	}
+	return LocaleEnglish
+}
+
+// negotiateLocale picks the supported locale that best matches the given preferences,
+// each a language tag or an Accept-Language value, in order of precedence.
+func negotiateLocale(preferences ...string) string {
+	_, index := language.MatchStrings(localeMatcher, preferences...)
+	return supportedLocales[index]
+}
+
+// requestLocale picks the locale from the locale query parameter or, without it, the
+// Accept-Language header.
+func requestLocale(r *http.Request) string {
+	return negotiateLocale(r.URL.Query().Get(LocaleParam), r.Header.Get("Accept-Language"))
+}
+
+// LocaleMiddleware selects the locale of error messages with requestLocale.
+func LocaleMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(WithLocale(r.Context(), requestLocale(r))))
+	})
+}
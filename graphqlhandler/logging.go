@@ -0,0 +1,278 @@
+package graphqlhandler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// RequestIDHeader is the header used to accept and echo the correlation ID of a request.
+const RequestIDHeader = "X-Request-ID"
+
+const redactedValue = "[REDACTED]"
+
+// piiFields lists variable keys whose values must never reach the logs.
+// Keys are matched case-insensitively at any depth of the variables map.
+var piiFields = map[string]bool{
+	// Identity and contact details of every party.
+	"full_name":     true,
+	"id_number":     true,
+	"phone":         true,
+	"email":         true,
+	"date_of_birth": true,
+	"gender":        true,
+	// Address below city level and coordinates.
+	"street":       true,
+	"district":     true,
+	"sub_district": true,
+	"zipcode":      true,
+	"geo":          true,
+	"latitude":     true,
+	"longitude":    true,
+	// Financial data.
+	"employer":            true,
+	"monthly_income":      true,
+	"monthly_obligations": true,
+	"dependents":          true,
+	// Collateral identifiers that lead to the owner.
+	"plate_number":       true,
+	"chassis_number":     true,
+	"engine_number":      true,
+	"serial_number":      true,
+	"certificate_number": true,
+}
+
+type requestInfoKey struct{}
+
+// requestInfo carries per-request logging state through the context.
+// Resolvers add application UUIDs to it so the final log line can list them.
+type requestInfo struct {
+	ID        string
+	Started   time.Time
+	ClientIP  string // recorded with terms acceptances
+	UserAgent string
+
+	mu    sync.Mutex
+	uuids []string
+}
+
+func requestInfoFromContext(ctx context.Context) *requestInfo {
+	if ctx == nil {
+		return nil
+	}
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// RequestIDFromContext returns the correlation ID of the current request, or "" if none.
+func RequestIDFromContext(ctx context.Context) string {
+	if info := requestInfoFromContext(ctx); info != nil {
+		return info.ID
+	}
+	return ""
+}
+
+// noteApplicationUUID records that the current request touched the given loan application.
+func noteApplicationUUID(ctx context.Context, appUUID string) {
+	info := requestInfoFromContext(ctx)
+	if info == nil || appUUID == "" {
+		return
+	}
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	for _, existing := range info.uuids {
+		if existing == appUUID {
+			return
+		}
+	}
+	info.uuids = append(info.uuids, appUUID)
+}
+
+func (info *requestInfo) applicationUUIDs() []string {
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	return append([]string(nil), info.uuids...)
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// isValidRequestID keeps client supplied IDs short and printable so they are safe to log.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestIDMiddleware accepts the caller's X-Request-ID (or generates one),
+// stores it in the request context and echoes it on the response.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !isValidRequestID(id) {
+			id = newRequestID()
+		}
+		info := &requestInfo{ID: id, Started: time.Now(), ClientIP: clientIP(r), UserAgent: r.UserAgent()}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)))
+	})
+}
+
+// trustForwardedFor makes clientIP use the X-Forwarded-For header set by a reverse proxy.
+var trustForwardedFor bool
+
+// SetTrustForwardedFor enables X-Forwarded-For for the client IP address. Enable it only
+// behind a proxy that overwrites the header, since clients can send any value.
+func SetTrustForwardedFor(trust bool) {
+	trustForwardedFor = trust
+}
+
+// clientIP returns the address of the client that sent the request.
+func clientIP(r *http.Request) string {
+	if trustForwardedFor {
+		first, _, _ := strings.Cut(r.Header.Get("X-Forwarded-For"), ",")
+		if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+			return ip.String()
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// contextLogHandler decorates every record with the request ID, actor and tenant found in the context.
+type contextLogHandler struct {
+	slog.Handler
+}
+
+// NewContextLogHandler wraps h so that records logged with a request context carry its request_id, actor and tenant.
+func NewContextLogHandler(h slog.Handler) slog.Handler {
+	return contextLogHandler{Handler: h}
+}
+
+func (h contextLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		record.AddAttrs(slog.String("actor", principal.UserID))
+	}
+	if id := tenantID(ctx); id != "" {
+		record.AddAttrs(slog.String("tenant", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextLogHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextLogHandler) WithGroup(name string) slog.Handler {
+	return contextLogHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// redactVariables returns a copy of the GraphQL variables with PII values replaced.
+func redactVariables(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, inner := range v {
+			if piiFields[strings.ToLower(key)] {
+				out[key] = redactedValue
+				continue
+			}
+			out[key] = redactVariables(inner)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, inner := range v {
+			out[i] = redactVariables(inner)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// operationFields returns the top level fields selected by the executed operation,
+// e.g. ["createLoanApplicationDraft"]. It is used when the client sends no operationName.
+func operationFields(query, operationName string) []string {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return nil
+	}
+	var fields []string
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName != "" && (op.Name == nil || op.Name.Value != operationName) {
+			continue
+		}
+		if op.SelectionSet == nil {
+			continue
+		}
+		for _, sel := range op.SelectionSet.Selections {
+			if field, ok := sel.(*ast.Field); ok && field.Name != nil {
+				fields = append(fields, field.Name.Value)
+			}
+		}
+		break
+	}
+	return fields
+}
+
+// LogResult is a handler.ResultCallbackFn that writes one structured line per GraphQL operation.
+func LogResult(ctx context.Context, params *graphql.Params, result *graphql.Result, _ []byte) {
+	attrs := []slog.Attr{
+		slog.String("operation", params.OperationName),
+		slog.Any("fields", operationFields(params.RequestString, params.OperationName)),
+		slog.Any("variables", redactVariables(params.VariableValues)),
+	}
+	if info := requestInfoFromContext(ctx); info != nil {
+		attrs = append(attrs,
+			slog.Duration("duration", time.Since(info.Started)),
+			slog.Any("application_uuids", info.applicationUUIDs()),
+		)
+	}
+
+	level := slog.LevelInfo
+	outcome := "success"
+	if result.HasErrors() {
+		level = slog.LevelWarn
+		outcome = "error"
+		messages := make([]string, len(result.Errors))
+		for i, e := range result.Errors {
+			messages[i] = e.Message
+		}
+		attrs = append(attrs, slog.Any("errors", messages))
+	}
+	attrs = append(attrs, slog.String("outcome", outcome))
+
+	slog.LogAttrs(ctx, level, "graphql operation", attrs...)
+}
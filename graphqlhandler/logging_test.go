@@ -0,0 +1,51 @@
+package graphqlhandler
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestLogResultRedactsPII(t *testing.T) {
+	var out bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&out, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	draft := testJointDraft("3171012345678901", "3171019876543210")
+	primary := draft["parties"].([]interface{})[0].(map[string]interface{})["customer"].(map[string]interface{})
+	primary["gender"] = "FEMALE"
+	primary["employment_type"] = "EMPLOYEE"
+	primary["employer"] = "Initech Nusantara"
+	primary["monthly_income"] = 23456789.0
+	primary["monthly_obligations"] = 1234567.0
+	primary["dependents"] = 7
+	primary["address"] = map[string]interface{}{
+		"street": "Jl. Kebon Sirih 17", "district": "Menteng", "sub_district": "Gondangdia", "city": "Jakarta Pusat",
+		"zipcode": "10350", "geo": map[string]interface{}{"latitude": -6.18765, "longitude": 106.83421},
+	}
+	draft["collateral"].(map[string]interface{})["vehicle"] = map[string]interface{}{
+		"plate_number": "B 4321 XYZ", "chassis_number": "MHFXW42G0K1234567", "engine_number": "2GDC987654",
+	}
+
+	params := &graphql.Params{RequestString: createDraftMutation, VariableValues: map[string]interface{}{"d": draft}}
+	LogResult(context.Background(), params, &graphql.Result{}, nil)
+
+	logged := out.String()
+	for _, value := range []string{
+		"John Doe", "Jane Roe", "3171012345678901", "3171019876543210", "john@example.com", "jane@example.com",
+		"1234567890", "1990-01-15", "FEMALE", "Jl. Kebon Sirih 17", "Menteng", "Gondangdia", "10350",
+		"-6.18765", "106.83421", "Initech Nusantara", "23456789", "1234567", "B 4321 XYZ", "MHFXW42G0K1234567", "2GDC987654",
+	} {
+		if strings.Contains(logged, value) {
+			t.Errorf("log line contains %q: %s", value, logged)
+		}
+	}
+	if !strings.Contains(logged, redactedValue) || !strings.Contains(logged, "Jakarta Pusat") {
+		t.Fatalf("log line lost the non-personal variables: %s", logged)
+	}
+}
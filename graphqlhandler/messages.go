@@ -0,0 +1,364 @@
+package graphqlhandler
+
+import "fmt"
+
+// Message codes select the text of validation errors, returned as
+// extensions.validation_code, and of localized API errors. Like the error codes they
+// are stable; only the catalog texts may change.
+const (
+	MsgInvalidField           = "INVALID_FIELD"
+	MsgRequired               = "REQUIRED"
+	MsgOneOf                  = "ONE_OF"
+	MsgOnlyWith               = "ONLY_WITH"
+	MsgNotNegative            = "NOT_NEGATIVE"
+	MsgNotPositive            = "NOT_POSITIVE"
+	MsgOutOfRange             = "OUT_OF_RANGE"
+	MsgAmountOutOfRange       = "AMOUNT_OUT_OF_RANGE"
+	MsgInvalidUTF8            = "INVALID_UTF8"
+	MsgControlCharacters      = "CONTROL_CHARACTERS"
+	MsgTooLong                = "TOO_LONG"
+	MsgLength                 = "LENGTH"
+	MsgNameCharacters         = "NAME_CHARACTERS"
+	MsgDateFormat             = "DATE_FORMAT"
+	MsgInvalidEmail           = "INVALID_EMAIL"
+	MsgInvalidCountry         = "INVALID_COUNTRY"
+	MsgPhoneInternational     = "PHONE_INTERNATIONAL_FORMAT"
+	MsgPhoneDigits            = "PHONE_DIGITS"
+	MsgNIKFormat              = "NIK_FORMAT"
+	MsgNIKProvince            = "NIK_PROVINCE"
+	MsgNIKBirthDate           = "NIK_BIRTH_DATE"
+	MsgNIKSerial              = "NIK_SERIAL"
+	MsgNIKDateOfBirthMismatch = "NIK_DATE_OF_BIRTH_MISMATCH"
+	MsgNIKMaleMismatch        = "NIK_MALE_GENDER_MISMATCH"
+	MsgNIKFemaleMismatch      = "NIK_FEMALE_GENDER_MISMATCH"
+	MsgIndonesianPhone        = "ID_PHONE_FORMAT"
+	MsgIndonesianPhonePrefix  = "ID_PHONE_PREFIX"
+	MsgIndonesianPostalCode   = "ID_POSTAL_CODE"
+	MsgRegionMismatch         = "REGION_POSTAL_CODE_MISMATCH"
+	MsgOutsideCountry         = "OUTSIDE_COUNTRY"
+
+	MsgRequiredWithFinancials  = "REQUIRED_WITH_FINANCIAL_DATA"
+	MsgRequiredForEmployment   = "REQUIRED_FOR_EMPLOYMENT_TYPE"
+	MsgNotForEmployment        = "NOT_APPLICABLE_TO_EMPLOYMENT_TYPE"
+	MsgPositiveForEmployment   = "POSITIVE_FOR_EMPLOYMENT_TYPE"
+	MsgTenureOutOfRange        = "TENURE_OUT_OF_RANGE"
+	MsgNoRate                  = "NO_RATE"
+	MsgNoBorrowerIncome        = "NO_BORROWER_INCOME"
+	MsgDebtToIncomeExceeded    = "DEBT_TO_INCOME_EXCEEDED"
+	MsgLoanToValueExceeded     = "LOAN_TO_VALUE_EXCEEDED"
+	MsgCollateralCount         = "COLLATERAL_COUNT"
+	MsgNotForCategory          = "NOT_APPLICABLE_TO_CATEGORY"
+	MsgRequiredForCategory     = "REQUIRED_FOR_CATEGORY"
+	MsgPlateNumberFormat       = "PLATE_NUMBER_FORMAT"
+	MsgChassisNumberFormat     = "CHASSIS_NUMBER_FORMAT"
+	MsgEngineNumberFormat      = "ENGINE_NUMBER_FORMAT"
+	MsgSerialNumberFormat      = "SERIAL_NUMBER_FORMAT"
+	MsgCertificateNumberFormat = "CERTIFICATE_NUMBER_FORMAT"
+	MsgTrucksOnly              = "TRUCKS_ONLY"
+	MsgTruckWeightOutOfRange   = "TRUCK_WEIGHT_OUT_OF_RANGE"
+	MsgRequiredForCertificate  = "REQUIRED_FOR_CERTIFICATE_TYPE"
+	MsgGoldWeightOutOfRange    = "GOLD_WEIGHT_OUT_OF_RANGE"
+	MsgBarsAndCoinsOnly        = "BARS_AND_COINS_ONLY"
+	MsgUnknownBrand            = "UNKNOWN_VEHICLE_BRAND"
+	MsgUnknownModel            = "UNKNOWN_VEHICLE_MODEL"
+	MsgUnknownVariant          = "UNKNOWN_VEHICLE_VARIANT"
+	MsgProducedSince           = "NOT_PRODUCED_BEFORE"
+	MsgProducedBetween         = "NOT_PRODUCED_IN_YEAR"
+	MsgPartyCount              = "PARTY_COUNT"
+	MsgDuplicateIDNumber       = "DUPLICATE_ID_NUMBER"
+	MsgPrimaryPartyCount       = "PRIMARY_PARTY_COUNT"
+	MsgTermsNotAccepted        = "TERMS_NOT_ACCEPTED"
+	MsgDuplicateTerms          = "DUPLICATE_TERMS_DOCUMENT"
+
+	MsgAuthenticationRequired  = "AUTHENTICATION_REQUIRED"
+	MsgTenantRequired          = "TENANT_REQUIRED"
+	MsgApplicationNotFound     = "APPLICATION_NOT_FOUND"
+	MsgVersionConflict         = "VERSION_CONFLICT"
+	MsgCannotSubmit            = "CANNOT_SUBMIT"
+	MsgCannotCancel            = "CANNOT_CANCEL"
+	MsgCannotDecide            = "CANNOT_DECIDE"
+	MsgCannotAddDocument       = "CANNOT_ADD_DOCUMENT"
+	MsgFileEmpty               = "FILE_EMPTY"
+	MsgFileTypeNotAccepted     = "FILE_TYPE_NOT_ACCEPTED"
+	MsgApplicationDocument     = "APPLICATION_DOCUMENT"
+	MsgCollateralIDRequired    = "COLLATERAL_ID_REQUIRED"
+	MsgCollateralNotFound      = "COLLATERAL_NOT_FOUND"
+	MsgDocumentNotFound        = "DOCUMENT_NOT_FOUND"
+	MsgVehicleVariantNotFound  = "VEHICLE_VARIANT_NOT_FOUND"
+	MsgRejectionReasonRequired = "REJECTION_REASON_REQUIRED"
+
+	MsgOperationForbidden        = "OPERATION_FORBIDDEN"
+	MsgTenantMismatch            = "TENANT_MISMATCH"
+	MsgTenantNotSelectable       = "TENANT_NOT_SELECTABLE"
+	MsgTenantBoundOperation      = "TENANT_BOUND_OPERATION"
+	MsgUnknownTenant             = "UNKNOWN_TENANT"
+	MsgIdempotencyKeysDiffer     = "IDEMPOTENCY_KEYS_DIFFER"
+	MsgIdempotencyKeyReused      = "IDEMPOTENCY_KEY_REUSED"
+	MsgStorageModeRequired       = "STORAGE_MODE_REQUIRED"
+	MsgTimestampFormat           = "TIMESTAMP_FORMAT"
+	MsgWebSocketOnly             = "WEBSOCKET_ONLY"
+	MsgMultipartUploadRequired   = "MULTIPART_UPLOAD_REQUIRED"
+	MsgWebhookURL                = "WEBHOOK_URL"
+	MsgWebhookHostUnresolved     = "WEBHOOK_HOST_UNRESOLVED"
+	MsgWebhookHostNotPublic      = "WEBHOOK_HOST_NOT_PUBLIC"
+	MsgUnknownEventType          = "UNKNOWN_EVENT_TYPE"
+	MsgWebhookNotFound           = "WEBHOOK_NOT_FOUND"
+	MsgDeadLetterNotFound        = "DEAD_LETTER_NOT_FOUND"
+	MsgBearerScheme              = "BEARER_SCHEME"
+	MsgInvalidAccessToken        = "INVALID_ACCESS_TOKEN"
+	MsgMethodNotAllowed          = "METHOD_NOT_ALLOWED"
+	MsgDocumentDownloadForbidden = "DOCUMENT_DOWNLOAD_FORBIDDEN"
+	MsgUploadTooLarge            = "UPLOAD_TOO_LARGE"
+	MsgInvalidMultipart          = "INVALID_MULTIPART"
+	MsgMultipartOperations       = "MULTIPART_OPERATIONS"
+	MsgMultipartMap              = "MULTIPART_MAP"
+	MsgMultipartFileCount        = "MULTIPART_FILE_COUNT"
+	MsgMultipartMapPath          = "MULTIPART_MAP_PATH"
+)
+
+// messageCatalogs hold the fmt templates of every message code per locale. Field names
+// stay as in the schema so clients can match them.
+var messageCatalogs = map[string]map[string]string{
+	LocaleEnglish: {
+		MsgInvalidField:           "invalid %s: %s",
+		MsgRequired:               "%s is required",
+		MsgOneOf:                  "send either %s or %s",
+		MsgOnlyWith:               "%s can only be sent with %s",
+		MsgNotNegative:            "%s must not be negative",
+		MsgNotPositive:            "%s must be positive",
+		MsgOutOfRange:             "%s must be between %d and %d",
+		MsgAmountOutOfRange:       "%s must be between %g and %g",
+		MsgInvalidUTF8:            "%s is not valid UTF-8",
+		MsgControlCharacters:      "%s must not contain control characters",
+		MsgTooLong:                "%s must be at most %d characters",
+		MsgLength:                 "%s must be %d-%d characters",
+		MsgNameCharacters:         "%s must start with a letter and contain only letters, spaces, apostrophes, hyphens and periods",
+		MsgDateFormat:             "%s must be in YYYY-MM-DD format",
+		MsgInvalidEmail:           "email is not valid",
+		MsgInvalidCountry:         "country must be an ISO 3166-1 alpha-2 code such as ID",
+		MsgPhoneInternational:     "phone must be + and 8-15 digits in international format",
+		MsgPhoneDigits:            "phone must be 6-30 digits",
+		MsgNIKFormat:              "id_number must be a 16 digit NIK",
+		MsgNIKProvince:            "id_number does not start with an Indonesian province code",
+		MsgNIKBirthDate:           "id_number does not contain a valid date of birth",
+		MsgNIKSerial:              "id_number has an invalid serial number",
+		MsgNIKDateOfBirthMismatch: "id_number does not match date_of_birth",
+		MsgNIKMaleMismatch:        "id_number belongs to a man, but gender is %s",
+		MsgNIKFemaleMismatch:      "id_number belongs to a woman, but gender is %s",
+		MsgIndonesianPhone:        "phone must be an Indonesian mobile number starting with 08 or +628",
+		MsgIndonesianPhonePrefix:  "phone must be an Indonesian mobile number with a known operator prefix and 10-13 digits",
+		MsgIndonesianPostalCode:   "zipcode must be a 5 digit Indonesian postal code",
+		MsgRegionMismatch:         "%s %q does not match zipcode %s",
+		MsgOutsideCountry:         "geo is outside %s",
+
+		MsgRequiredWithFinancials:  "%s is required with financial data",
+		MsgRequiredForEmployment:   "%s is required for %s customers",
+		MsgNotForEmployment:        "%s does not apply to %s customers",
+		MsgPositiveForEmployment:   "%s must be positive for %s customers",
+		MsgTenureOutOfRange:        "tenure must be between %d and %d, and divisible by %d",
+		MsgNoRate:                  "no rate available for %s over %d months",
+		MsgNoBorrowerIncome:        "the borrowers have no monthly income",
+		MsgDebtToIncomeExceeded:    "the installment of %.2f brings the borrowers' debt-to-income ratio to %.1f%%, more than %g%%",
+		MsgLoanToValueExceeded:     "amount must be at most %g%% of the collateral value %g",
+		MsgCollateralCount:         "between 1 and %d collaterals are required",
+		MsgNotForCategory:          "%s does not apply to %s collateral",
+		MsgRequiredForCategory:     "%s is required for %s collateral",
+		MsgPlateNumberFormat:       "plate_number must be a region code, 1-4 digits and an optional 1-3 letter suffix, e.g. B 1234 XYZ",
+		MsgChassisNumberFormat:     "chassis_number must be 17 letters and digits, without I, O and Q",
+		MsgEngineNumberFormat:      "engine_number must be 5-20 letters and digits",
+		MsgSerialNumberFormat:      "serial_number must be 5-30 letters, digits and dashes",
+		MsgCertificateNumberFormat: "certificate_number must be 1-50 letters, digits, dots, slashes and dashes",
+		MsgTrucksOnly:              "%s only applies to trucks",
+		MsgTruckWeightOutOfRange:   "%s must be between %d and %d for trucks",
+		MsgRequiredForCertificate:  "%s is required for %s certificates",
+		MsgGoldWeightOutOfRange:    "%s must be greater than 0 and at most %d",
+		MsgBarsAndCoinsOnly:        "%s only applies to bars and coins",
+		MsgUnknownBrand:            "brand '%s' is not in the %s vehicle catalog",
+		MsgUnknownModel:            "model '%s' is not in the catalog of %s",
+		MsgUnknownVariant:          "variant '%s' is not in the catalog of %s",
+		MsgProducedSince:           "the %s %s %s is produced since %d",
+		MsgProducedBetween:         "the %s %s %s was produced from %d to %d",
+		MsgPartyCount:              "at most %d parties are allowed",
+		MsgDuplicateIDNumber:       "the same id_number appears twice",
+		MsgPrimaryPartyCount:       "exactly one PRIMARY party is required",
+		MsgTermsNotAccepted:        "the current %s version %s was not accepted",
+		MsgDuplicateTerms:          "%s is listed more than once",
+
+		MsgAuthenticationRequired:  "authentication required",
+		MsgTenantRequired:          "a tenant is required, send the %s header",
+		MsgApplicationNotFound:     "loan application with UUID '%s' not found",
+		MsgVersionConflict:         "loan application '%s' was modified since version %d; reload it and retry",
+		MsgCannotSubmit:            "loan application status is '%s', cannot submit",
+		MsgCannotCancel:            "loan application status is '%s', cannot cancel",
+		MsgCannotDecide:            "loan application status is '%s', only SUBMITTED applications can be decided",
+		MsgCannotAddDocument:       "loan application status is '%s', documents can only be added to DRAFT or SUBMITTED applications",
+		MsgFileEmpty:               "file is empty",
+		MsgFileTypeNotAccepted:     "file type %s is not accepted, send JPEG, PNG or PDF",
+		MsgApplicationDocument:     "%s documents belong to the application, not to a collateral item",
+		MsgCollateralIDRequired:    "collateralId is required for %s documents when the application has several collateral items",
+		MsgCollateralNotFound:      "collateral item '%s' not found",
+		MsgDocumentNotFound:        "document '%s' not found",
+		MsgVehicleVariantNotFound:  "vehicle variant %s %s %s not found",
+		MsgRejectionReasonRequired: "a reason is required to reject a document",
+
+		MsgOperationForbidden:        "not allowed to call %s",
+		MsgTenantMismatch:            "tenant header does not match the access token",
+		MsgTenantNotSelectable:       "the access token does not allow choosing a tenant",
+		MsgTenantBoundOperation:      "%s affects every tenant and needs an access token not bound to a tenant",
+		MsgUnknownTenant:             "unknown tenant %q",
+		MsgIdempotencyKeysDiffer:     "%s and %s header differ",
+		MsgIdempotencyKeyReused:      "idempotency key was already used with a different payload",
+		MsgStorageModeRequired:       "%s requires the %s storage mode",
+		MsgTimestampFormat:           "%s must be an RFC 3339 timestamp",
+		MsgWebSocketOnly:             "subscriptions are only available over WebSocket (graphql-transport-ws)",
+		MsgMultipartUploadRequired:   "%s must be sent as a multipart upload",
+		MsgWebhookURL:                "url must be an absolute https URL",
+		MsgWebhookHostUnresolved:     "url host %s cannot be resolved",
+		MsgWebhookHostNotPublic:      "url host %s resolves to a non-public address",
+		MsgUnknownEventType:          "unknown event type %q",
+		MsgWebhookNotFound:           "webhook '%s' not found",
+		MsgDeadLetterNotFound:        "dead letter '%s' not found",
+		MsgBearerScheme:              "authorization header must use the Bearer scheme",
+		MsgInvalidAccessToken:        "invalid access token",
+		MsgMethodNotAllowed:          "use %s",
+		MsgDocumentDownloadForbidden: "not allowed to download documents",
+		MsgUploadTooLarge:            "uploads are limited to %d bytes",
+		MsgInvalidMultipart:          "invalid multipart request: %s",
+		MsgMultipartOperations:       "the operations part must be a single JSON request",
+		MsgMultipartMap:              "the map part must be a JSON object of file keys to variable paths",
+		MsgMultipartFileCount:        "expected one file in part %q",
+		MsgMultipartMapPath:          "map path %q: %s",
+	},
+	LocaleIndonesian: {
+		MsgInvalidField:           "%s tidak valid: %s",
+		MsgRequired:               "%s wajib diisi",
+		MsgOneOf:                  "kirim salah satu dari %s atau %s",
+		MsgOnlyWith:               "%s hanya dapat dikirim bersama %s",
+		MsgNotNegative:            "%s tidak boleh negatif",
+		MsgNotPositive:            "%s harus lebih dari 0",
+		MsgOutOfRange:             "%s harus antara %d dan %d",
+		MsgAmountOutOfRange:       "%s harus antara %g dan %g",
+		MsgInvalidUTF8:            "%s bukan teks UTF-8 yang valid",
+		MsgControlCharacters:      "%s tidak boleh mengandung karakter kontrol",
+		MsgTooLong:                "%s maksimal %d karakter",
+		MsgLength:                 "%s harus %d-%d karakter",
+		MsgNameCharacters:         "%s harus diawali huruf dan hanya berisi huruf, spasi, apostrof, tanda hubung, dan titik",
+		MsgDateFormat:             "%s harus berformat YYYY-MM-DD",
+		MsgInvalidEmail:           "email tidak valid",
+		MsgInvalidCountry:         "country harus berupa kode ISO 3166-1 alpha-2, misalnya ID",
+		MsgPhoneInternational:     "phone harus berupa + dan 8-15 digit dalam format internasional",
+		MsgPhoneDigits:            "phone harus 6-30 digit",
+		MsgNIKFormat:              "id_number harus berupa NIK 16 digit",
+		MsgNIKProvince:            "id_number tidak diawali kode provinsi Indonesia",
+		MsgNIKBirthDate:           "id_number tidak memuat tanggal lahir yang valid",
+		MsgNIKSerial:              "id_number memiliki nomor urut yang tidak valid",
+		MsgNIKDateOfBirthMismatch: "id_number tidak sesuai dengan date_of_birth",
+		MsgNIKMaleMismatch:        "id_number milik laki-laki, tetapi gender %s",
+		MsgNIKFemaleMismatch:      "id_number milik perempuan, tetapi gender %s",
+		MsgIndonesianPhone:        "phone harus berupa nomor ponsel Indonesia yang diawali 08 atau +628",
+		MsgIndonesianPhonePrefix:  "phone harus berupa nomor ponsel Indonesia dengan prefiks operator yang dikenal dan 10-13 digit",
+		MsgIndonesianPostalCode:   "zipcode harus berupa kode pos Indonesia 5 digit",
+		MsgRegionMismatch:         "%s %q tidak sesuai dengan zipcode %s",
+		MsgOutsideCountry:         "geo berada di luar %s",
+
+		MsgRequiredWithFinancials:  "%s wajib diisi bersama data keuangan",
+		MsgRequiredForEmployment:   "%s wajib diisi untuk nasabah %s",
+		MsgNotForEmployment:        "%s tidak berlaku untuk nasabah %s",
+		MsgPositiveForEmployment:   "%s harus lebih dari 0 untuk nasabah %s",
+		MsgTenureOutOfRange:        "tenure harus antara %d dan %d bulan dan habis dibagi %d",
+		MsgNoRate:                  "tidak ada suku bunga untuk %s dengan tenor %d bulan",
+		MsgNoBorrowerIncome:        "peminjam tidak memiliki penghasilan bulanan",
+		MsgDebtToIncomeExceeded:    "angsuran sebesar %.2f membuat rasio utang terhadap penghasilan peminjam menjadi %.1f%%, melebihi %g%%",
+		MsgLoanToValueExceeded:     "amount maksimal %g%% dari nilai agunan %g",
+		MsgCollateralCount:         "diperlukan 1 sampai %d agunan",
+		MsgNotForCategory:          "%s tidak berlaku untuk agunan %s",
+		MsgRequiredForCategory:     "%s wajib diisi untuk agunan %s",
+		MsgPlateNumberFormat:       "plate_number harus berupa kode wilayah, 1-4 angka, dan akhiran 1-3 huruf opsional, misalnya B 1234 XYZ",
+		MsgChassisNumberFormat:     "chassis_number harus 17 huruf dan angka, tanpa I, O, dan Q",
+		MsgEngineNumberFormat:      "engine_number harus 5-20 huruf dan angka",
+		MsgSerialNumberFormat:      "serial_number harus 5-30 huruf, angka, dan tanda hubung",
+		MsgCertificateNumberFormat: "certificate_number harus 1-50 huruf, angka, titik, garis miring, dan tanda hubung",
+		MsgTrucksOnly:              "%s hanya berlaku untuk truk",
+		MsgTruckWeightOutOfRange:   "%s harus antara %d dan %d untuk truk",
+		MsgRequiredForCertificate:  "%s wajib diisi untuk sertifikat %s",
+		MsgGoldWeightOutOfRange:    "%s harus lebih dari 0 dan maksimal %d",
+		MsgBarsAndCoinsOnly:        "%s hanya berlaku untuk emas batangan dan koin",
+		MsgUnknownBrand:            "merek '%s' tidak ada di katalog kendaraan %s",
+		MsgUnknownModel:            "model '%s' tidak ada di katalog %s",
+		MsgUnknownVariant:          "varian '%s' tidak ada di katalog %s",
+		MsgProducedSince:           "%s %s %s diproduksi sejak %d",
+		MsgProducedBetween:         "%s %s %s diproduksi dari %d sampai %d",
+		MsgPartyCount:              "maksimal %d pihak",
+		MsgDuplicateIDNumber:       "id_number yang sama muncul dua kali",
+		MsgPrimaryPartyCount:       "harus ada tepat satu pihak PRIMARY",
+		MsgTermsNotAccepted:        "%s versi %s yang berlaku belum disetujui",
+		MsgDuplicateTerms:          "%s dicantumkan lebih dari satu kali",
+
+		MsgAuthenticationRequired:  "autentikasi diperlukan",
+		MsgTenantRequired:          "tenant wajib ditentukan, kirim header %s",
+		MsgApplicationNotFound:     "pengajuan pinjaman dengan UUID '%s' tidak ditemukan",
+		MsgVersionConflict:         "pengajuan pinjaman '%s' telah diubah sejak versi %d; muat ulang lalu coba lagi",
+		MsgCannotSubmit:            "status pengajuan pinjaman adalah '%s', tidak dapat diajukan",
+		MsgCannotCancel:            "status pengajuan pinjaman adalah '%s', tidak dapat dibatalkan",
+		MsgCannotDecide:            "status pengajuan pinjaman adalah '%s', hanya pengajuan SUBMITTED yang dapat diputuskan",
+		MsgCannotAddDocument:       "status pengajuan pinjaman adalah '%s', dokumen hanya dapat ditambahkan ke pengajuan DRAFT atau SUBMITTED",
+		MsgFileEmpty:               "berkas kosong",
+		MsgFileTypeNotAccepted:     "jenis berkas %s tidak diterima, kirim JPEG, PNG, atau PDF",
+		MsgApplicationDocument:     "dokumen %s milik pengajuan, bukan milik agunan",
+		MsgCollateralIDRequired:    "collateralId wajib diisi untuk dokumen %s bila pengajuan memiliki beberapa agunan",
+		MsgCollateralNotFound:      "agunan '%s' tidak ditemukan",
+		MsgDocumentNotFound:        "dokumen '%s' tidak ditemukan",
+		MsgVehicleVariantNotFound:  "varian kendaraan %s %s %s tidak ditemukan",
+		MsgRejectionReasonRequired: "alasan wajib diisi untuk menolak dokumen",
+
+		MsgOperationForbidden:        "tidak diizinkan memanggil %s",
+		MsgTenantMismatch:            "header tenant tidak sesuai dengan token akses",
+		MsgTenantNotSelectable:       "token akses tidak mengizinkan pemilihan tenant",
+		MsgTenantBoundOperation:      "%s berlaku untuk semua tenant dan memerlukan token akses yang tidak terikat pada tenant",
+		MsgUnknownTenant:             "tenant %q tidak dikenal",
+		MsgIdempotencyKeysDiffer:     "%s dan header %s berbeda",
+		MsgIdempotencyKeyReused:      "kunci idempotensi sudah dipakai dengan muatan yang berbeda",
+		MsgStorageModeRequired:       "%s memerlukan mode penyimpanan %s",
+		MsgTimestampFormat:           "%s harus berupa stempel waktu RFC 3339",
+		MsgWebSocketOnly:             "langganan hanya tersedia melalui WebSocket (graphql-transport-ws)",
+		MsgMultipartUploadRequired:   "%s harus dikirim sebagai unggahan multipart",
+		MsgWebhookURL:                "url harus berupa URL https absolut",
+		MsgWebhookHostUnresolved:     "host url %s tidak dapat di-resolve",
+		MsgWebhookHostNotPublic:      "host url %s mengarah ke alamat non-publik",
+		MsgUnknownEventType:          "jenis event %q tidak dikenal",
+		MsgWebhookNotFound:           "webhook '%s' tidak ditemukan",
+		MsgDeadLetterNotFound:        "dead letter '%s' tidak ditemukan",
+		MsgBearerScheme:              "header Authorization harus memakai skema Bearer",
+		MsgInvalidAccessToken:        "token akses tidak valid",
+		MsgMethodNotAllowed:          "gunakan %s",
+		MsgDocumentDownloadForbidden: "tidak diizinkan mengunduh dokumen",
+		MsgUploadTooLarge:            "unggahan dibatasi %d byte",
+		MsgInvalidMultipart:          "permintaan multipart tidak valid: %s",
+		MsgMultipartOperations:       "bagian operations harus berupa satu permintaan JSON",
+		MsgMultipartMap:              "bagian map harus berupa objek JSON dari kunci berkas ke path variabel",
+		MsgMultipartFileCount:        "diharapkan satu berkas pada bagian %q",
+		MsgMultipartMapPath:          "path map %q: %s",
+	},
+}
+
+func init() {
+	for locale, catalog := range messageCatalogs {
+		if len(catalog) != len(messageCatalogs[LocaleEnglish]) {
+			panic(fmt.Sprintf("message catalog %q does not translate every message code", locale))
+		}
+		for code := range catalog {
+			if _, ok := messageCatalogs[LocaleEnglish][code]; !ok {
+				panic(fmt.Sprintf("message catalog %q has unknown message code %q", locale, code))
+			}
+		}
+	}
+}
+
+// catalogMessage renders a message code in a locale, falling back to English.
+func catalogMessage(locale, code string, args []interface{}) string {
+	template, ok := messageCatalogs[locale][code]
+	if !ok {
+		template = messageCatalogs[LocaleEnglish][code]
+	}
+	return fmt.Sprintf(template, args...)
+}
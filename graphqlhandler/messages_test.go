@@ -0,0 +1,90 @@
+package graphqlhandler
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// messageCodes returns the values of the Msg* constants declared in messages.go.
+func messageCodes(t *testing.T) map[string]string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "messages.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes := make(map[string]string)
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		for i, name := range spec.Names {
+			if lit, ok := spec.Values[i].(*ast.BasicLit); ok && strings.HasPrefix(name.Name, "Msg") {
+				codes[name.Name], _ = strconv.Unquote(lit.Value)
+			}
+		}
+		return false
+	})
+	return codes
+}
+
+var formatVerb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestMessageCatalogsTranslateEveryCode(t *testing.T) {
+	codes := messageCodes(t)
+	if len(codes) == 0 {
+		t.Fatal("no message codes found")
+	}
+	for name, code := range codes {
+		english, ok := messageCatalogs[LocaleEnglish][code]
+		if !ok {
+			t.Errorf("%s has no English message", name)
+			continue
+		}
+		for _, locale := range supportedLocales {
+			translated, ok := messageCatalogs[locale][code]
+			if !ok {
+				t.Errorf("%s has no %s message", name, locale)
+				continue
+			}
+			// Translations may reorder words but must take the same arguments.
+			want, got := formatVerb.FindAllString(english, -1), formatVerb.FindAllString(translated, -1)
+			if !slices.Equal(want, got) {
+				t.Errorf("%s: %s message uses %v, English uses %v", name, locale, got, want)
+			}
+		}
+	}
+}
+
+func TestMiddlewareErrorsAreLocalized(t *testing.T) {
+	useTenants(t, "acme", "globex")
+	handler := TenantMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.Header.Set(TenantHeader, "globex")
+	req.Header.Set("Accept-Language", "id-ID,id;q=0.9")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req.WithContext(WithPrincipal(req.Context(), agentOf("acme"))))
+
+	var body struct {
+		Errors []struct {
+			Message    string                 `json:"message"`
+			Extensions map[string]interface{} `json:"extensions"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || len(body.Errors) != 1 {
+		t.Fatalf("body %v: %v", body, err)
+	}
+	want := messageCatalogs[LocaleIndonesian][MsgTenantMismatch]
+	if got := body.Errors[0]; got.Message != want || got.Extensions["code"] != ErrCodeForbidden {
+		t.Fatalf("got %+v, want %q with code %s", got, want, ErrCodeForbidden)
+	}
+}
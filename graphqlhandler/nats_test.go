@@ -0,0 +1,195 @@
+package graphqlhandler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type natsMessage struct {
+	subject string
+	header  string
+	payload []byte
+}
+
+// natsStandIn is a local stand-in for a NATS server: it greets with an INFO that
+// announces header support, answers PING with PONG and records CONNECT options and
+// HPUB messages. reject answers messages with -ERR; dropNext closes the connection
+// instead of answering the next message.
+type natsStandIn struct {
+	t        *testing.T
+	listener net.Listener
+	reject   atomic.Bool
+	dropNext atomic.Bool
+
+	mu       sync.Mutex
+	connects []map[string]interface{}
+	messages []natsMessage
+}
+
+func startNATSStandIn(t *testing.T) *natsStandIn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &natsStandIn{t: t, listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *natsStandIn) url() string {
+	return "nats://loans:secret@" + s.listener.Addr().String()
+}
+
+func (s *natsStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"stand-in\",\"headers\":true}\r\n")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		verb, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch verb {
+		case "CONNECT":
+			var options map[string]interface{}
+			json.Unmarshal([]byte(args), &options)
+			s.mu.Lock()
+			s.connects = append(s.connects, options)
+			s.mu.Unlock()
+		case "PING":
+			fmt.Fprintf(conn, "PONG\r\n")
+		case "HPUB":
+			var subject string
+			var headerLen, totalLen int
+			if _, err := fmt.Sscanf(args, "%s %d %d", &subject, &headerLen, &totalLen); err != nil {
+				s.t.Errorf("HPUB %q: %v", args, err)
+				return
+			}
+			body := make([]byte, totalLen+2)
+			if _, err := io.ReadFull(reader, body); err != nil || string(body[totalLen:]) != "\r\n" {
+				s.t.Errorf("HPUB %q: body does not match the byte counts", args)
+				return
+			}
+			if s.dropNext.CompareAndSwap(true, false) {
+				return
+			}
+			if s.reject.Load() {
+				fmt.Fprintf(conn, "-ERR 'Permissions Violation for Publish to %s'\r\n", subject)
+				continue
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, natsMessage{subject: subject, header: string(body[:headerLen]), payload: body[headerLen:totalLen]})
+			s.mu.Unlock()
+		default:
+			fmt.Fprintf(conn, "-ERR 'Unknown Protocol Operation'\r\n")
+		}
+	}
+}
+
+func (s *natsStandIn) received() ([]map[string]interface{}, []natsMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]interface{}(nil), s.connects...), append([]natsMessage(nil), s.messages...)
+}
+
+// msgIDs returns the Nats-Msg-Id header of every accepted message.
+func (s *natsStandIn) msgIDs() []string {
+	_, messages := s.received()
+	var ids []string
+	for _, message := range messages {
+		for _, line := range strings.Split(message.header, "\r\n") {
+			if id, ok := strings.CutPrefix(line, "Nats-Msg-Id: "); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+func testDomainEvent(id string) DomainEvent {
+	return DomainEvent{ID: id, Type: DomainEventApplicationSubmitted, SchemaVersion: DomainEventSchemaVersion, TenantID: "acme.corp",
+		ApplicationUUID: "app-1", ApplicationVersion: 2, OccurredAt: time.Now().UTC(),
+		Data: ApplicationEventData{PreviousStatus: "DRAFT", Status: "SUBMITTED"}}
+}
+
+func TestNATSPublisher(t *testing.T) {
+	server := startNATSStandIn(t)
+	publisher, err := NewNATSPublisher(server.url(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	ctx := context.Background()
+
+	if err := publisher.Publish(ctx, testDomainEvent("event-1")); err != nil {
+		t.Fatal(err)
+	}
+	connects, messages := server.received()
+	if len(connects) != 1 {
+		t.Fatalf("%d CONNECTs, want 1", len(connects))
+	}
+	for option, want := range map[string]interface{}{"user": "loans", "pass": "secret", "headers": true, "verbose": false, "name": "loangraphql"} {
+		if connects[0][option] != want {
+			t.Errorf("CONNECT %s = %v, want %v", option, connects[0][option], want)
+		}
+	}
+	if len(messages) != 1 {
+		t.Fatalf("%d messages, want 1", len(messages))
+	}
+	if messages[0].subject != "loans.acme_corp.ApplicationSubmitted" {
+		t.Errorf("subject %q", messages[0].subject)
+	}
+	if messages[0].header != "NATS/1.0\r\nNats-Msg-Id: event-1\r\n\r\n" {
+		t.Errorf("header %q", messages[0].header)
+	}
+	var published DomainEvent
+	if err := json.Unmarshal(messages[0].payload, &published); err != nil || published.ID != "event-1" || published.Data.Status != "SUBMITTED" {
+		t.Errorf("payload %s: %v", messages[0].payload, err)
+	}
+
+	// A server error fails the publish; the next one starts over on a new connection.
+	server.reject.Store(true)
+	if err := publisher.Publish(ctx, testDomainEvent("event-2")); err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+		t.Fatalf("rejected publish: %v", err)
+	}
+	server.reject.Store(false)
+	if err := publisher.Publish(ctx, testDomainEvent("event-2")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A dropped connection fails the publish in flight and is re-opened for the next one.
+	server.dropNext.Store(true)
+	if err := publisher.Publish(ctx, testDomainEvent("event-3")); err == nil {
+		t.Fatal("publish succeeded on a dropped connection")
+	}
+	if err := publisher.Publish(ctx, testDomainEvent("event-3")); err != nil {
+		t.Fatal(err)
+	}
+
+	if connects, _ := server.received(); len(connects) != 3 {
+		t.Errorf("%d CONNECTs, want 3", len(connects))
+	}
+	if ids := server.msgIDs(); strings.Join(ids, ",") != "event-1,event-2,event-3" {
+		t.Errorf("accepted messages %v", ids)
+	}
+}
@@ -0,0 +1,162 @@
+package graphqlhandler
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Lifecycle event types, one per status an application can enter.
+const (
+	EventApplicationCreated   = "application.created"
+	EventApplicationSubmitted = "application.submitted"
+	EventApplicationCancelled = "application.cancelled"
+	EventApplicationApproved  = "application.approved"
+	EventApplicationRejected  = "application.rejected"
+	EventApplicationExpired   = "application.expired"
+)
+
+var lifecycleEventTypes = []string{
+	EventApplicationCreated,
+	EventApplicationSubmitted,
+	EventApplicationCancelled,
+	EventApplicationApproved,
+	EventApplicationRejected,
+	EventApplicationExpired,
+}
+
+// OutboxEvent is a lifecycle event recorded by the store in the same critical section
+// as the status change it describes (a transactional outbox). Background workers read
+// it with PendingEvents and acknowledge it once handled, so an event is never lost
+// between committing a change and delivering its notification.
+type OutboxEvent struct {
+	ID              string    `json:"id"`
+	Type            string    `json:"type"`
+	TenantID        string    `json:"tenant_id"`
+	ApplicationUUID string    `json:"application_uuid"`
+	PreviousStatus  string    `json:"previous_status,omitempty"`
+	Status          string    `json:"status"`
+	Version         int       `json:"version"`
+	Actor           string    `json:"actor"`
+	OccurredAt      time.Time `json:"occurred_at"`
+
+	// Loan terms, recorded for application.created only.
+	ProposedLoan       *ProposedLoanData `json:"proposed_loan,omitempty"`
+	CollateralCategory string            `json:"collateral_category,omitempty"`
+}
+
+func newOutboxEvent(previousStatus string, app *LoanApplicationData, actor string, at time.Time) OutboxEvent {
+	event := OutboxEvent{
+		ID:              uuid.New().String(),
+		Type:            "application." + strings.ToLower(app.Status),
+		TenantID:        app.TenantID,
+		ApplicationUUID: app.UUID,
+		PreviousStatus:  previousStatus,
+		Status:          app.Status,
+		Version:         app.Version,
+		Actor:           actor,
+		OccurredAt:      at,
+	}
+	if previousStatus == "" {
+		loan := app.ProposedLoan
+		event.Type = EventApplicationCreated
+		event.ProposedLoan = &loan
+		event.CollateralCategory = app.primaryCollateral().Category
+	}
+	return event
+}
+
+// Names under which workers consume the outbox.
+const (
+	outboxConsumerWebhooks     = "webhooks"
+	outboxConsumerDomainEvents = "domain-events" // followed by ":<sink>", one consumer per sink
+)
+
+// outboxConsumers lists the consumers every outbox event waits for. Workers register
+// when they are created, before any traffic is served.
+var outboxConsumers struct {
+	sync.RWMutex
+	names []string
+}
+
+func registerOutboxConsumer(name string) {
+	outboxConsumers.Lock()
+	defer outboxConsumers.Unlock()
+	for _, existing := range outboxConsumers.names {
+		if existing == name {
+			return
+		}
+	}
+	outboxConsumers.names = append(outboxConsumers.names, name)
+}
+
+func outboxConsumerNames() []string {
+	outboxConsumers.RLock()
+	defer outboxConsumers.RUnlock()
+	return outboxConsumers.names
+}
+
+// outboxEntry is an outbox event with the consumers that have handled it.
+type outboxEntry struct {
+	event OutboxEvent
+	acked map[string]bool
+}
+
+func (e *outboxEntry) ackedByAll(consumers []string) bool {
+	for _, name := range consumers {
+		if !e.acked[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// outboxLog is the outbox of one store. Stores embed it and call record while holding
+// their own write lock.
+type outboxLog struct {
+	mu      sync.Mutex
+	entries []*outboxEntry
+}
+
+// record appends an event. Without any registered consumer nobody would ever
+// acknowledge it, so it is not kept.
+func (o *outboxLog) record(event OutboxEvent) {
+	if len(outboxConsumerNames()) == 0 {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.entries = append(o.entries, &outboxEntry{event: event, acked: make(map[string]bool)})
+}
+
+func (o *outboxLog) PendingEvents(consumer string, limit int) []OutboxEvent {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var events []OutboxEvent
+	for _, entry := range o.entries {
+		if len(events) == limit {
+			break
+		}
+		if !entry.acked[consumer] {
+			events = append(events, entry.event)
+		}
+	}
+	return events
+}
+
+func (o *outboxLog) AckEvent(consumer, id string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i, entry := range o.entries {
+		if entry.event.ID != id {
+			continue
+		}
+		entry.acked[consumer] = true
+		if entry.ackedByAll(outboxConsumerNames()) {
+			o.entries = append(o.entries[:i], o.entries[i+1:]...)
+		}
+		return
+	}
+}
@@ -0,0 +1,204 @@
+package graphqlhandler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+)
+
+// Party roles. Every application has exactly one PRIMARY party, stored as its customer.
+const (
+	PartyRolePrimary     = "PRIMARY"
+	PartyRoleCoApplicant = "CO_APPLICANT" // shares liability for the loan, e.g. a spouse
+	PartyRoleGuarantor   = "GUARANTOR"    // liable only if the borrowers default
+)
+
+// maxParties limits the parties of one application, the primary party included.
+const maxParties = 4
+
+// ConsentData records what a party agreed to when the application was created.
+type ConsentData struct {
+	CreditCheck    bool      `json:"credit_check"`    // credit bureau (SLIK) inquiry
+	DataProcessing bool      `json:"data_processing"` // processing of personal data for the application
+	Marketing      bool      `json:"marketing"`
+	GivenAt        time.Time `json:"given_at"`
+}
+
+// complete reports whether the consents required to assess the application were given.
+func (c ConsentData) complete() bool {
+	return c.CreditCheck && c.DataProcessing
+}
+
+// PartyData is a co-applicant or guarantor. The primary party is the application's Customer.
+type PartyData struct {
+	ID       string       `json:"id"`
+	Role     string       `json:"role"`
+	Customer CustomerData `json:"customer"`
+	Consent  ConsentData  `json:"consent"`
+}
+
+// allParties returns the primary party, whose ID is the application UUID, followed by
+// the co-applicants and guarantors.
+func (app *LoanApplicationData) allParties() []PartyData {
+	return append([]PartyData{{ID: app.UUID, Role: PartyRolePrimary, Customer: app.Customer, Consent: app.Consent}}, app.Parties...)
+}
+
+// hasIDNumberIndex reports whether any party of the application has the blind index.
+func (app *LoanApplicationData) hasIDNumberIndex(index string) bool {
+	if app.Customer.IDNumberIndex == index {
+		return true
+	}
+	for _, party := range app.Parties {
+		if party.Customer.IDNumberIndex == index {
+			return true
+		}
+	}
+	return false
+}
+
+// forEachCustomer calls fn with the customer data of every party and the scope its
+// fields are encrypted under: the application UUID for the primary party and
+// "<application uuid>/<party id>" for the others.
+func forEachCustomer(app *LoanApplicationData, fn func(scope string, customer *CustomerData) error) error {
+	if err := fn(app.UUID, &app.Customer); err != nil {
+		return err
+	}
+	for i := range app.Parties {
+		if err := fn(app.UUID+"/"+app.Parties[i].ID, &app.Parties[i].Customer); err != nil {
+			return fmt.Errorf("party %s: %w", app.Parties[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// newCustomerData maps a validated CustomerInput. The phone is stored in E.164 format
+// where the country allows it.
+func newCustomerData(input map[string]interface{}) CustomerData {
+	addressInput := input["address"].(map[string]interface{})
+	email, _ := input["email"].(string) // Optional, absent when not provided
+	country, _ := input["country"].(string)
+	gender, _ := input["gender"].(string)
+	validator, _ := countryValidatorFor(country)
+	phone, _ := validator.NormalizePhone(input["phone"].(string))
+	address := newAddressData(addressInput, country)
+	employmentType, _ := input["employment_type"].(string)
+	employer, _ := input["employer"].(string)
+	income, _ := input["monthly_income"].(float64)
+	obligations, _ := input["monthly_obligations"].(float64)
+	dependents, _ := input["dependents"].(int)
+	return CustomerData{
+		FullName:           input["full_name"].(string),
+		DateOfBirth:        input["date_of_birth"].(string),
+		IDNumber:           input["id_number"].(string),
+		Email:              email,
+		Phone:              phone,
+		Country:            country,
+		Gender:             gender,
+		Address:            address,
+		EmploymentType:     employmentType,
+		Employer:           employer,
+		MonthlyIncome:      income,
+		MonthlyObligations: obligations,
+		Dependents:         dependents,
+		IDNumberIndex:      blindIndex(input["id_number"].(string)),
+	}
+}
+
+// newAddressData maps a validated AddressInput and standardizes its regions.
+func newAddressData(input map[string]interface{}, customerCountry string) AddressData {
+	province, _ := input["province"].(string)
+	district, _ := input["district"].(string)
+	subDistrict, _ := input["sub_district"].(string)
+	address := AddressData{
+		Street:      input["street"].(string),
+		SubDistrict: subDistrict,
+		District:    district,
+		City:        input["city"].(string),
+		Province:    province,
+		Zipcode:     input["zipcode"].(string),
+		Country:     addressCountry(input, customerCountry),
+	}
+	if geo, ok := input["geo"].(map[string]interface{}); ok {
+		point := newGeoPoint(geo)
+		address.Geo = &point
+	}
+	standardizeAddress(&address, input)
+	return address
+}
+
+func newConsentData(input map[string]interface{}, now time.Time) ConsentData {
+	creditCheck, _ := input["credit_check"].(bool)
+	dataProcessing, _ := input["data_processing"].(bool)
+	marketing, _ := input["marketing"].(bool)
+	return ConsentData{CreditCheck: creditCheck, DataProcessing: dataProcessing, Marketing: marketing, GivenAt: now}
+}
+
+// draftParties validates the parties of a draft and splits them into the primary
+// party and the others. Drafts either send the deprecated customer field, which
+// becomes the primary party with the consent of the equally deprecated consent field
+// (none is recorded without it), or a parties list with exactly one PRIMARY entry.
+func draftParties(draft map[string]interface{}, now time.Time) (CustomerData, ConsentData, []PartyData, error) {
+	customerInput, hasCustomer := draft["customer"].(map[string]interface{})
+	partiesInput, hasParties := draft["parties"].([]interface{})
+	consentInput, hasConsent := draft["consent"].(map[string]interface{})
+	if hasCustomer == hasParties {
+		return CustomerData{}, ConsentData{}, nil, invalidInput("", MsgOneOf, "parties", "customer")
+	}
+	if hasCustomer {
+		if err := validateCustomerInput(customerInput); err != nil {
+			return CustomerData{}, ConsentData{}, nil, invalidField("customer", err)
+		}
+		var consent ConsentData
+		if hasConsent {
+			consent = newConsentData(consentInput, now)
+		}
+		return newCustomerData(customerInput), consent, nil, nil
+	}
+	if hasConsent {
+		return CustomerData{}, ConsentData{}, nil, invalidInput("consent", MsgOnlyWith, "consent", "customer")
+	}
+
+	if len(partiesInput) > maxParties {
+		return CustomerData{}, ConsentData{}, nil, invalidInput("parties", MsgPartyCount, maxParties)
+	}
+	var primary *PartyData
+	var others []PartyData
+	seen := make(map[string]bool, len(partiesInput))
+	for i, raw := range partiesInput {
+		input, _ := raw.(map[string]interface{})
+		role, _ := input["role"].(string)
+		customerInput, _ := input["customer"].(map[string]interface{})
+		consentInput, _ := input["consent"].(map[string]interface{})
+		if err := validateCustomerInput(customerInput); err != nil {
+			return CustomerData{}, ConsentData{}, nil, invalidField(fmt.Sprintf("parties[%d]", i), invalidField("customer", err))
+		}
+		customer := newCustomerData(customerInput)
+		if seen[customer.IDNumberIndex] {
+			return CustomerData{}, ConsentData{}, nil, invalidField(fmt.Sprintf("parties[%d]", i), invalidInput("customer.id_number", MsgDuplicateIDNumber))
+		}
+		seen[customer.IDNumberIndex] = true
+		consent := newConsentData(consentInput, now)
+		if role == PartyRolePrimary {
+			if primary != nil {
+				return CustomerData{}, ConsentData{}, nil, invalidInput("parties", MsgPrimaryPartyCount)
+			}
+			primary = &PartyData{Customer: customer, Consent: consent}
+			continue
+		}
+		others = append(others, PartyData{ID: uuid.New().String(), Role: role, Customer: customer, Consent: consent})
+	}
+	if primary == nil {
+		return CustomerData{}, ConsentData{}, nil, invalidInput("parties", MsgPrimaryPartyCount)
+	}
+	return primary.Customer, primary.Consent, others, nil
+}
+
+var partiesResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	app, ok := p.Source.(*LoanApplicationData)
+	if !ok {
+		return nil, nil
+	}
+	return app.allParties(), nil
+}
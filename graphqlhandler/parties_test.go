@@ -0,0 +1,39 @@
+package graphqlhandler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLegacyDraftRecordsConsent(t *testing.T) {
+	useTenants(t, "acme")
+	ctx := requestContext(t, agentOf("acme"), "")
+	consent := map[string]interface{}{"credit_check": true, "data_processing": true}
+
+	draft := testDraft("ID410000001")
+	draft["consent"] = consent
+	app, _ := tenants.store("acme").Get(createDraft(t, ctx, draft))
+	if primary := app.allParties()[0]; !primary.Consent.complete() || primary.Consent.GivenAt.IsZero() {
+		t.Fatalf("consent of the customer field not recorded: %+v", primary.Consent)
+	}
+	for _, reason := range assessEligibility(app, BusinessRules{}).Reasons {
+		if strings.Contains(reason, "consented") {
+			t.Fatalf("legacy draft with consent reported as %q", reason)
+		}
+	}
+
+	// Without consent the application stays ineligible, as documented.
+	app, _ = tenants.store("acme").Get(createDraft(t, ctx, testDraft("ID410000002")))
+	if app.allParties()[0].Consent.complete() {
+		t.Fatal("consent recorded although none was sent")
+	}
+
+	joint := testJointDraft("ID410000003", "ID410000004")
+	joint["consent"] = consent
+	result := execute(ctx, createDraftMutation, map[string]interface{}{"d": joint})
+	if code := errorCode(result); code != ErrCodeBadRequest {
+		t.Fatalf("consent next to parties: code %q, want %s", code, ErrCodeBadRequest)
+	} else if !strings.Contains(result.Errors[0].Message, "consent can only be sent with customer") {
+		t.Fatalf("consent next to parties: %v", result.Errors)
+	}
+}
@@ -0,0 +1,169 @@
+package graphqlhandler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// erasedName replaces the full name of a customer whose data was erased.
+const erasedName = "ERASED"
+
+// CustomerExport is the document returned by exportCustomerData.
+type CustomerExport struct {
+	ExportedAt   time.Time                   `json:"exported_at"`
+	TenantID     string                      `json:"tenant_id"`
+	Applications []CustomerExportApplication `json:"applications"`
+}
+
+// CustomerExportApplication is one application of the data subject with its audit history.
+type CustomerExportApplication struct {
+	Application *LoanApplicationData `json:"application"`
+	History     []AuditEvent         `json:"history"`
+}
+
+// applicationsOfDataSubject finds the current tenant's applications for an id_number via its blind index.
+func applicationsOfDataSubject(p graphql.ResolveParams) ([]*LoanApplicationData, LoanApplicationStore, error) {
+	_, store, err := requireTenant(p.Context)
+	if err != nil {
+		return nil, nil, err
+	}
+	idNumber, _ := p.Args["id_number"].(string)
+	if idNumber == "" {
+		return nil, nil, invalidInput("id_number", MsgRequired, "id_number")
+	}
+	apps := store.FindByIDNumberIndex(blindIndex(idNumber))
+	for _, app := range apps {
+		noteApplicationUUID(p.Context, app.UUID)
+	}
+	return apps, store, nil
+}
+
+var exportCustomerDataResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	apps, _, err := applicationsOfDataSubject(p)
+	if err != nil {
+		return nil, err
+	}
+
+	export := CustomerExport{
+		ExportedAt:   time.Now(),
+		TenantID:     tenantID(p.Context),
+		Applications: make([]CustomerExportApplication, 0, len(apps)),
+	}
+	subject := blindIndex(p.Args["id_number"].(string))
+	for _, app := range apps {
+		// Other parties of the application are not part of the data subject's export.
+		forEachCustomer(app, func(_ string, customer *CustomerData) error {
+			if customer.IDNumberIndex != subject {
+				*customer = CustomerData{FullName: redactedValue}
+			}
+			customer.IDNumberIndex = "" // internal lookup key, not personal data
+			return nil
+		})
+		export.Applications = append(export.Applications, CustomerExportApplication{
+			Application: app,
+			History:     auditEventsFor(app.UUID),
+		})
+		recordAudit(p.Context, AuditActionDataExported, app.UUID)
+	}
+	return export, nil
+}
+
+// anonymizeCustomer blanks every personal field of every party while keeping the loan
+// and collateral data. Uploaded documents are dropped; it returns them so the caller can
+// delete their content once the change is stored.
+func anonymizeCustomer(app *LoanApplicationData, now time.Time) []DocumentData {
+	removed, _ := anonymizeParties(app, func(CustomerData) bool { return true }, now)
+	return removed
+}
+
+// anonymizeParties blanks the personal fields of the parties selected by match. The
+// uploaded documents and the terms acceptance belong to the primary party, so they are
+// only dropped or blanked, and CustomerErasedAt only set, when the primary party is
+// erased. It returns the removed documents and whether the primary party was erased.
+func anonymizeParties(app *LoanApplicationData, match func(CustomerData) bool, now time.Time) ([]DocumentData, bool) {
+	for i := range app.Parties {
+		if match(app.Parties[i].Customer) {
+			app.Parties[i].Customer = CustomerData{FullName: erasedName}
+		}
+	}
+	if !match(app.Customer) {
+		return nil, false
+	}
+	removed := app.Documents
+	app.Customer = CustomerData{FullName: erasedName}
+	app.Documents = nil
+	if app.TermsAcceptance != nil {
+		// The acceptance stays as evidence; who accepted is the erased customer.
+		app.TermsAcceptance.IPAddress = ""
+		app.TermsAcceptance.UserAgent = ""
+	}
+	for i := range app.Collaterals {
+		app.Collaterals[i].IsDocumentComplete = false
+		app.Collaterals[i].MissingDocuments = nil
+	}
+	app.CustomerErasedAt = &now
+	return removed, true
+}
+
+// isErased reports whether the customer data is the anonymized form left by erasure.
+func (c CustomerData) isErased() bool {
+	return c.FullName == erasedName && c.IDNumberIndex == ""
+}
+
+// erasedPartyIDs returns the IDs of the parties erased between two versions of an
+// application. The primary party's ID is the application UUID.
+func erasedPartyIDs(before, after *LoanApplicationData) map[string]bool {
+	previous := make(map[string]CustomerData)
+	for _, party := range before.allParties() {
+		previous[party.ID] = party.Customer
+	}
+	erased := make(map[string]bool)
+	for _, party := range after.allParties() {
+		if was, ok := previous[party.ID]; ok && !was.isErased() && party.Customer.isErased() {
+			erased[party.ID] = true
+		}
+	}
+	return erased
+}
+
+// eraseCustomerDataResolver anonymizes the data subject in each of their applications,
+// leaving the other parties intact. Loan records and audit events are kept for
+// compliance; active applications of an erased primary party are cancelled because they
+// can no longer be processed without the customer's data.
+var eraseCustomerDataResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	principal, err := requirePrincipal(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	apps, store, err := applicationsOfDataSubject(p)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := blindIndex(p.Args["id_number"].(string))
+	isSubject := func(customer CustomerData) bool { return customer.IDNumberIndex == subject }
+	erased := 0
+	for _, app := range apps {
+		var removed []DocumentData
+		_, err := store.Update(app.UUID, 0, func(stored *LoanApplicationData) error {
+			now := time.Now()
+			var primary bool
+			removed, primary = anonymizeParties(stored, isSubject, now)
+			if primary && (stored.Status == "DRAFT" || stored.Status == "SUBMITTED") {
+				stored.Status = "CANCELLED"
+			}
+			stored.UpdatedAt = now
+			stored.UpdatedBy = principal.UserID
+			return nil
+		})
+		if err != nil {
+			return erased, fmt.Errorf("erase loan application '%s': %w", app.UUID, err)
+		}
+		deleteDocumentBlobs(p.Context, removed)
+		erased++
+		recordAudit(p.Context, AuditActionDataErased, app.UUID)
+	}
+	return erased, nil
+}
@@ -0,0 +1,173 @@
+package graphqlhandler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const eraseMutation = `mutation($id: String!) { eraseCustomerData(id_number: $id) }`
+
+func TestEraseCustomerDataErasesOnlyTheSubject(t *testing.T) {
+	useEventSourcedTenants(t, "acme")
+	store := tenants.store("acme")
+	uuid := createDraft(t, requestContext(t, agentOf("acme"), ""), testJointDraft("ID300000001", "ID300000002"))
+	created := time.Now()
+	time.Sleep(time.Millisecond)
+
+	data := mustExecute(t, requestContext(t, adminOf("acme"), ""), eraseMutation, map[string]interface{}{"id": "ID300000002"})
+	if data["eraseCustomerData"] != 1 {
+		t.Fatalf("erased %v applications, want 1", data["eraseCustomerData"])
+	}
+
+	app, _ := store.Get(uuid)
+	if app.Status != "DRAFT" || app.CustomerErasedAt != nil {
+		t.Fatalf("erasing a co-applicant changed the application: status %s, erased at %v", app.Status, app.CustomerErasedAt)
+	}
+	if app.Customer.FullName != "John Doe" || app.Customer.IDNumber != "ID300000001" {
+		t.Fatalf("primary party changed: %+v", app.Customer)
+	}
+	if co := app.Parties[0].Customer; !co.isErased() || co.Email != "" {
+		t.Fatalf("co-applicant not erased: %+v", co)
+	}
+
+	past, found, err := store.(historicalStore).GetAsOf(uuid, created)
+	if err != nil || !found {
+		t.Fatalf("GetAsOf: found %v, %v", found, err)
+	}
+	if past.Customer.FullName != "John Doe" {
+		t.Fatalf("history of the primary party redacted: %+v", past.Customer)
+	}
+	if co := past.Parties[0].Customer; co.FullName != erasedName || co.Email != "" || co.IDNumber != "" {
+		t.Fatalf("history still holds the co-applicant: %+v", co)
+	}
+}
+
+// submittedJointApplication creates a joint application with an ID card of the primary
+// party and submits it from a known client address.
+func submittedJointApplication(t *testing.T, primaryIDNumber, coApplicantIDNumber string) string {
+	t.Helper()
+	ctx := requestContext(t, agentOf("acme"), "")
+	ctx = context.WithValue(ctx, requestInfoKey{}, &requestInfo{ClientIP: "203.0.113.7", UserAgent: "LoanApp/2.1 (test)"})
+	uuid := createDraft(t, ctx, testJointDraft(primaryIDNumber, coApplicantIDNumber))
+	mustExecute(t, withUpload(t, ctx, []byte("%PDF-1.4 id card")),
+		`mutation($u: ID!, $f: Upload!) { uploadLoanApplicationDocument(uuid: $u, type: ID_CARD, file: $f) { id } }`,
+		map[string]interface{}{"u": uuid, "f": "0"})
+	mustExecute(t, ctx, `mutation($u: ID!) {
+		submitLoanApplication(uuid: $u, consent: {channel: WEB, terms: [
+			{document: LOAN_AGREEMENT, version: "2026-01"}, {document: PRIVACY_NOTICE, version: "2026-01"}]})
+	}`, map[string]interface{}{"u": uuid})
+	return uuid
+}
+
+func TestExportCustomerData(t *testing.T) {
+	useTenants(t, "acme")
+	useTempBlobs(t)
+	uuid := submittedJointApplication(t, "ID300000011", "ID300000012")
+
+	data := mustExecute(t, requestContext(t, adminOf("acme"), ""),
+		`mutation($id: String!) { exportCustomerData(id_number: $id) }`, map[string]interface{}{"id": "ID300000011"})
+	raw, _ := json.Marshal(data["exportCustomerData"])
+	var export CustomerExport
+	if err := json.Unmarshal(raw, &export); err != nil {
+		t.Fatal(err)
+	}
+	if export.TenantID != "acme" || len(export.Applications) != 1 {
+		t.Fatalf("export of tenant %q with %d applications", export.TenantID, len(export.Applications))
+	}
+	app := export.Applications[0].Application
+	if app.UUID != uuid || app.Customer.FullName != "John Doe" || app.Customer.IDNumber != "ID300000011" || app.Customer.Email != "john@example.com" {
+		t.Fatalf("subject's data missing from the export: %+v", app.Customer)
+	}
+	if co := app.Parties[0].Customer; co.FullName != redactedValue || co.IDNumber != "" || co.Email != "" {
+		t.Fatalf("export contains another party: %+v", co)
+	}
+	if strings.Contains(string(raw), "id_number_index") || strings.Contains(string(raw), "Jane Roe") {
+		t.Fatalf("export leaks the blind index or the co-applicant: %s", raw)
+	}
+	if len(app.Documents) != 1 || app.TermsAcceptance == nil || app.TermsAcceptance.IPAddress != "203.0.113.7" {
+		t.Fatalf("export misses documents or terms acceptance: %+v, %+v", app.Documents, app.TermsAcceptance)
+	}
+	uploaded := false
+	for _, event := range export.Applications[0].History {
+		uploaded = uploaded || event.Action == AuditActionDocumentUploaded
+	}
+	if !uploaded {
+		t.Fatalf("history lacks the upload: %+v", export.Applications[0].History)
+	}
+	if !hasAuditEvent(uuid, AuditActionDataExported) {
+		t.Fatal("export not audited")
+	}
+}
+
+func hasAuditEvent(appUUID, action string) bool {
+	for _, event := range auditEventsFor(appUUID) {
+		if event.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+func TestEraseCustomerDataErasesThePrimaryParty(t *testing.T) {
+	useEventSourcedTenants(t, "acme")
+	useTempBlobs(t)
+	store := tenants.store("acme")
+	uuid := submittedJointApplication(t, "ID300000021", "ID300000022")
+	before, _ := store.Get(uuid)
+	var versions []time.Time // when each version before the erasure was recorded
+	for _, event := range backendStore(store).(*eventSourcedStore).streams[uuid] {
+		versions = append(versions, event.RecordedAt)
+	}
+
+	admin := requestContext(t, adminOf("acme"), "")
+	if data := mustExecute(t, admin, eraseMutation, map[string]interface{}{"id": "ID300000021"}); data["eraseCustomerData"] != 1 {
+		t.Fatalf("erased %v applications, want 1", data["eraseCustomerData"])
+	}
+
+	app, _ := store.Get(uuid)
+	if app.Status != "CANCELLED" || app.CustomerErasedAt == nil {
+		t.Fatalf("status %s, erased at %v", app.Status, app.CustomerErasedAt)
+	}
+	if !app.Customer.isErased() || app.Customer.Email != "" || app.Customer.DateOfBirth != "" || app.Customer.Address.Street != "" {
+		t.Fatalf("customer not erased: %+v", app.Customer)
+	}
+	if co := app.Parties[0].Customer; co.FullName != "Jane Roe" || co.IDNumber != "ID300000022" {
+		t.Fatalf("co-applicant changed: %+v", co)
+	}
+	if len(app.Documents) != 0 {
+		t.Fatalf("documents kept: %+v", app.Documents)
+	}
+	if _, err := documentBlobs.Get(context.Background(), before.Documents[0].BlobKey); !errors.Is(err, errBlobNotFound) {
+		t.Fatalf("document content kept: %v", err)
+	}
+	if terms := app.TermsAcceptance; terms == nil || terms.IPAddress != "" || terms.UserAgent != "" || len(terms.Terms) != 2 {
+		t.Fatalf("terms acceptance %+v, want versions without client address", terms)
+	}
+	if !hasAuditEvent(uuid, AuditActionDataErased) {
+		t.Fatal("erasure not audited")
+	}
+	if len(store.FindByIDNumberIndex(blindIndex("ID300000021"))) != 0 {
+		t.Fatal("erased customer can still be found by id_number")
+	}
+
+	// The history before the erasure no longer holds the primary party either.
+	for _, at := range versions {
+		past, found, err := store.(historicalStore).GetAsOf(uuid, at)
+		if err != nil || !found {
+			t.Fatalf("GetAsOf(%v): found %v, %v", at, found, err)
+		}
+		raw, _ := json.Marshal(past)
+		for _, value := range []string{"John Doe", "ID300000021", "john@example.com", "203.0.113.7", "LoanApp/2.1", before.Documents[0].ID} {
+			if strings.Contains(string(raw), value) {
+				t.Errorf("state as of %v still contains %q", at, value)
+			}
+		}
+		if !strings.Contains(string(raw), "Jane Roe") {
+			t.Errorf("state as of %v lost the co-applicant", at)
+		}
+	}
+}
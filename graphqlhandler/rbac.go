@@ -0,0 +1,238 @@
+package graphqlhandler
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/graphql-go/graphql"
+)
+
+// Roles carried in the "roles" claim of the access token.
+const (
+	RoleSalesAgent  = "SALES_AGENT"
+	RoleUnderwriter = "UNDERWRITER"
+	RoleAuditor     = "AUDITOR"
+	RoleAdmin       = "ADMIN"
+	RoleCustomer    = "CUSTOMER" // Applicant using the mobile app; restricted to own applications
+)
+
+// publicOperations may be called without authentication.
+var publicOperations = map[string]bool{
+	"healthCheck": true,
+}
+
+// operationPolicy lists the roles allowed to call each query, mutation and subscription.
+// Every root field must appear here or in publicOperations; schema construction panics otherwise.
+var operationPolicy = map[string][]string{
+	"getLoanApplication":            {RoleCustomer, RoleSalesAgent, RoleUnderwriter, RoleAuditor, RoleAdmin},
+	"listLoanApplications":          {RoleCustomer, RoleSalesAgent, RoleUnderwriter, RoleAuditor, RoleAdmin},
+	"createLoanApplicationDraft":    {RoleCustomer, RoleSalesAgent, RoleAdmin},
+	"submitLoanApplication":         {RoleCustomer, RoleSalesAgent, RoleAdmin},
+	"cancelLoanApplication":         {RoleCustomer, RoleSalesAgent, RoleUnderwriter, RoleAdmin},
+	"approveLoanApplication":        {RoleUnderwriter, RoleAdmin},
+	"rejectLoanApplication":         {RoleUnderwriter, RoleAdmin},
+	"uploadLoanApplicationDocument": {RoleCustomer, RoleSalesAgent, RoleAdmin},
+	"reviewLoanApplicationDocument": {RoleUnderwriter, RoleAdmin},
+	"reencryptCustomerData":         {RoleAdmin},
+	"rebuildProjections":            {RoleAdmin},
+	"exportCustomerData":            {RoleAdmin},
+	"eraseCustomerData":             {RoleAdmin},
+	"lookupPostalCode":              {RoleCustomer, RoleSalesAgent, RoleUnderwriter, RoleAdmin},
+	"termsDocuments":                {RoleCustomer, RoleSalesAgent, RoleUnderwriter, RoleAuditor, RoleAdmin},
+	"vehicleBrands":                 {RoleCustomer, RoleSalesAgent, RoleUnderwriter, RoleAdmin},
+	"vehicleVariants":               {RoleCustomer, RoleSalesAgent, RoleUnderwriter, RoleAdmin},
+	"upsertVehicleVariant":          {RoleAdmin},
+	"deleteVehicleVariant":          {RoleAdmin},
+	"webhooks":                      {RoleAdmin},
+	"webhookDeadLetters":            {RoleAdmin},
+	"registerWebhook":               {RoleAdmin},
+	"deleteWebhook":                 {RoleAdmin},
+	"redeliverWebhookDeadLetter":    {RoleAdmin},
+
+	"loanApplicationStatusChanged": {RoleCustomer, RoleSalesAgent, RoleUnderwriter, RoleAuditor, RoleAdmin},
+	"loanApplicationEvents":        {RoleCustomer, RoleSalesAgent, RoleUnderwriter, RoleAuditor, RoleAdmin},
+}
+
+// platformOperations act on every tenant. Callers whose token is bound to a tenant may
+// not call them, whatever their role.
+var platformOperations = map[string]bool{
+	"reencryptCustomerData": true,
+	"rebuildProjections":    true,
+	"upsertVehicleVariant":  true, // the vehicle catalog is shared by every tenant
+	"deleteVehicleVariant":  true,
+}
+
+// piiRawAccessRoles may read customer PII unmasked. Customers only ever reach
+// their own applications (see canAccessApplication), so they see their own data raw.
+var piiRawAccessRoles = []string{RoleCustomer, RoleUnderwriter, RoleAdmin}
+
+// staffRoles are back-office roles that are not limited to applications they own.
+var staffRoles = []string{RoleSalesAgent, RoleUnderwriter, RoleAuditor, RoleAdmin}
+
+func hasAnyRole(principal *Principal, roles []string) bool {
+	for _, role := range roles {
+		if principal.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// ownsApplicationsOnly reports whether the caller is an applicant without any staff role.
+func ownsApplicationsOnly(principal *Principal) bool {
+	return principal.HasRole(RoleCustomer) && !hasAnyRole(principal, staffRoles)
+}
+
+// canAccessApplication enforces ownership for customer callers. Resolvers treat a
+// false result exactly like an unknown UUID.
+func canAccessApplication(ctx context.Context, app *LoanApplicationData) bool {
+	return canAccessOwnedBy(ctx, app.OwnerID)
+}
+
+// canAccessOwnedBy is canAccessApplication for callers that only know the owner.
+func canAccessOwnedBy(ctx context.Context, ownerID string) bool {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return false
+	}
+	if ownsApplicationsOnly(principal) {
+		return ownerID == principal.UserID
+	}
+	return true
+}
+
+// authorize wraps a root field resolver with the role check from operationPolicy.
+func authorize(operation string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	roles := operationPolicy[operation]
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		principal, err := requirePrincipal(p.Context)
+		if err != nil {
+			return nil, localizeError(p.Context, err)
+		}
+		if !hasAnyRole(principal, roles) {
+			return nil, localizeError(p.Context, newLocalizedAPIError(ErrCodeForbidden, MsgOperationForbidden, operation))
+		}
+		if platformOperations[operation] && principal.TenantID != "" {
+			return nil, localizeError(p.Context, newLocalizedAPIError(ErrCodeForbidden, MsgTenantBoundOperation, operation))
+		}
+		result, err := resolve(p)
+		return result, localizeError(p.Context, err)
+	}
+}
+
+// withPolicy applies authorize to every field of a root object so that no
+// operation can be added without an explicit policy entry. Subscription fields
+// are checked both when subscribing and for every event.
+func withPolicy(fields graphql.Fields) graphql.Fields {
+	for name, field := range fields {
+		if publicOperations[name] {
+			continue
+		}
+		if _, ok := operationPolicy[name]; !ok {
+			panic(fmt.Sprintf("no authorization policy defined for %q", name))
+		}
+		field.Resolve = authorize(name, field.Resolve)
+		if field.Subscribe != nil {
+			field.Subscribe = authorize(name, field.Subscribe)
+		}
+	}
+	return fields
+}
+
+// --- Field-level PII masking ---
+
+// canViewRawPII reports whether the caller may see customer PII unmasked.
+func canViewRawPII(ctx context.Context) bool {
+	principal, ok := PrincipalFromContext(ctx)
+	return ok && hasAnyRole(principal, piiRawAccessRoles)
+}
+
+// maskString hides all but the last `visible` characters, e.g. "****6789".
+func maskString(value string, visible int) string {
+	if utf8.RuneCountInString(value) <= visible {
+		return "****"
+	}
+	runes := []rune(value)
+	return "****" + string(runes[len(runes)-visible:])
+}
+
+func customerSource(source interface{}) (*CustomerData, bool) {
+	switch c := source.(type) {
+	case CustomerData:
+		return &c, true
+	case *CustomerData:
+		return c, c != nil
+	}
+	return nil, false
+}
+
+// maskedCustomerField returns a resolver for a Customer field that masks the
+// value with mask unless the caller is allowed raw access.
+func maskedCustomerField(get func(*CustomerData) interface{}, mask func(*CustomerData) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		customer, ok := customerSource(p.Source)
+		if !ok {
+			return nil, fmt.Errorf("unexpected customer source %T", p.Source)
+		}
+		if canViewRawPII(p.Context) {
+			return get(customer), nil
+		}
+		return mask(customer), nil
+	}
+}
+
+var customerIDNumberResolver = maskedCustomerField(
+	func(c *CustomerData) interface{} { return c.IDNumber },
+	func(c *CustomerData) interface{} { return maskString(c.IDNumber, 4) },
+)
+
+var customerPhoneResolver = maskedCustomerField(
+	func(c *CustomerData) interface{} { return c.Phone },
+	func(c *CustomerData) interface{} { return maskString(c.Phone, 4) },
+)
+
+var customerDateOfBirthResolver = maskedCustomerField(
+	func(c *CustomerData) interface{} { return c.DateOfBirth },
+	func(c *CustomerData) interface{} { return "****-**-**" },
+)
+
+var customerGenderResolver = maskedCustomerField(
+	func(c *CustomerData) interface{} {
+		if c.Gender == "" {
+			return nil
+		}
+		return c.Gender
+	},
+	func(c *CustomerData) interface{} { return nil },
+)
+
+// financialCustomerField returns a resolver for a financial Customer field. It is null
+// when the customer provided no financial data or the caller may not see raw PII;
+// the derived debt-to-income ratio stays visible to everyone.
+func financialCustomerField(get func(*CustomerData) interface{}) graphql.FieldResolveFn {
+	return maskedCustomerField(
+		func(c *CustomerData) interface{} {
+			if !c.hasFinancials() {
+				return nil
+			}
+			return get(c)
+		},
+		func(c *CustomerData) interface{} { return nil },
+	)
+}
+
+// The city and province stay visible so that branch staff can still route the
+// application; the street, district, sub-district, zipcode and coordinates do not.
+var customerAddressResolver = maskedCustomerField(
+	func(c *CustomerData) interface{} { return c.Address },
+	func(c *CustomerData) interface{} {
+		return AddressData{
+			Street:   maskString(c.Address.Street, 0),
+			City:     c.Address.City,
+			Province: c.Address.Province,
+			Zipcode:  maskString(c.Address.Zipcode, 0),
+			Country:  c.Address.Country,
+		}
+	},
+)
@@ -0,0 +1,276 @@
+package graphqlhandler
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+)
+
+// bundledRegions holds the region reference datasets shipped with the server, one
+// <country>.json file per country. The Indonesian file is a sample covering a few
+// cities; production deployments load the complete dataset with LoadRegionsFile.
+//
+//go:embed regions/*.json
+var bundledRegions embed.FS
+
+// RegionDataset is the administrative hierarchy of a country: provinces, cities or
+// regencies, districts (kecamatan) and sub-districts (kelurahan or desa) with their
+// postal codes.
+type RegionDataset struct {
+	Country   string     `json:"country"`
+	Bounds    *GeoBounds `json:"bounds,omitempty"`
+	Provinces []struct {
+		Name   string `json:"name"`
+		Cities []struct {
+			Name      string `json:"name"`
+			Districts []struct {
+				Name         string `json:"name"`
+				SubDistricts []struct {
+					Name       string `json:"name"`
+					PostalCode string `json:"postal_code"`
+				} `json:"sub_districts"`
+			} `json:"districts"`
+		} `json:"cities"`
+	} `json:"provinces"`
+}
+
+// GeoBounds is the bounding box addresses of a country must lie in.
+type GeoBounds struct {
+	MinLatitude  float64 `json:"min_latitude"`
+	MaxLatitude  float64 `json:"max_latitude"`
+	MinLongitude float64 `json:"min_longitude"`
+	MaxLongitude float64 `json:"max_longitude"`
+}
+
+func (b GeoBounds) contains(point GeoPoint) bool {
+	return point.Latitude >= b.MinLatitude && point.Latitude <= b.MaxLatitude &&
+		point.Longitude >= b.MinLongitude && point.Longitude <= b.MaxLongitude
+}
+
+// PostalRegion is one sub-district and the regions above it. A postal code may cover
+// several sub-districts.
+type PostalRegion struct {
+	Country     string `json:"country"`
+	Province    string `json:"province"`
+	City        string `json:"city"`
+	District    string `json:"district"`
+	SubDistrict string `json:"sub_district"`
+	PostalCode  string `json:"postal_code"`
+}
+
+// regionIndex is a dataset indexed by postal code.
+type regionIndex struct {
+	bounds       *GeoBounds
+	byPostalCode map[string][]PostalRegion
+}
+
+var (
+	regionsMu sync.RWMutex
+	regions   = map[string]*regionIndex{}
+)
+
+func init() {
+	files, err := bundledRegions.ReadDir("regions")
+	if err != nil {
+		panic(err)
+	}
+	for _, file := range files {
+		raw, err := bundledRegions.ReadFile("regions/" + file.Name())
+		if err != nil {
+			panic(err)
+		}
+		if err := loadRegions(raw); err != nil {
+			panic(fmt.Sprintf("bundled %s: %v", file.Name(), err))
+		}
+	}
+}
+
+// LoadRegionsFile replaces the region dataset of the country the file describes.
+func LoadRegionsFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("regions: read file: %w", err)
+	}
+	if err := loadRegions(raw); err != nil {
+		return fmt.Errorf("regions: %s: %w", path, err)
+	}
+	return nil
+}
+
+func loadRegions(raw []byte) error {
+	var dataset RegionDataset
+	if err := json.Unmarshal(raw, &dataset); err != nil {
+		return fmt.Errorf("parse file: %w", err)
+	}
+	if !countryCodePattern.MatchString(dataset.Country) {
+		return fmt.Errorf("country %q is not an ISO 3166-1 alpha-2 code", dataset.Country)
+	}
+	validator, _ := countryValidatorFor(dataset.Country)
+	index := &regionIndex{bounds: dataset.Bounds, byPostalCode: map[string][]PostalRegion{}}
+	for _, province := range dataset.Provinces {
+		for _, city := range province.Cities {
+			for _, district := range city.Districts {
+				for _, sub := range district.SubDistricts {
+					if err := validator.ValidatePostalCode(sub.PostalCode); err != nil {
+						return fmt.Errorf("sub-district %q of %s: %w", sub.Name, district.Name, err)
+					}
+					index.byPostalCode[sub.PostalCode] = append(index.byPostalCode[sub.PostalCode], PostalRegion{
+						Country:     dataset.Country,
+						Province:    province.Name,
+						City:        city.Name,
+						District:    district.Name,
+						SubDistrict: sub.Name,
+						PostalCode:  sub.PostalCode,
+					})
+				}
+			}
+		}
+	}
+	if len(index.byPostalCode) == 0 {
+		return fmt.Errorf("no postal codes for %s", dataset.Country)
+	}
+	regionsMu.Lock()
+	defer regionsMu.Unlock()
+	regions[dataset.Country] = index
+	return nil
+}
+
+// regionsFor returns the dataset of a country, if one is loaded.
+func regionsFor(country string) (*regionIndex, bool) {
+	regionsMu.RLock()
+	defer regionsMu.RUnlock()
+	index, ok := regions[country]
+	return index, ok
+}
+
+// regionNamePrefixes are dropped when names are compared, so "Jakarta Pusat" matches
+// "Kota Administrasi Jakarta Pusat" and "Kec. Gambir" matches "Gambir".
+var regionNamePrefixes = []string{
+	"provinsi ", "prov. ", "kota administrasi ", "kota adm. ", "kabupaten administrasi ",
+	"kabupaten ", "kab. ", "kota ", "kecamatan ", "kec. ", "kelurahan ", "kel. ", "desa ",
+}
+
+// sameRegionName compares region names ignoring case, punctuation spacing and the
+// administrative prefixes.
+func sameRegionName(a, b string) bool {
+	return regionNameKey(a) == regionNameKey(b)
+}
+
+func regionNameKey(name string) string {
+	key := strings.ToLower(normalizeText(name))
+	for _, prefix := range regionNamePrefixes {
+		if trimmed, ok := strings.CutPrefix(key, prefix); ok {
+			key = trimmed
+			break
+		}
+	}
+	return strings.ReplaceAll(key, ".", "")
+}
+
+// matchRegions returns the regions of the address's postal code that agree with the
+// province, city, district and sub-district it names. It reports the first field that
+// contradicts the postal code. A dataset may be incomplete, so a postal code it does
+// not list matches nothing and is not checked further.
+func (index *regionIndex) matchRegions(input map[string]interface{}) ([]PostalRegion, error) {
+	zipcode, _ := input["zipcode"].(string)
+	candidates := index.byPostalCode[zipcode]
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	for _, level := range []struct {
+		field string
+		name  func(PostalRegion) string
+	}{
+		{"city", func(r PostalRegion) string { return r.City }},
+		{"province", func(r PostalRegion) string { return r.Province }},
+		{"district", func(r PostalRegion) string { return r.District }},
+		{"sub_district", func(r PostalRegion) string { return r.SubDistrict }},
+	} {
+		value, _ := input[level.field].(string)
+		if value == "" {
+			continue
+		}
+		var matching []PostalRegion
+		for _, candidate := range candidates {
+			if sameRegionName(level.name(candidate), value) {
+				matching = append(matching, candidate)
+			}
+		}
+		if len(matching) == 0 {
+			return nil, invalidInput(level.field, MsgRegionMismatch, level.field, value, zipcode)
+		}
+		candidates = matching
+	}
+	return candidates, nil
+}
+
+// standardizeAddress replaces the region names of an address with those of the
+// dataset and fills in the levels its postal code determines.
+func standardizeAddress(address *AddressData, input map[string]interface{}) {
+	index, ok := regionsFor(address.Country)
+	if !ok {
+		return
+	}
+	matches, err := index.matchRegions(input)
+	if err != nil || len(matches) == 0 {
+		return // rejected by validateAddressInput
+	}
+	common := func(name func(PostalRegion) string) string {
+		for _, match := range matches[1:] {
+			if name(match) != name(matches[0]) {
+				return ""
+			}
+		}
+		return name(matches[0])
+	}
+	address.Province = common(func(r PostalRegion) string { return r.Province })
+	address.City = common(func(r PostalRegion) string { return r.City })
+	address.District = common(func(r PostalRegion) string { return r.District })
+	address.SubDistrict = common(func(r PostalRegion) string { return r.SubDistrict })
+}
+
+// validateGeoPoint checks the optional coordinates of an address.
+func validateGeoPoint(input map[string]interface{}, country string) error {
+	geo, ok := input["geo"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	point := newGeoPoint(geo)
+	if point.Latitude < -90 || point.Latitude > 90 {
+		return invalidField("geo", invalidInput("latitude", MsgAmountOutOfRange, "latitude", -90.0, 90.0))
+	}
+	if point.Longitude < -180 || point.Longitude > 180 {
+		return invalidField("geo", invalidInput("longitude", MsgAmountOutOfRange, "longitude", -180.0, 180.0))
+	}
+	if index, ok := regionsFor(country); ok && index.bounds != nil && !index.bounds.contains(point) {
+		return invalidInput("geo", MsgOutsideCountry, country)
+	}
+	return nil
+}
+
+func newGeoPoint(input map[string]interface{}) GeoPoint {
+	latitude, _ := input["latitude"].(float64)
+	longitude, _ := input["longitude"].(float64)
+	return GeoPoint{Latitude: latitude, Longitude: longitude}
+}
+
+// lookupPostalCodeResolver returns the regions of a postal code, so that forms can
+// fill in the province, city, district and sub-district.
+var lookupPostalCodeResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	zipcode, _ := p.Args["zipcode"].(string)
+	country, _ := p.Args["country"].(string)
+	index, ok := regionsFor(country)
+	if !ok {
+		return []PostalRegion{}, nil
+	}
+	matches := index.byPostalCode[normalizeText(zipcode)]
+	if matches == nil {
+		return []PostalRegion{}, nil
+	}
+	return matches, nil
+}
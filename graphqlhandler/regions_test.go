@@ -0,0 +1,26 @@
+package graphqlhandler
+
+import "testing"
+
+func TestValidateAddressInputAgainstRegions(t *testing.T) {
+	tests := []struct {
+		name    string
+		city    string
+		zipcode string
+		code    string // expected validation code, "" when valid
+	}{
+		{"matching city", "Jakarta Pusat", "10110", ""},
+		{"city contradicts zipcode", "Bandung", "10110", MsgRegionMismatch},
+		// The bundled dataset is a sample, so zipcodes it lacks are only format checked.
+		{"zipcode missing from the dataset", "Medan", "20111", ""},
+		{"malformed zipcode", "Medan", "2011", MsgIndonesianPostalCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := map[string]interface{}{"street": "Jl. Merdeka 1", "city": tt.city, "zipcode": tt.zipcode}
+			if got := validationCode(validateAddressInput(input, "ID")); got != tt.code {
+				t.Fatalf("got code %q, want %q", got, tt.code)
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package graphqlhandler
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"regexp"
 	"time"
 
@@ -22,89 +24,137 @@ func isValidEmail(emailStr string) bool {
 	return regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`).MatchString(emailStr)
 }
 
-func validateAddressInput(input map[string]interface{}) error {
+// addressCountry returns the country of an address, which defaults to the customer's.
+func addressCountry(input map[string]interface{}, customerCountry string) string {
+	if country, _ := input["country"].(string); country != "" {
+		return country
+	}
+	return customerCountry
+}
+
+func validateAddressInput(input map[string]interface{}, customerCountry string) error {
 	street, _ := input["street"].(string)
 	city, _ := input["city"].(string)
 	zipcode, _ := input["zipcode"].(string)
 
-	if len(street) == 0 || len(street) > 200 {
-		return fmt.Errorf("street must be 1-200 characters")
+	if err := validateText("street", street, 1, 200); err != nil {
+		return err
+	}
+	if err := validateText("city", city, 1, 100); err != nil {
+		return err
+	}
+	for _, field := range []string{"province", "district", "sub_district"} {
+		value, _ := input[field].(string)
+		if err := validateText(field, value, 0, 100); err != nil {
+			return err
+		}
 	}
-	if len(city) == 0 || len(city) > 100 {
-		return fmt.Errorf("city must be 1-100 characters")
+	country := addressCountry(input, customerCountry)
+	validator, err := countryValidatorFor(country)
+	if err != nil {
+		return err
 	}
-	if len(zipcode) < 3 || len(zipcode) > 10 {
-		return fmt.Errorf("zipcode must be 3-10 characters")
+	if err := validator.ValidatePostalCode(zipcode); err != nil {
+		return err
+	}
+	if err := validateGeoPoint(input, country); err != nil {
+		return err
+	}
+	if index, ok := regionsFor(country); ok {
+		if _, err := index.matchRegions(input); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// validateCustomerInput validates a customer with the rules of the customer's country
+// (see countries.go). Strings are expected to be normalized (see text.go).
 func validateCustomerInput(input map[string]interface{}) error {
 	fullName, _ := input["full_name"].(string)
 	dob, _ := input["date_of_birth"].(string)
 	idNumber, _ := input["id_number"].(string)
 	email, _ := input["email"].(string) // email can be empty string if not provided
 	phone, _ := input["phone"].(string)
+	country, _ := input["country"].(string)
+	gender, _ := input["gender"].(string)
 
-	if !regexp.MustCompile(`^[a-zA-Z ]{3,100}$`).MatchString(fullName) {
-		return fmt.Errorf("full_name must be 3-100 characters, alphabet and space only")
+	validator, err := countryValidatorFor(country)
+	if err != nil {
+		return err
+	}
+	if err := validateName("full_name", fullName); err != nil {
+		return err
 	}
 	if !isValidDate(dob) {
-		return fmt.Errorf("date_of_birth must be in YYYY-MM-DD format")
+		return invalidInput("date_of_birth", MsgDateFormat, "date_of_birth")
 	}
-	if len(idNumber) == 0 || len(idNumber) > 25 {
-		return fmt.Errorf("id_number must be 1-25 characters")
+	if err := validator.ValidateNationalID(idNumber, dob, gender); err != nil {
+		return err
 	}
 	if email != "" && !isValidEmail(email) { // Validate only if email is provided
-		return fmt.Errorf("email is not valid")
+		return invalidInput("email", MsgInvalidEmail)
 	}
-	if !regexp.MustCompile(`^[0-9]{6,30}$`).MatchString(phone) {
-		return fmt.Errorf("phone must be 6-30 digits")
+	if _, err := validator.NormalizePhone(phone); err != nil {
+		return err
 	}
 
 	addressInput, ok := input["address"].(map[string]interface{})
 	if !ok {
-		return fmt.Errorf("address is required")
+		return invalidInput("address", MsgRequired, "address")
 	}
-	if err := validateAddressInput(addressInput); err != nil {
-		return fmt.Errorf("invalid address: %w", err)
+	if err := validateAddressInput(addressInput, country); err != nil {
+		return invalidField("address", err)
 	}
-	return nil
+	return validateFinancialInput(input)
 }
 
-func validateCollateralInput(input map[string]interface{}) error {
+func validateCollateralInput(input map[string]interface{}, rules BusinessRules) error {
+	category, _ := input["category"].(string)
 	brand, _ := input["brand"].(string)
 	variant, _ := input["variant"].(string)
 	mfgYear, okInt := input["manufacturing_year"].(int)
 
-	if len(brand) == 0 {
-		return fmt.Errorf("brand is required")
+	if !hasMakeAndModel(category) {
+		for _, field := range []string{"brand", "model", "variant", "manufacturing_year"} {
+			if input[field] != nil {
+				return invalidInput(field, MsgNotForCategory, field, category)
+			}
+		}
+		return validateCollateralDetails(input)
 	}
-	if len(variant) == 0 {
-		return fmt.Errorf("variant is required")
+	if err := validateText("brand", brand, 1, 100); err != nil {
+		return err
+	}
+	if model, ok := input["model"].(string); ok {
+		if err := validateText("model", model, 1, 100); err != nil {
+			return err
+		}
+	}
+	if err := validateText("variant", variant, 1, 100); err != nil {
+		return err
 	}
 	currentYear := time.Now().Year()
-	if !okInt || mfgYear < 2020 || mfgYear > currentYear {
-		return fmt.Errorf("manufacturing_year must be between 2020 and %d", currentYear)
+	if !okInt || mfgYear < rules.MinManufacturingYear || mfgYear > currentYear {
+		return invalidInput("manufacturing_year", MsgOutOfRange, "manufacturing_year", rules.MinManufacturingYear, currentYear)
 	}
-	_, okBool := input["is_document_complete"].(bool)
-	if !okBool {
-		// This case should ideally be caught by GraphQL type system for non-null boolean
-		return fmt.Errorf("is_document_complete is required and must be a boolean")
+	if err := validateCatalogVehicle(input); err != nil {
+		return err
 	}
-	// Category is enum, handled by GraphQL type system
-	return nil
+	// Category is enum, handled by GraphQL type system; is_document_complete is ignored
+	// because it is derived from the uploaded documents.
+	return validateCollateralDetails(input)
 }
 
-func validateProposedLoanInput(input map[string]interface{}) error {
+func validateProposedLoanInput(input map[string]interface{}, rules BusinessRules) error {
 	tenure, okInt := input["tenure"].(int)
 	amount, okFloat := input["amount"].(float64)
 
-	if !okInt || tenure < 3 || tenure > 60 || tenure%3 != 0 {
-		return fmt.Errorf("tenure must be between 3 and 60, and divisible by 3")
+	if !okInt || tenure < rules.MinTenure || tenure > rules.MaxTenure || tenure%rules.TenureStep != 0 {
+		return invalidInput("tenure", MsgTenureOutOfRange, rules.MinTenure, rules.MaxTenure, rules.TenureStep)
 	}
-	if !okFloat || amount < 100 || amount > 50000 {
-		return fmt.Errorf("amount must be between 100 and 50000")
+	if !okFloat || amount < rules.MinAmount || amount > rules.MaxAmount {
+		return invalidInput("amount", MsgAmountOutOfRange, "amount", rules.MinAmount, rules.MaxAmount)
 	}
 	return nil
 }
@@ -116,79 +166,111 @@ var healthCheckResolver = func(p graphql.ResolveParams) (interface{}, error) {
 }
 
 var createLoanApplicationDraftResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	principal, err := requirePrincipal(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	tenant, store, err := requireTenant(p.Context)
+	if err != nil {
+		return nil, err
+	}
 	dataArg, ok := p.Args["data"].(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("missing 'data' argument")
 	}
+	normalizeInput(dataArg)
 
-	// Validate inputs
+	// Validate inputs against the tenant's business rules
 	proposedLoanInput, _ := dataArg["proposed_loan"].(map[string]interface{})
-	collateralInput, _ := dataArg["collateral"].(map[string]interface{})
-	customerInput, _ := dataArg["customer"].(map[string]interface{})
 
-	if err := validateProposedLoanInput(proposedLoanInput); err != nil {
-		return nil, fmt.Errorf("invalid proposed_loan: %w", err)
+	if err := validateProposedLoanInput(proposedLoanInput, tenant.BusinessRules); err != nil {
+		return nil, invalidField("proposed_loan", err)
 	}
-	if err := validateCollateralInput(collateralInput); err != nil {
-		return nil, fmt.Errorf("invalid collateral: %w", err)
+	collaterals, err := draftCollaterals(dataArg, tenant.BusinessRules)
+	if err != nil {
+		return nil, err
 	}
-	if err := validateCustomerInput(customerInput); err != nil {
-		return nil, fmt.Errorf("invalid customer: %w", err)
+	now := time.Now()
+	customer, consent, parties, err := draftParties(dataArg, now)
+	if err != nil {
+		return nil, err
+	}
+
+	category := collaterals[0].Category
+	tenure := proposedLoanInput["tenure"].(int)
+	interestRate, ok := tenant.RateCard.Rate(category, tenure)
+	if !ok {
+		return nil, invalidField("proposed_loan", invalidInput("tenure", MsgNoRate, category, tenure))
 	}
 
 	// Map input to data structure
 	appUUID := uuid.New().String()
-	now := time.Now()
-
-	customerAddrInput := customerInput["address"].(map[string]interface{})
 	newApp := &LoanApplicationData{
 		UUID:   appUUID,
 		Status: "DRAFT",
 		ProposedLoan: ProposedLoanData{
-			Tenure: proposedLoanInput["tenure"].(int),
-			Amount: proposedLoanInput["amount"].(float64),
-		},
-		Collateral: CollateralData{
-			Category:           collateralInput["category"].(string),
-			Brand:              collateralInput["brand"].(string),
-			Variant:            collateralInput["variant"].(string),
-			ManufacturingYear:  collateralInput["manufacturing_year"].(int),
-			IsDocumentComplete: collateralInput["is_document_complete"].(bool),
-		},
-		Customer: CustomerData{
-			FullName:    customerInput["full_name"].(string),
-			DateOfBirth: customerInput["date_of_birth"].(string),
-			IDNumber:    customerInput["id_number"].(string),
-			Email:       customerInput["email"].(string), // Already asserted as string or empty
-			Phone:       customerInput["phone"].(string),
-			Address: AddressData{
-				Street:  customerAddrInput["street"].(string),
-				City:    customerAddrInput["city"].(string),
-				Zipcode: customerAddrInput["zipcode"].(string),
-			},
+			Tenure:       tenure,
+			Amount:       proposedLoanInput["amount"].(float64),
+			InterestRate: interestRate,
 		},
-		CreatedAt: now,
-		UpdatedAt: now,
+		Collaterals: collaterals,
+		Customer:    customer,
+		Consent:     consent,
+		Parties:     parties,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		CreatedBy:   principal.UserID,
+		UpdatedBy:   principal.UserID,
+		OwnerID:     principal.UserID,
+		TenantID:    tenant.ID,
+	}
+
+	if err := validateLoanToValue(newApp, tenant.BusinessRules); err != nil {
+		return nil, err
+	}
+	if err := validateAffordability(newApp, tenant.BusinessRules); err != nil {
+		return nil, err
 	}
+	refreshDocumentCompleteness(newApp, tenant.RequiredDocuments)
 
-	loanApplicationsMutex.Lock()
-	loanApplications[appUUID] = newApp
-	loanApplicationsMutex.Unlock()
+	if err := store.Create(newApp); err != nil {
+		return nil, err
+	}
 
+	noteApplicationUUID(p.Context, appUUID)
+	recordAudit(p.Context, AuditActionCreated, appUUID)
 	return appUUID, nil
 }
 
 var getLoanApplicationResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	if _, err := requirePrincipal(p.Context); err != nil {
+		return nil, err
+	}
+	_, store, err := requireTenant(p.Context)
+	if err != nil {
+		return nil, err
+	}
 	uuidArg, ok := p.Args["uuid"].(string)
 	if !ok {
 		return nil, fmt.Errorf("missing 'uuid' argument")
 	}
+	noteApplicationUUID(p.Context, uuidArg)
 
-	loanApplicationsMutex.RLock()
-	app, exists := loanApplications[uuidArg]
-	loanApplicationsMutex.RUnlock()
-
-	if !exists {
+	app, exists := store.Get(uuidArg)
+	if asOfArg, ok := p.Args["asOf"].(string); ok {
+		asOf, parseErr := time.Parse(time.RFC3339, asOfArg)
+		if parseErr != nil {
+			return nil, newLocalizedAPIError(ErrCodeBadRequest, MsgTimestampFormat, "asOf")
+		}
+		history, ok := store.(historicalStore)
+		if !ok {
+			return nil, errNoHistory
+		}
+		if app, exists, err = history.GetAsOf(uuidArg, asOf); err != nil {
+			return nil, err
+		}
+	}
+	if !exists || !canAccessApplication(p.Context, app) {
 		return nil, nil // GraphQL spec: return null if not found for nullable type
 	}
 	// Map internal struct to the format expected by graphql.Field resolver
@@ -197,61 +279,217 @@ var getLoanApplicationResolver = func(p graphql.ResolveParams) (interface{}, err
 	return app, nil
 }
 
-var submitLoanApplicationResolver = func(p graphql.ResolveParams) (interface{}, error) {
-	uuidArg, ok := p.Args["uuid"].(string)
-	if !ok {
-		return nil, fmt.Errorf("missing 'uuid' argument")
+var listLoanApplicationsResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	if _, err := requirePrincipal(p.Context); err != nil {
+		return nil, err
 	}
-
-	loanApplicationsMutex.Lock()
-	defer loanApplicationsMutex.Unlock()
-
-	app, exists := loanApplications[uuidArg]
-	if !exists {
-		return false, fmt.Errorf("loan application with UUID '%s' not found", uuidArg)
+	_, store, err := requireTenant(p.Context)
+	if err != nil {
+		return nil, err
 	}
-
-	if app.Status != "DRAFT" {
-		// Depending on business logic, could allow submission from other statuses or return error
-		return false, fmt.Errorf("loan application status is '%s', cannot submit", app.Status)
+	status, _ := p.Args["status"].(string)
+	limit, _ := p.Args["limit"].(int)
+	offset, _ := p.Args["offset"].(int)
+	if limit < 1 || limit > 100 {
+		return nil, invalidInput("limit", MsgOutOfRange, "limit", 1, 100)
+	}
+	if offset < 0 {
+		return nil, invalidInput("offset", MsgNotNegative, "offset")
 	}
 
-	app.Status = "SUBMITTED"
-	app.UpdatedAt = time.Now()
-	loanApplications[uuidArg] = app // Re-assign pointer if needed, though map stores pointer
-
-	return true, nil
+	apps := []*LoanApplicationData{}
+	for _, app := range store.List() {
+		if status != "" && app.Status != status {
+			continue
+		}
+		if !canAccessApplication(p.Context, app) {
+			continue
+		}
+		apps = append(apps, app)
+	}
+	if offset >= len(apps) {
+		return []*LoanApplicationData{}, nil
+	}
+	apps = apps[offset:]
+	if len(apps) > limit {
+		apps = apps[:limit]
+	}
+	for _, app := range apps {
+		noteApplicationUUID(p.Context, app.UUID)
+	}
+	return apps, nil
 }
 
-var cancelLoanApplicationResolver = func(p graphql.ResolveParams) (interface{}, error) {
+// updateLoanApplication applies mutate to the caller's application inside the tenant store.
+// Unknown UUIDs and applications the caller may not access both yield NOT_FOUND; a stale
+// expectedVersion yields CONFLICT.
+func updateLoanApplication(p graphql.ResolveParams, mutate func(app *LoanApplicationData, principal *Principal) error) (*LoanApplicationData, error) {
+	principal, err := requirePrincipal(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	_, store, err := requireTenant(p.Context)
+	if err != nil {
+		return nil, err
+	}
 	uuidArg, ok := p.Args["uuid"].(string)
 	if !ok {
 		return nil, fmt.Errorf("missing 'uuid' argument")
 	}
+	noteApplicationUUID(p.Context, uuidArg)
+	expectedVersion, hasExpectedVersion := p.Args["expectedVersion"].(int)
+	if hasExpectedVersion && expectedVersion < 1 {
+		return nil, newLocalizedAPIError(ErrCodeBadRequest, MsgNotPositive, "expectedVersion")
+	}
 
-	loanApplicationsMutex.Lock()
-	defer loanApplicationsMutex.Unlock()
+	app, err := store.Update(uuidArg, expectedVersion, func(app *LoanApplicationData) error {
+		if !canAccessApplication(p.Context, app) {
+			return applicationNotFound(uuidArg)
+		}
+		return mutate(app, principal)
+	})
+	if errors.Is(err, errApplicationNotFound) {
+		return nil, applicationNotFound(uuidArg)
+	}
+	if errors.Is(err, errVersionConflict) {
+		// The version check runs before the access check; do not reveal foreign applications.
+		if current, ok := store.Get(uuidArg); !ok || !canAccessApplication(p.Context, current) {
+			return nil, applicationNotFound(uuidArg)
+		}
+		return nil, newLocalizedAPIError(ErrCodeConflict, MsgVersionConflict, uuidArg, expectedVersion)
+	}
+	return app, err
+}
 
-	app, exists := loanApplications[uuidArg]
-	if !exists {
-		return false, fmt.Errorf("loan application with UUID '%s' not found", uuidArg)
+var submitLoanApplicationResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	tenant, _, err := requireTenant(p.Context)
+	if err != nil {
+		return false, err
 	}
+	consentInput, _ := p.Args["consent"].(map[string]interface{})
+	now := time.Now()
+	acceptance, err := newTermsAcceptance(p.Context, consentInput, tenant.TermsDocuments, now)
+	if err != nil {
+		return false, invalidField("consent", err)
+	}
+	_, err = updateLoanApplication(p, func(app *LoanApplicationData, principal *Principal) error {
+		if app.Status != "DRAFT" {
+			// Depending on business logic, could allow submission from other statuses or return error
+			return newLocalizedAPIError(ErrCodeConflict, MsgCannotSubmit, app.Status)
+		}
+		acceptance.AcceptedBy = principal.UserID
+		app.TermsAcceptance = acceptance
+		app.Status = "SUBMITTED"
+		app.UpdatedAt = now
+		app.UpdatedBy = principal.UserID
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	recordAudit(p.Context, AuditActionSubmitted, p.Args["uuid"].(string))
+	return true, nil
+}
+
+// errAlreadyCancelled short-circuits a cancel of an already cancelled application.
+var errAlreadyCancelled = errors.New("already cancelled")
 
-	// Add business logic here, e.g., cannot cancel if already processed
-	if app.Status == "CANCELLED" {
+var cancelLoanApplicationResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	_, err := updateLoanApplication(p, func(app *LoanApplicationData, principal *Principal) error {
+		// Add business logic here, e.g., cannot cancel if already processed
+		if app.Status == "CANCELLED" {
+			return errAlreadyCancelled
+		}
+		if app.Status != "DRAFT" && app.Status != "SUBMITTED" { // Example: cannot cancel if in terminal state other than cancelled
+			return newLocalizedAPIError(ErrCodeConflict, MsgCannotCancel, app.Status)
+		}
+		app.Status = "CANCELLED"
+		app.UpdatedAt = time.Now()
+		app.UpdatedBy = principal.UserID
+		return nil
+	})
+	if errors.Is(err, errAlreadyCancelled) {
 		return true, nil // Already cancelled
 	}
-	if app.Status != "DRAFT" && app.Status != "SUBMITTED" { // Example: cannot cancel if in terminal state other than cancelled
-		return false, fmt.Errorf("loan application status is '%s', cannot cancel", app.Status)
+	if err != nil {
+		return false, err
 	}
 
-	app.Status = "CANCELLED"
-	app.UpdatedAt = time.Now()
-	loanApplications[uuidArg] = app
+	recordAudit(p.Context, AuditActionCancelled, p.Args["uuid"].(string))
+	return true, nil
+}
+
+// decideLoanApplication moves a SUBMITTED application to an underwriting decision status.
+func decideLoanApplication(p graphql.ResolveParams, status, auditAction string) (interface{}, error) {
+	_, err := updateLoanApplication(p, func(app *LoanApplicationData, principal *Principal) error {
+		if app.Status != "SUBMITTED" {
+			return newLocalizedAPIError(ErrCodeConflict, MsgCannotDecide, app.Status)
+		}
+		app.Status = status
+		app.UpdatedAt = time.Now()
+		app.UpdatedBy = principal.UserID
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
 
+	recordAudit(p.Context, auditAction, p.Args["uuid"].(string))
 	return true, nil
 }
 
+var approveLoanApplicationResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	return decideLoanApplication(p, "APPROVED", AuditActionApproved)
+}
+
+var rejectLoanApplicationResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	return decideLoanApplication(p, "REJECTED", AuditActionRejected)
+}
+
+// reencryptCustomerDataResolver re-wraps every data key under the current key after a rotation.
+var reencryptCustomerDataResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	if err := reloadKeyFile(); err != nil {
+		return nil, err
+	}
+	total := 0
+	for id, store := range tenants.allStores() {
+		encrypted, ok := store.(*encryptedStore)
+		if !ok {
+			continue
+		}
+		count, err := encrypted.rewrapKeys()
+		total += count
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", id, err)
+		}
+	}
+	slog.InfoContext(p.Context, "re-encrypted customer data", "records", total, "key_id", currentKeyProvider().CurrentKeyID())
+	return total, nil
+}
+
+var rebuildProjectionsResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	total := 0
+	rebuilt := false
+	for id, store := range tenants.allStores() {
+		projections, ok := backendStore(store).(projectionStore)
+		if !ok {
+			continue
+		}
+		count, err := projections.RebuildProjections()
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", id, err)
+		}
+		total += count
+		rebuilt = true
+	}
+	if !rebuilt {
+		return nil, newLocalizedAPIError(ErrCodeBadRequest, MsgStorageModeRequired, "rebuildProjections", StorageModeEventSourced)
+	}
+	slog.InfoContext(p.Context, "rebuilt projections", "applications", total)
+	return total, nil
+}
+
 // Field resolver for LoanApplication.createdAt and LoanApplication.updatedAt to format time.Time
 var timeFormatterResolver = func(p graphql.ResolveParams) (interface{}, error) {
 	if t, ok := p.Source.(*LoanApplicationData); ok {
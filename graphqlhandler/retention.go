@@ -0,0 +1,165 @@
+package graphqlhandler
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"time"
+)
+
+// Clock abstracts time so the retention worker can be driven deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the wall clock.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// RetentionPolicy configures what the retention worker does. A zero duration disables the rule.
+type RetentionPolicy struct {
+	// DraftExpiry moves DRAFT applications not updated for this long to EXPIRED.
+	DraftExpiry time.Duration
+	// AnonymizeClosedAfter erases the customer data of CANCELLED and EXPIRED
+	// applications that have not changed for this long.
+	AnonymizeClosedAfter time.Duration
+}
+
+// DefaultRetentionPolicy expires drafts after 30 days and anonymizes closed applications after 180 days.
+var DefaultRetentionPolicy = RetentionPolicy{
+	DraftExpiry:          30 * 24 * time.Hour,
+	AnonymizeClosedAfter: 180 * 24 * time.Hour,
+}
+
+// RetentionReport summarizes one run of the retention worker.
+type RetentionReport struct {
+	DraftsExpired          int
+	ApplicationsAnonymized int
+	Errors                 int
+}
+
+// retentionMetrics is published at /debug/vars under "retention".
+var retentionMetrics = expvar.NewMap("retention")
+
+// errRetentionSkipped aborts an update whose application no longer qualifies when it is
+// re-checked under the store lock, so that the record and its version stay untouched.
+var errRetentionSkipped = errors.New("no longer qualifies for retention")
+
+// retentionPrincipal is the actor recorded for changes made by the worker.
+var retentionPrincipal = &Principal{UserID: "system:retention"}
+
+// RetentionWorker applies a RetentionPolicy to the applications of every tenant.
+type RetentionWorker struct {
+	Policy   RetentionPolicy
+	Clock    Clock
+	Interval time.Duration
+}
+
+// NewRetentionWorker returns a worker using the wall clock.
+func NewRetentionWorker(policy RetentionPolicy, interval time.Duration) *RetentionWorker {
+	return &RetentionWorker{Policy: policy, Clock: SystemClock{}, Interval: interval}
+}
+
+// Run applies the policy immediately and then every Interval until ctx is cancelled.
+func (w *RetentionWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		w.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce applies the policy to every tenant once.
+func (w *RetentionWorker) RunOnce(ctx context.Context) RetentionReport {
+	var report RetentionReport
+	for id, store := range tenants.allStores() {
+		cfg, ok := tenants.lookup(id)
+		if !ok {
+			continue
+		}
+		tenantCtx := WithPrincipal(context.WithValue(ctx, tenantKey{}, cfg), retentionPrincipal)
+		w.applyToStore(tenantCtx, store, &report)
+	}
+
+	retentionMetrics.Add("runs", 1)
+	retentionMetrics.Add("drafts_expired", int64(report.DraftsExpired))
+	retentionMetrics.Add("applications_anonymized", int64(report.ApplicationsAnonymized))
+	retentionMetrics.Add("errors", int64(report.Errors))
+	slog.InfoContext(ctx, "retention run finished",
+		"drafts_expired", report.DraftsExpired,
+		"applications_anonymized", report.ApplicationsAnonymized,
+		"errors", report.Errors,
+	)
+	return report
+}
+
+func isClosedWithoutLoan(status string) bool {
+	return status == "CANCELLED" || status == "EXPIRED"
+}
+
+func (w *RetentionWorker) applyToStore(ctx context.Context, store LoanApplicationStore, report *RetentionReport) {
+	now := w.Clock.Now()
+	for _, app := range store.List() {
+		switch {
+		case w.Policy.DraftExpiry > 0 && app.Status == "DRAFT":
+			cutoff := now.Add(-w.Policy.DraftExpiry)
+			if !app.UpdatedAt.Before(cutoff) {
+				continue
+			}
+			_, err := store.Update(app.UUID, 0, func(stored *LoanApplicationData) error {
+				// Re-check under the store lock; the draft may have been touched meanwhile.
+				if stored.Status != "DRAFT" || !stored.UpdatedAt.Before(cutoff) {
+					return errRetentionSkipped
+				}
+				stored.Status = "EXPIRED"
+				stored.UpdatedAt = now
+				stored.UpdatedBy = retentionPrincipal.UserID
+				return nil
+			})
+			if errors.Is(err, errRetentionSkipped) {
+				continue
+			}
+			if err != nil {
+				report.Errors++
+				slog.ErrorContext(ctx, "failed to expire draft", "application_uuid", app.UUID, "error", err)
+				continue
+			}
+			report.DraftsExpired++
+			recordAudit(ctx, AuditActionExpired, app.UUID)
+
+		case w.Policy.AnonymizeClosedAfter > 0 && isClosedWithoutLoan(app.Status) && app.CustomerErasedAt == nil:
+			cutoff := now.Add(-w.Policy.AnonymizeClosedAfter)
+			if !app.UpdatedAt.Before(cutoff) {
+				continue
+			}
+			var removed []DocumentData
+			_, err := store.Update(app.UUID, 0, func(stored *LoanApplicationData) error {
+				if !isClosedWithoutLoan(stored.Status) || stored.CustomerErasedAt != nil || !stored.UpdatedAt.Before(cutoff) {
+					return errRetentionSkipped
+				}
+				removed = anonymizeCustomer(stored, now)
+				stored.UpdatedAt = now
+				stored.UpdatedBy = retentionPrincipal.UserID
+				return nil
+			})
+			if errors.Is(err, errRetentionSkipped) {
+				continue
+			}
+			if err != nil {
+				report.Errors++
+				slog.ErrorContext(ctx, "failed to anonymize application", "application_uuid", app.UUID, "error", err)
+				continue
+			}
+			deleteDocumentBlobs(ctx, removed)
+			report.ApplicationsAnonymized++
+			recordAudit(ctx, AuditActionAnonymized, app.UUID)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package graphqlhandler
+
+import (
+	"context"
+	"expvar"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+// retentionMetric returns the current value of a retention counter.
+func retentionMetric(name string) int64 {
+	if v, ok := retentionMetrics.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func hasAuditAction(appUUID, action string) bool {
+	for _, event := range auditEventsFor(appUUID) {
+		if event.Action == action && event.ActorID == retentionPrincipal.UserID {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRetentionWorker(t *testing.T) {
+	useTenants(t, "acme")
+	ctx := requestContext(t, agentOf("acme"), "")
+	store := tenants.store("acme")
+	draft := createDraft(t, ctx, testDraft("ID200000001"))
+	cancelled := createDraft(t, ctx, testDraft("ID200000002"))
+	mustExecute(t, ctx, `mutation($u: ID!) { cancelLoanApplication(uuid: $u) }`, map[string]interface{}{"u": cancelled})
+
+	start := time.Now()
+	clock := &fakeClock{now: start.Add(10 * 24 * time.Hour)}
+	worker := &RetentionWorker{Policy: DefaultRetentionPolicy, Clock: clock}
+	expiredBefore, anonymizedBefore := retentionMetric("drafts_expired"), retentionMetric("applications_anonymized")
+
+	if report := worker.RunOnce(context.Background()); report != (RetentionReport{}) {
+		t.Fatalf("run before any deadline changed %+v", report)
+	}
+
+	clock.now = start.Add(31 * 24 * time.Hour)
+	if report := worker.RunOnce(context.Background()); report != (RetentionReport{DraftsExpired: 1}) {
+		t.Fatalf("run after draft expiry: %+v", report)
+	}
+	app, _ := store.Get(draft)
+	if app.Status != "EXPIRED" || !app.UpdatedAt.Equal(clock.now) || app.UpdatedBy != retentionPrincipal.UserID {
+		t.Fatalf("draft is %s, updated %v by %s", app.Status, app.UpdatedAt, app.UpdatedBy)
+	}
+	if !hasAuditAction(draft, AuditActionExpired) {
+		t.Fatal("no audit event for the expired draft")
+	}
+	if app, _ := store.Get(cancelled); app.CustomerErasedAt != nil {
+		t.Fatal("cancelled application anonymized before its deadline")
+	}
+
+	clock.now = start.Add((31 + 181) * 24 * time.Hour)
+	if report := worker.RunOnce(context.Background()); report != (RetentionReport{ApplicationsAnonymized: 2}) {
+		t.Fatalf("run after the anonymization deadline: %+v", report)
+	}
+	for _, uuid := range []string{draft, cancelled} {
+		app, _ := store.Get(uuid)
+		if app.CustomerErasedAt == nil || app.Customer.FullName != erasedName || app.Customer.IDNumber != "" {
+			t.Fatalf("%s not anonymized: %+v", uuid, app.Customer)
+		}
+		if !hasAuditAction(uuid, AuditActionAnonymized) {
+			t.Fatalf("no audit event for anonymizing %s", uuid)
+		}
+	}
+
+	if got := retentionMetric("drafts_expired") - expiredBefore; got != 1 {
+		t.Errorf("drafts_expired grew by %d, want 1", got)
+	}
+	if got := retentionMetric("applications_anonymized") - anonymizedBefore; got != 2 {
+		t.Errorf("applications_anonymized grew by %d, want 2", got)
+	}
+}
+
+// staleListStore lists outdated copies of its applications, like a List that raced
+// with a concurrent update.
+type staleListStore struct {
+	LoanApplicationStore
+	stale []*LoanApplicationData
+}
+
+func (s staleListStore) List() []*LoanApplicationData { return s.stale }
+
+func TestRetentionWorkerSkipsApplicationsChangedMeanwhile(t *testing.T) {
+	useTenants(t, "acme")
+	ctx := requestContext(t, agentOf("acme"), "")
+	store := tenants.store("acme")
+	uuid := createDraft(t, ctx, testDraft("ID200000003"))
+
+	stale, _ := store.Get(uuid)
+	stale.UpdatedAt = stale.UpdatedAt.Add(-60 * 24 * time.Hour)
+	worker := &RetentionWorker{Policy: DefaultRetentionPolicy, Clock: &fakeClock{now: time.Now()}}
+	var report RetentionReport
+	worker.applyToStore(ctx, staleListStore{LoanApplicationStore: store, stale: []*LoanApplicationData{stale}}, &report)
+
+	if report != (RetentionReport{}) {
+		t.Fatalf("report %+v, want nothing done", report)
+	}
+	app, _ := store.Get(uuid)
+	if app.Status != "DRAFT" || app.Version != 1 {
+		t.Fatalf("application touched: status %s, version %d", app.Status, app.Version)
+	}
+}
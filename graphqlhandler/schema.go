@@ -7,6 +7,12 @@ import (
 
 var Schema graphql.Schema
 
+// expectedVersionArg is accepted by mutations that change an existing application.
+var expectedVersionArg = &graphql.ArgumentConfig{
+	Type:        graphql.Int,
+	Description: "Fail with CONFLICT unless the application is still at this version.",
+}
+
 func init() {
 	// Re-fetch loanApplicationType to ensure it's initialized, especially its fields
 	// This is important because we are about to assign resolvers to its fields.
@@ -54,7 +60,7 @@ func init() {
 
 	rootQuery := graphql.NewObject(graphql.ObjectConfig{
 		Name: "Query",
-		Fields: graphql.Fields{
+		Fields: withPolicy(graphql.Fields{
 			"healthCheck": &graphql.Field{
 				Type:    graphql.NewNonNull(graphql.String),
 				Resolve: healthCheckResolver,
@@ -65,15 +71,92 @@ func init() {
 					"uuid": &graphql.ArgumentConfig{
 						Type: graphql.NewNonNull(graphql.ID),
 					},
+					"asOf": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "RFC 3339 timestamp; returns the application as it was at that time. Requires the event-sourced storage mode.",
+					},
 				},
 				Resolve: getLoanApplicationResolver,
 			},
-		},
+			"listLoanApplications": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(GetLoanApplicationType()))),
+				Args: graphql.FieldConfigArgument{
+					"status": &graphql.ArgumentConfig{
+						Type: graphql.String,
+					},
+					"limit": &graphql.ArgumentConfig{
+						Type:         graphql.Int,
+						DefaultValue: 20,
+					},
+					"offset": &graphql.ArgumentConfig{
+						Type:         graphql.Int,
+						DefaultValue: 0,
+					},
+				},
+				Resolve: listLoanApplicationsResolver,
+			},
+			"lookupPostalCode": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postalRegionType))),
+				Description: "Regions a postal code covers, to fill in address forms. Empty for unknown codes and countries without a region dataset.",
+				Args: graphql.FieldConfigArgument{
+					"zipcode": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"country": &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "ID"},
+				},
+				Resolve: lookupPostalCodeResolver,
+			},
+			"termsDocuments": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(termsDocumentType))),
+				Description: "The current terms versions of the tenant, which submitLoanApplication must accept.",
+				Resolve:     termsDocumentsResolver,
+			},
+			"vehicleBrands": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+				Description: "Catalog brands of a collateral category containing the search text, for typeahead.",
+				Args: graphql.FieldConfigArgument{
+					"category": &graphql.ArgumentConfig{Type: graphql.NewNonNull(collateralCategoryEnum)},
+					"search":   &graphql.ArgumentConfig{Type: graphql.String},
+					"limit":    &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 20},
+				},
+				Resolve: vehicleBrandsResolver,
+			},
+			"vehicleVariants": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(vehicleVariantType))),
+				Description: "Catalog variants of a brand whose name contains the search text, optionally only those of a model or produced in a year.",
+				Args: graphql.FieldConfigArgument{
+					"category": &graphql.ArgumentConfig{Type: graphql.NewNonNull(collateralCategoryEnum)},
+					"brand":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"model":    &graphql.ArgumentConfig{Type: graphql.String},
+					"search":   &graphql.ArgumentConfig{Type: graphql.String},
+					"year":     &graphql.ArgumentConfig{Type: graphql.Int},
+					"limit":    &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 20},
+				},
+				Resolve: vehicleVariantsResolver,
+			},
+			"webhooks": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(webhookType))),
+				Resolve: webhooksResolver,
+			},
+			"webhookDeadLetters": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(webhookDeadLetterType))),
+				Description: "Webhook deliveries that failed on every attempt, newest first.",
+				Args: graphql.FieldConfigArgument{
+					"limit": &graphql.ArgumentConfig{
+						Type:         graphql.Int,
+						DefaultValue: 20,
+					},
+					"offset": &graphql.ArgumentConfig{
+						Type:         graphql.Int,
+						DefaultValue: 0,
+					},
+				},
+				Resolve: webhookDeadLettersResolver,
+			},
+		}),
 	})
 
 	rootMutation := graphql.NewObject(graphql.ObjectConfig{
 		Name: "Mutation",
-		Fields: graphql.Fields{
+		Fields: withPolicy(withIdempotency(graphql.Fields{
 			"createLoanApplicationDraft": &graphql.Field{
 				Type: graphql.NewNonNull(graphql.ID),
 				Args: graphql.FieldConfigArgument{
@@ -84,11 +167,16 @@ func init() {
 				Resolve: createLoanApplicationDraftResolver,
 			},
 			"submitLoanApplication": &graphql.Field{
-				Type: graphql.NewNonNull(graphql.Boolean),
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "Submits a DRAFT application once the customer has accepted the current terms.",
 				Args: graphql.FieldConfigArgument{
 					"uuid": &graphql.ArgumentConfig{
 						Type: graphql.NewNonNull(graphql.ID),
 					},
+					"consent": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(submissionConsentInputType),
+					},
+					"expectedVersion": expectedVersionArg,
 				},
 				Resolve: submitLoanApplicationResolver,
 			},
@@ -98,16 +186,191 @@ func init() {
 					"uuid": &graphql.ArgumentConfig{
 						Type: graphql.NewNonNull(graphql.ID),
 					},
+					"expectedVersion": expectedVersionArg,
 				},
 				Resolve: cancelLoanApplicationResolver,
 			},
-		},
+			"approveLoanApplication": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"uuid": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+					"expectedVersion": expectedVersionArg,
+				},
+				Resolve: approveLoanApplicationResolver,
+			},
+			"rejectLoanApplication": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"uuid": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+					"expectedVersion": expectedVersionArg,
+				},
+				Resolve: rejectLoanApplicationResolver,
+			},
+			"uploadLoanApplicationDocument": &graphql.Field{
+				Type:        graphql.NewNonNull(loanApplicationDocumentType),
+				Description: "Attaches a file (JPEG, PNG or PDF) to a DRAFT or SUBMITTED application. Send it as a GraphQL multipart request.",
+				Args: graphql.FieldConfigArgument{
+					"uuid": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+					"type": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(documentTypeEnum),
+					},
+					"file": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(uploadScalar),
+					},
+					"collateralId": &graphql.ArgumentConfig{
+						Type:        graphql.ID,
+						Description: "The collateral item the document describes; required for every type but ID_CARD unless there is only one item.",
+					},
+					"expectedVersion": expectedVersionArg,
+				},
+				Resolve: uploadLoanApplicationDocumentResolver,
+			},
+			"reviewLoanApplicationDocument": &graphql.Field{
+				Type:        graphql.NewNonNull(loanApplicationDocumentType),
+				Description: "Marks a document VERIFIED or REJECTED. A reason is required to reject.",
+				Args: graphql.FieldConfigArgument{
+					"uuid": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+					"documentId": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+					"decision": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(documentReviewDecisionEnum),
+					},
+					"reason": &graphql.ArgumentConfig{
+						Type: graphql.String,
+					},
+					"expectedVersion": expectedVersionArg,
+				},
+				Resolve: reviewLoanApplicationDocumentResolver,
+			},
+			"reencryptCustomerData": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Int),
+				Description: "Re-wraps customer data keys under the current encryption key. Returns the number of applications re-wrapped.",
+				Resolve:     reencryptCustomerDataResolver,
+			},
+			"rebuildProjections": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Int),
+				Description: "Rebuilds the current state of every application from the event log. Returns the number of applications rebuilt.",
+				Resolve:     rebuildProjectionsResolver,
+			},
+			"exportCustomerData": &graphql.Field{
+				Type:        graphql.NewNonNull(jsonScalar),
+				Description: "Exports every application of a data subject, with its audit history, as JSON.",
+				Args: graphql.FieldConfigArgument{
+					"id_number": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: exportCustomerDataResolver,
+			},
+			"eraseCustomerData": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Int),
+				Description: "Anonymizes the customer data of a data subject. Returns the number of applications erased.",
+				Args: graphql.FieldConfigArgument{
+					"id_number": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: eraseCustomerDataResolver,
+			},
+			"upsertVehicleVariant": &graphql.Field{
+				Type:        graphql.NewNonNull(vehicleVariantType),
+				Description: "Adds a variant to the vehicle catalog or changes the production years of an existing one.",
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(vehicleVariantInputType),
+					},
+				},
+				Resolve: upsertVehicleVariantResolver,
+			},
+			"deleteVehicleVariant": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "Removes a variant from the vehicle catalog. Existing collaterals keep their values.",
+				Args: graphql.FieldConfigArgument{
+					"category": &graphql.ArgumentConfig{Type: graphql.NewNonNull(collateralCategoryEnum)},
+					"brand":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"model":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"variant":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: deleteVehicleVariantResolver,
+			},
+			"registerWebhook": &graphql.Field{
+				Type:        graphql.NewNonNull(webhookRegistrationType),
+				Description: "Subscribes an endpoint to lifecycle events of the current tenant.",
+				Args: graphql.FieldConfigArgument{
+					"url": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+					"events": &graphql.ArgumentConfig{
+						Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+						Description: "Event types, e.g. application.submitted.",
+					},
+				},
+				Resolve: registerWebhookResolver,
+			},
+			"deleteWebhook": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+				},
+				Resolve: deleteWebhookResolver,
+			},
+			"redeliverWebhookDeadLetter": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "Queues a dead-lettered delivery for another round of attempts.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+				},
+				Resolve: redeliverWebhookDeadLetterResolver,
+			},
+		})),
+	})
+
+	rootSubscription := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: withPolicy(graphql.Fields{
+			"loanApplicationStatusChanged": &graphql.Field{
+				Type:        graphql.NewNonNull(loanApplicationEventType),
+				Description: "Status changes of one loan application.",
+				Args: graphql.FieldConfigArgument{
+					"uuid": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.ID),
+					},
+				},
+				Subscribe: loanApplicationStatusChangedSubscriber,
+				Resolve:   statusEventResolver,
+			},
+			"loanApplicationEvents": &graphql.Field{
+				Type:        graphql.NewNonNull(loanApplicationEventType),
+				Description: "Status changes of the tenant's loan applications the caller may access.",
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{
+						Type: loanApplicationEventFilterType,
+					},
+				},
+				Subscribe: loanApplicationEventsSubscriber,
+				Resolve:   statusEventResolver,
+			},
+		}),
 	})
 
 	var err error
 	Schema, err = graphql.NewSchema(graphql.SchemaConfig{
-		Query:    rootQuery,
-		Mutation: rootMutation,
+		Query:        rootQuery,
+		Mutation:     rootMutation,
+		Subscription: rootSubscription,
 		// Types:    []graphql.Type{collateralCategoryEnum, dateScalar, emailScalar, addressType, customerType, collateralType, proposedLoanType, loanApplicationType}, // Explicitly list types if needed for schema documentation or if not referenced directly by Query/Mutation fields.
 	})
 
@@ -0,0 +1,64 @@
+package graphqlhandler
+
+import (
+	"fmt"
+
+	"github.com/graphql-go/graphql"
+)
+
+// Subscription root fields have two functions: the subscriber, run once when a client
+// subscribes, returns a channel of events; the resolver maps each event to the result.
+
+// statusEventResolver resolves a subscription field to the event being delivered.
+// Over plain HTTP there is no event, so the operation is rejected.
+var statusEventResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	event, ok := p.Source.(*LoanApplicationEvent)
+	if !ok {
+		return nil, newLocalizedAPIError(ErrCodeBadRequest, MsgWebSocketOnly)
+	}
+	return event, nil
+}
+
+var loanApplicationStatusChangedSubscriber = func(p graphql.ResolveParams) (interface{}, error) {
+	tenant, store, err := requireTenant(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	uuidArg, ok := p.Args["uuid"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing 'uuid' argument")
+	}
+	app, exists := store.Get(uuidArg)
+	if !exists || !canAccessApplication(p.Context, app) {
+		return nil, applicationNotFound(uuidArg)
+	}
+	return subscribeStatusEvents(p.Context, func(e *LoanApplicationEvent) bool {
+		return e.TenantID == tenant.ID && e.ApplicationUUID == uuidArg
+	}), nil
+}
+
+var loanApplicationEventsSubscriber = func(p graphql.ResolveParams) (interface{}, error) {
+	tenant, _, err := requireTenant(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	filter, _ := p.Args["filter"].(map[string]interface{})
+	appUUID, _ := filter["application_uuid"].(string)
+	statuses := map[string]bool{}
+	if list, ok := filter["statuses"].([]interface{}); ok {
+		for _, status := range list {
+			statuses[status.(string)] = true
+		}
+	}
+
+	ctx := p.Context
+	return subscribeStatusEvents(ctx, func(e *LoanApplicationEvent) bool {
+		if e.TenantID != tenant.ID || !canAccessOwnedBy(ctx, e.OwnerID) {
+			return false
+		}
+		if appUUID != "" && e.ApplicationUUID != appUUID {
+			return false
+		}
+		return len(statuses) == 0 || statuses[e.Status]
+	}), nil
+}
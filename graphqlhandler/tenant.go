@@ -0,0 +1,351 @@
+package graphqlhandler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// TenantHeader selects the partner lender when the access token carries no tenant claim.
+const TenantHeader = "X-Tenant-ID"
+
+// BusinessRules are the per-tenant limits applied when validating a draft.
+// Zero values are replaced by the defaults in defaultBusinessRules.
+type BusinessRules struct {
+	MinTenure            int     `json:"min_tenure"`
+	MaxTenure            int     `json:"max_tenure"`
+	TenureStep           int     `json:"tenure_step"`
+	MinAmount            float64 `json:"min_amount"`
+	MaxAmount            float64 `json:"max_amount"`
+	MinManufacturingYear int     `json:"min_manufacturing_year"`
+	MinApplicantAge      int     `json:"min_applicant_age"`   // every party, at application
+	MaxAgeAtMaturity     int     `json:"max_age_at_maturity"` // at least one borrower, when the loan ends
+	MaxLoanToValue       float64 `json:"max_loan_to_value"`   // percent of the aggregate collateral value
+	MaxDebtToIncome      float64 `json:"max_debt_to_income"`  // percent of the borrowers' monthly income
+	DebtToIncomeAction   string  `json:"debt_to_income_action"`
+}
+
+var defaultBusinessRules = BusinessRules{
+	MinTenure:            3,
+	MaxTenure:            60,
+	TenureStep:           3,
+	MinAmount:            100,
+	MaxAmount:            50000,
+	MinManufacturingYear: 2020,
+	MinApplicantAge:      21,
+	MaxAgeAtMaturity:     65,
+	MaxLoanToValue:       80,
+	MaxDebtToIncome:      40,
+	DebtToIncomeAction:   DebtToIncomeFlag,
+}
+
+func (r BusinessRules) withDefaults() BusinessRules {
+	if r.MinTenure == 0 {
+		r.MinTenure = defaultBusinessRules.MinTenure
+	}
+	if r.MaxTenure == 0 {
+		r.MaxTenure = defaultBusinessRules.MaxTenure
+	}
+	if r.TenureStep == 0 {
+		r.TenureStep = defaultBusinessRules.TenureStep
+	}
+	if r.MinAmount == 0 {
+		r.MinAmount = defaultBusinessRules.MinAmount
+	}
+	if r.MaxAmount == 0 {
+		r.MaxAmount = defaultBusinessRules.MaxAmount
+	}
+	if r.MinManufacturingYear == 0 {
+		r.MinManufacturingYear = defaultBusinessRules.MinManufacturingYear
+	}
+	if r.MinApplicantAge == 0 {
+		r.MinApplicantAge = defaultBusinessRules.MinApplicantAge
+	}
+	if r.MaxAgeAtMaturity == 0 {
+		r.MaxAgeAtMaturity = defaultBusinessRules.MaxAgeAtMaturity
+	}
+	if r.MaxLoanToValue == 0 {
+		r.MaxLoanToValue = defaultBusinessRules.MaxLoanToValue
+	}
+	if r.MaxDebtToIncome == 0 {
+		r.MaxDebtToIncome = defaultBusinessRules.MaxDebtToIncome
+	}
+	if r.DebtToIncomeAction == "" {
+		r.DebtToIncomeAction = defaultBusinessRules.DebtToIncomeAction
+	}
+	return r
+}
+
+// RateCardEntry is the annual interest rate (percent) for a collateral category
+// up to and including MaxTenure months.
+type RateCardEntry struct {
+	Category   string  `json:"category"`
+	MaxTenure  int     `json:"max_tenure"`
+	AnnualRate float64 `json:"annual_rate"`
+}
+
+// RateCard lists the rates a tenant offers.
+type RateCard []RateCardEntry
+
+var defaultRateCard = RateCard{
+	{Category: CollateralCategoryCar, MaxTenure: 24, AnnualRate: 8.5},
+	{Category: CollateralCategoryCar, MaxTenure: 60, AnnualRate: 9.5},
+	{Category: CollateralCategoryMotorcycle, MaxTenure: 24, AnnualRate: 12},
+	{Category: CollateralCategoryMotorcycle, MaxTenure: 60, AnnualRate: 14},
+	{Category: CollateralCategoryTruck, MaxTenure: 24, AnnualRate: 10},
+	{Category: CollateralCategoryTruck, MaxTenure: 60, AnnualRate: 11},
+	{Category: CollateralCategoryHeavyEquipment, MaxTenure: 36, AnnualRate: 11},
+	{Category: CollateralCategoryHeavyEquipment, MaxTenure: 60, AnnualRate: 12.5},
+	{Category: CollateralCategoryPropertyCertificate, MaxTenure: 60, AnnualRate: 7.5},
+	{Category: CollateralCategoryGold, MaxTenure: 12, AnnualRate: 9},
+	{Category: CollateralCategoryGold, MaxTenure: 24, AnnualRate: 10},
+}
+
+// Rate returns the annual rate for the category using the narrowest tenure band that fits.
+func (c RateCard) Rate(category string, tenure int) (float64, bool) {
+	best := -1
+	for i, entry := range c {
+		if entry.Category != category || tenure > entry.MaxTenure {
+			continue
+		}
+		if best < 0 || entry.MaxTenure < c[best].MaxTenure {
+			best = i
+		}
+	}
+	if best < 0 {
+		return 0, false
+	}
+	return c[best].AnnualRate, true
+}
+
+// TenantConfig describes one partner finance company.
+type TenantConfig struct {
+	ID            string        `json:"id"`
+	Name          string        `json:"name"`
+	BusinessRules BusinessRules `json:"business_rules"`
+	RateCard      RateCard      `json:"rate_card"`
+
+	RequiredDocuments RequiredDocuments `json:"required_documents"`
+	TermsDocuments    []TermsDocument   `json:"terms_documents"` // accepted at submission
+}
+
+// tenantRegistry holds the configured tenants and the storage of each one.
+type tenantRegistry struct {
+	mu            sync.RWMutex
+	tenants       map[string]*TenantConfig
+	defaultTenant string // used when a request names no tenant; empty means a tenant is required
+	stores        map[string]LoanApplicationStore
+}
+
+// tenants is the process wide registry. Without a tenants file there is a single "default" tenant.
+var tenants = newTenantRegistry([]TenantConfig{{ID: "default", Name: "Default"}}, "default")
+
+func newTenantRegistry(configs []TenantConfig, defaultTenant string) *tenantRegistry {
+	reg := &tenantRegistry{
+		tenants:       make(map[string]*TenantConfig, len(configs)),
+		defaultTenant: defaultTenant,
+		stores:        make(map[string]LoanApplicationStore, len(configs)),
+	}
+	for i := range configs {
+		cfg := configs[i]
+		cfg.BusinessRules = cfg.BusinessRules.withDefaults()
+		if len(cfg.RateCard) == 0 {
+			cfg.RateCard = defaultRateCard
+		}
+		if cfg.RequiredDocuments == nil {
+			cfg.RequiredDocuments = defaultRequiredDocuments
+		}
+		if cfg.TermsDocuments == nil {
+			cfg.TermsDocuments = defaultTermsDocuments
+		}
+		reg.tenants[cfg.ID] = &cfg
+		reg.stores[cfg.ID] = newEncryptedStore(newPublishingStore(newTenantBackend(), statusEvents))
+	}
+	return reg
+}
+
+// newTenantBackend creates the backend store of a tenant, as chosen by SetStorageMode.
+var newTenantBackend = func() LoanApplicationStore { return newMemoryStore() }
+
+// SetStorageMode selects how tenant stores keep applications: StorageModeMemory (the
+// default) or StorageModeEventSourced. It recreates the stores of the default registry,
+// so call it at startup before LoadTenantsFile.
+func SetStorageMode(mode string) error {
+	switch mode {
+	case "", StorageModeMemory:
+		newTenantBackend = func() LoanApplicationStore { return newMemoryStore() }
+	case StorageModeEventSourced:
+		newTenantBackend = func() LoanApplicationStore { return newEventSourcedStore() }
+	default:
+		return fmt.Errorf("storage: unknown mode %q, use %q or %q", mode, StorageModeMemory, StorageModeEventSourced)
+	}
+	tenants = newTenantRegistry([]TenantConfig{{ID: "default", Name: "Default"}}, "default")
+	return nil
+}
+
+// LoadTenantsFile replaces the tenant registry with the tenants defined in a JSON file:
+//
+//	{"default_tenant": "", "tenants": [{"id": "acme", "name": "ACME Finance", "business_rules": {...}, "rate_card": [...]}]}
+func LoadTenantsFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("tenants: read file: %w", err)
+	}
+	var file struct {
+		DefaultTenant string         `json:"default_tenant"`
+		Tenants       []TenantConfig `json:"tenants"`
+	}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return fmt.Errorf("tenants: parse file: %w", err)
+	}
+	if len(file.Tenants) == 0 {
+		return fmt.Errorf("tenants: %s defines no tenants", path)
+	}
+	seen := make(map[string]bool, len(file.Tenants))
+	for _, cfg := range file.Tenants {
+		if cfg.ID == "" {
+			return fmt.Errorf("tenants: tenant without id in %s", path)
+		}
+		if seen[cfg.ID] {
+			return fmt.Errorf("tenants: duplicate tenant id %q", cfg.ID)
+		}
+		seen[cfg.ID] = true
+		if action := cfg.BusinessRules.DebtToIncomeAction; action != "" && action != DebtToIncomeBlock && action != DebtToIncomeFlag {
+			return fmt.Errorf("tenants: tenant %q has debt_to_income_action %q, use %q or %q", cfg.ID, action, DebtToIncomeBlock, DebtToIncomeFlag)
+		}
+		for _, entry := range cfg.RateCard {
+			if !isCollateralCategory(entry.Category) {
+				return fmt.Errorf("tenants: tenant %q has a rate for unknown collateral category %q", cfg.ID, entry.Category)
+			}
+		}
+		for category, docTypes := range cfg.RequiredDocuments {
+			if !isCollateralCategory(category) {
+				return fmt.Errorf("tenants: tenant %q requires documents for unknown collateral category %q", cfg.ID, category)
+			}
+			for _, docType := range docTypes {
+				if !isDocumentType(docType) {
+					return fmt.Errorf("tenants: tenant %q requires unknown document type %q for %s", cfg.ID, docType, category)
+				}
+			}
+		}
+		if err := validateTermsDocuments(cfg.TermsDocuments); err != nil {
+			return fmt.Errorf("tenants: tenant %q: %w", cfg.ID, err)
+		}
+	}
+	if file.DefaultTenant != "" && !seen[file.DefaultTenant] {
+		return fmt.Errorf("tenants: default tenant %q is not defined", file.DefaultTenant)
+	}
+	tenants = newTenantRegistry(file.Tenants, file.DefaultTenant)
+	return nil
+}
+
+func (reg *tenantRegistry) lookup(id string) (*TenantConfig, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	cfg, ok := reg.tenants[id]
+	return cfg, ok
+}
+
+func (reg *tenantRegistry) store(id string) LoanApplicationStore {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.stores[id]
+}
+
+// allStores returns the store of every tenant, keyed by tenant ID.
+func (reg *tenantRegistry) allStores() map[string]LoanApplicationStore {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	stores := make(map[string]LoanApplicationStore, len(reg.stores))
+	for id, store := range reg.stores {
+		stores[id] = store
+	}
+	return stores
+}
+
+type tenantKey struct{}
+
+// TenantFromContext returns the tenant resolved for the request, if any.
+func TenantFromContext(ctx context.Context) (*TenantConfig, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	cfg, ok := ctx.Value(tenantKey{}).(*TenantConfig)
+	return cfg, ok && cfg != nil
+}
+
+// tenantID returns the ID of the request tenant, or "" if none was resolved.
+func tenantID(ctx context.Context) string {
+	if cfg, ok := TenantFromContext(ctx); ok {
+		return cfg.ID
+	}
+	return ""
+}
+
+// requireTenant returns the request tenant and its application store.
+func requireTenant(ctx context.Context) (*TenantConfig, LoanApplicationStore, error) {
+	cfg, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, nil, newLocalizedAPIError(ErrCodeTenantRequired, MsgTenantRequired, TenantHeader)
+	}
+	return cfg, tenants.store(cfg.ID), nil
+}
+
+// crossTenantRoles may act for any tenant: when their token is not bound to a tenant,
+// they choose one with the X-Tenant-ID header.
+var crossTenantRoles = []string{RoleAdmin}
+
+// resolveTenant applies the tenant selection rules: a "tenant" claim in the access
+// token decides the tenant, and a requested ID that contradicts it is rejected. Without
+// a claim only cross-tenant roles may request a tenant; other callers get the default
+// tenant. It returns nil without an error when no tenant applies.
+func resolveTenant(principal *Principal, requested string) (*TenantConfig, *apiError) {
+	id := requested
+	switch {
+	case principal != nil && principal.TenantID != "":
+		if requested != "" && requested != principal.TenantID {
+			return nil, newLocalizedAPIError(ErrCodeForbidden, MsgTenantMismatch)
+		}
+		id = principal.TenantID
+	case requested != "":
+		if principal == nil || !hasAnyRole(principal, crossTenantRoles) {
+			return nil, newLocalizedAPIError(ErrCodeForbidden, MsgTenantNotSelectable)
+		}
+	default:
+		id = tenants.defaultTenant
+	}
+	if id == "" {
+		return nil, nil
+	}
+	cfg, ok := tenants.lookup(id)
+	if !ok {
+		return nil, newLocalizedAPIError(ErrCodeUnknownTenant, MsgUnknownTenant, id)
+	}
+	return cfg, nil
+}
+
+// TenantMiddleware resolves the tenant of a request from the X-Tenant-ID header and
+// the access token (see resolveTenant). It must run after the authentication middleware.
+func TenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := PrincipalFromContext(r.Context())
+		cfg, apiErr := resolveTenant(principal, r.Header.Get(TenantHeader))
+		if apiErr != nil {
+			status := http.StatusBadRequest
+			if apiErr.Code == ErrCodeForbidden {
+				status = http.StatusForbidden
+			}
+			writeHTTPError(w, r, status, apiErr)
+			return
+		}
+		if cfg == nil {
+			// Tenant-free operations such as healthCheck still work; resolvers call requireTenant.
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantKey{}, cfg)))
+	})
+}
@@ -0,0 +1,169 @@
+package graphqlhandler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestResolveTenant(t *testing.T) {
+	useTenants(t, "acme", "globex")
+	unbound := &Principal{UserID: "u", Roles: []string{RoleSalesAgent}}
+	admin := &Principal{UserID: "a", Roles: []string{RoleAdmin}}
+
+	tests := []struct {
+		name      string
+		principal *Principal
+		requested string
+		want      string
+		wantCode  string
+	}{
+		{"claim", agentOf("acme"), "", "acme", ""},
+		{"matching header", agentOf("acme"), "acme", "acme", ""},
+		{"header contradicts claim", agentOf("acme"), "globex", "", ErrCodeForbidden},
+		{"header without claim", unbound, "globex", "", ErrCodeForbidden},
+		{"header without principal", nil, "globex", "", ErrCodeForbidden},
+		{"cross-tenant role", admin, "globex", "globex", ""},
+		{"admin bound to a tenant", &Principal{UserID: "a", Roles: []string{RoleAdmin}, TenantID: "acme"}, "globex", "", ErrCodeForbidden},
+		{"unknown tenant", admin, "initech", "", ErrCodeUnknownTenant},
+		{"no tenant", unbound, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, apiErr := resolveTenant(tt.principal, tt.requested)
+			if tt.wantCode != "" {
+				if apiErr == nil || apiErr.Code != tt.wantCode {
+					t.Fatalf("got %v, want error %s", apiErr, tt.wantCode)
+				}
+				return
+			}
+			if apiErr != nil {
+				t.Fatalf("unexpected error %v", apiErr)
+			}
+			got := ""
+			if cfg != nil {
+				got = cfg.ID
+			}
+			if got != tt.want {
+				t.Fatalf("got tenant %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenantMiddlewareRejectsMismatch(t *testing.T) {
+	useTenants(t, "acme", "globex")
+	handler := TenantMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	for _, principal := range []*Principal{agentOf("acme"), {UserID: "u", Roles: []string{RoleSalesAgent}}} {
+		req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+		req.Header.Set(TenantHeader, "globex")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req.WithContext(WithPrincipal(req.Context(), principal)))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%+v with header globex: status %d, want 403", principal, rec.Code)
+		}
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	useTenants(t, "acme", "globex")
+	acme := requestContext(t, agentOf("acme"), "")
+	globex := requestContext(t, agentOf("globex"), "")
+	uuid := createDraft(t, acme, testDraft("ID100000001"))
+
+	t.Run("get", func(t *testing.T) {
+		query := `query($u: ID!) { getLoanApplication(uuid: $u) { uuid } }`
+		if data := mustExecute(t, acme, query, map[string]interface{}{"u": uuid}); data["getLoanApplication"] == nil {
+			t.Fatal("owning tenant cannot read its application")
+		}
+		if data := mustExecute(t, globex, query, map[string]interface{}{"u": uuid}); data["getLoanApplication"] != nil {
+			t.Fatal("other tenant read the application")
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		query := `{ listLoanApplications { uuid } }`
+		if list := mustExecute(t, acme, query, nil)["listLoanApplications"].([]interface{}); len(list) != 1 {
+			t.Fatalf("owning tenant lists %d applications, want 1", len(list))
+		}
+		if list := mustExecute(t, globex, query, nil)["listLoanApplications"].([]interface{}); len(list) != 0 {
+			t.Fatalf("other tenant lists %v", list)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		result := execute(globex, `mutation($u: ID!) { cancelLoanApplication(uuid: $u) }`, map[string]interface{}{"u": uuid})
+		if code := errorCode(result); code != ErrCodeNotFound {
+			t.Fatalf("cancel from other tenant: code %q, want %s", code, ErrCodeNotFound)
+		}
+		app, _ := tenants.store("acme").Get(uuid)
+		if app.Status != "DRAFT" || app.Version != 1 {
+			t.Fatalf("application changed: status %s, version %d", app.Status, app.Version)
+		}
+	})
+
+	t.Run("subscriptions", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(globex)
+		defer cancel()
+		results := graphql.Subscribe(graphql.Params{
+			Schema:         Schema,
+			RequestString:  `subscription($u: ID!) { loanApplicationStatusChanged(uuid: $u) { status } }`,
+			VariableValues: map[string]interface{}{"u": uuid},
+			Context:        ctx,
+		})
+		// graphql-go drops error extensions of subscriptions, so only the failure is checked.
+		if result := <-results; !result.HasErrors() {
+			t.Fatalf("other tenant subscribed: %v", result.Data)
+		}
+
+		const events = `subscription { loanApplicationEvents { application_uuid status } }`
+		acmeCtx, cancelAcme := context.WithCancel(acme)
+		defer cancelAcme()
+		acmeEvents := graphql.Subscribe(graphql.Params{Schema: Schema, RequestString: events, Context: acmeCtx})
+		globexEvents := graphql.Subscribe(graphql.Params{Schema: Schema, RequestString: events, Context: ctx})
+		time.Sleep(50 * time.Millisecond) // let both subscriptions register on the bus
+
+		mustExecute(t, acme, `mutation($u: ID!) { cancelLoanApplication(uuid: $u) }`, map[string]interface{}{"u": uuid})
+		select {
+		case result := <-acmeEvents:
+			if result.HasErrors() {
+				t.Fatalf("acme event: %v", result.Errors)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("owning tenant received no event")
+		}
+		select {
+		case result := <-globexEvents:
+			t.Fatalf("other tenant received %v", result.Data)
+		case <-time.After(200 * time.Millisecond):
+		}
+	})
+}
+
+func TestPlatformOperationsNeedUnboundAdmin(t *testing.T) {
+	useEventSourcedTenants(t, "acme", "globex")
+	bound := requestContext(t, adminOf("acme"), "")
+	for _, mutation := range []string{`mutation { reencryptCustomerData }`, `mutation { rebuildProjections }`} {
+		if code := errorCode(execute(bound, mutation, nil)); code != ErrCodeForbidden {
+			t.Fatalf("%s by a tenant-bound admin: code %q, want %s", mutation, code, ErrCodeForbidden)
+		}
+		operator := requestContext(t, &Principal{UserID: "operator", Roles: []string{RoleAdmin}}, "acme")
+		mustExecute(t, operator, mutation, nil)
+	}
+}
+
+func TestVehicleCatalogNeedsUnboundAdmin(t *testing.T) {
+	useTenants(t, "acme")
+	const deleteVariant = `mutation { deleteVehicleVariant(category: CAR, brand: "Toyota", model: "Camry", variant: "2.5 V") }`
+	if code := errorCode(execute(requestContext(t, adminOf("acme"), ""), deleteVariant, nil)); code != ErrCodeForbidden {
+		t.Fatalf("catalog change by a tenant-bound admin: code %q, want %s", code, ErrCodeForbidden)
+	}
+	// The variant is still there for every tenant.
+	createDraft(t, requestContext(t, agentOf("acme"), ""), testDraft("ID490000001"))
+}
@@ -0,0 +1,138 @@
+package graphqlhandler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// Terms documents a customer accepts when an application is submitted.
+const (
+	TermsDocumentLoanAgreement = "LOAN_AGREEMENT" // terms and conditions of the loan
+	TermsDocumentPrivacyNotice = "PRIVACY_NOTICE" // consent to the processing of personal data
+)
+
+var termsDocumentTypes = []string{TermsDocumentLoanAgreement, TermsDocumentPrivacyNotice}
+
+func isTermsDocumentType(docType string) bool {
+	for _, known := range termsDocumentTypes {
+		if known == docType {
+			return true
+		}
+	}
+	return false
+}
+
+// Channels through which terms are accepted.
+const (
+	ConsentChannelMobileApp  = "MOBILE_APP"
+	ConsentChannelWeb        = "WEB"
+	ConsentChannelBranch     = "BRANCH"      // at a branch or with a sales agent
+	ConsentChannelCallCenter = "CALL_CENTER" // recorded call
+)
+
+var consentChannels = []string{ConsentChannelMobileApp, ConsentChannelWeb, ConsentChannelBranch, ConsentChannelCallCenter}
+
+// maxUserAgentLength bounds the recorded User-Agent header.
+const maxUserAgentLength = 500
+
+// TermsDocument is the current version of a terms document of a tenant.
+type TermsDocument struct {
+	Type    string `json:"type"`
+	Version string `json:"version"`
+	URL     string `json:"url,omitempty"` // where customers can read this version
+}
+
+// defaultTermsDocuments apply to tenants without terms_documents in TENANTS_FILE.
+var defaultTermsDocuments = []TermsDocument{
+	{Type: TermsDocumentLoanAgreement, Version: "2026-01"},
+	{Type: TermsDocumentPrivacyNotice, Version: "2026-01"},
+}
+
+// validateTermsDocuments checks the terms documents of a tenant configuration.
+func validateTermsDocuments(docs []TermsDocument) error {
+	seen := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		if !isTermsDocumentType(doc.Type) {
+			return fmt.Errorf("unknown terms document type %q", doc.Type)
+		}
+		if doc.Version == "" {
+			return fmt.Errorf("terms document %s has no version", doc.Type)
+		}
+		if seen[doc.Type] {
+			return fmt.Errorf("duplicate terms document %s", doc.Type)
+		}
+		seen[doc.Type] = true
+	}
+	return nil
+}
+
+// AcceptedTerms is a terms document version the customer accepted.
+type AcceptedTerms struct {
+	Document string `json:"document"`
+	Version  string `json:"version"`
+}
+
+// TermsAcceptanceData records the acceptance of the terms at submission, as legal
+// evidence of the customer's agreement.
+type TermsAcceptanceData struct {
+	AcceptedAt time.Time       `json:"accepted_at"`
+	AcceptedBy string          `json:"accepted_by"` // Principal that submitted the application
+	Channel    string          `json:"channel"`
+	IPAddress  string          `json:"ip_address,omitempty"` // Blanked when the customer's data is erased
+	UserAgent  string          `json:"user_agent,omitempty"`
+	Terms      []AcceptedTerms `json:"terms"`
+}
+
+func (a *TermsAcceptanceData) clone() *TermsAcceptanceData {
+	if a == nil {
+		return nil
+	}
+	c := *a
+	c.Terms = append([]AcceptedTerms(nil), a.Terms...)
+	return &c
+}
+
+// newTermsAcceptance checks that the consent of a submission accepts the current version
+// of every terms document and records it with the client of the request.
+func newTermsAcceptance(ctx context.Context, input map[string]interface{}, current []TermsDocument, now time.Time) (*TermsAcceptanceData, error) {
+	accepted := map[string]string{}
+	terms, _ := input["terms"].([]interface{})
+	for i, item := range terms {
+		term, _ := item.(map[string]interface{})
+		document, _ := term["document"].(string)
+		version, _ := term["version"].(string)
+		if _, ok := accepted[document]; ok {
+			return nil, invalidField(fmt.Sprintf("terms[%d]", i), invalidInput("document", MsgDuplicateTerms, document))
+		}
+		accepted[document] = normalizeText(version)
+	}
+	acceptance := &TermsAcceptanceData{AcceptedAt: now, Terms: make([]AcceptedTerms, 0, len(current))}
+	for _, doc := range current {
+		if accepted[doc.Type] != doc.Version {
+			return nil, invalidInput("terms", MsgTermsNotAccepted, doc.Type, doc.Version)
+		}
+		acceptance.Terms = append(acceptance.Terms, AcceptedTerms{Document: doc.Type, Version: doc.Version})
+	}
+	acceptance.Channel, _ = input["channel"].(string)
+	if info := requestInfoFromContext(ctx); info != nil {
+		acceptance.IPAddress = info.ClientIP
+		acceptance.UserAgent = info.UserAgent
+		if runes := []rune(acceptance.UserAgent); len(runes) > maxUserAgentLength {
+			acceptance.UserAgent = string(runes[:maxUserAgentLength])
+		}
+	}
+	return acceptance, nil
+}
+
+// termsDocumentsResolver returns the terms versions of the current tenant that a
+// submission must accept.
+var termsDocumentsResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	tenant, _, err := requireTenant(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	return tenant.TermsDocuments, nil
+}
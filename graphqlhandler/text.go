@@ -0,0 +1,98 @@
+package graphqlhandler
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalizeText puts free text in Unicode NFC, so that "é" typed as e plus a combining
+// accent and as a single code point compare and count the same, trims it and collapses
+// runs of whitespace, including tabs, newlines and non-breaking spaces, to one space.
+func normalizeText(s string) string {
+	return strings.Join(strings.Fields(norm.NFC.String(s)), " ")
+}
+
+// normalizeInput normalizes every string of a mutation input in place, recursing into
+// nested objects and lists, so validation and storage see the same values.
+func normalizeInput(input map[string]interface{}) {
+	for key, value := range input {
+		input[key] = normalizeValue(value)
+	}
+}
+
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return normalizeText(v)
+	case map[string]interface{}:
+		normalizeInput(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeValue(v[i])
+		}
+	}
+	return value
+}
+
+// textLength returns the length of s in characters (runes), not bytes.
+func textLength(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// validateText checks that a normalized text is valid UTF-8 without control characters
+// and between min and max characters long.
+func validateText(field, value string, min, max int) error {
+	if !utf8.ValidString(value) {
+		return invalidInput(field, MsgInvalidUTF8, field)
+	}
+	for _, r := range value {
+		if unicode.IsControl(r) {
+			return invalidInput(field, MsgControlCharacters, field)
+		}
+	}
+	if value == "" && min > 0 {
+		return invalidInput(field, MsgRequired, field)
+	}
+	if n := textLength(value); n < min || n > max {
+		if min == 0 {
+			return invalidInput(field, MsgTooLong, field, max)
+		}
+		return invalidInput(field, MsgLength, field, min, max)
+	}
+	return nil
+}
+
+// namePunctuation may appear inside a name: apostrophes (O'Brien, Nur'aini, also typed
+// as a right single quotation mark), hyphens and periods of initials.
+const namePunctuation = "'’-."
+
+const (
+	minNameLength = 3
+	maxNameLength = 100
+)
+
+// validateName checks a normalized personal name. Names consist of letters in any
+// script, combining marks, spaces and namePunctuation; they start with a letter and
+// do not contain two punctuation characters in a row.
+func validateName(field, name string) error {
+	if err := validateText(field, name, minNameLength, maxNameLength); err != nil {
+		return err
+	}
+	previous := ' '
+	for i, r := range name {
+		punctuation := strings.ContainsRune(namePunctuation, r)
+		switch {
+		case i == 0 && !unicode.IsLetter(r):
+			return invalidInput(field, MsgNameCharacters, field)
+		case unicode.IsLetter(r), unicode.IsMark(r), r == ' ':
+		case punctuation && !strings.ContainsRune(namePunctuation, previous):
+		default:
+			return invalidInput(field, MsgNameCharacters, field)
+		}
+		previous = r
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package graphqlhandler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"  José  ", "José"},
+		{"José", "José"}, // combining acute accent composes to é
+		{"Siti Nur'aini", "Siti Nur'aini"},
+		{"a\t\nb", "a b"},
+	}
+	for _, tt := range tests {
+		if got := normalizeText(tt.in); got != tt.want {
+			t.Errorf("normalizeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name string
+		code string // expected validation code, "" when valid
+	}{
+		{"O'Brien", ""},
+		{"Siti Nur'aini", ""},
+		{"Siti Nur’aini", ""},
+		{"José", ""},
+		{normalizeText("José García"), ""},
+		{"Nguyễn Văn An", ""},
+		{"Jean-Luc Picard", ""},
+		{"李小龙", ""},
+		{"J. R. Smith", ""},
+		{"äb́c", ""}, // letters with combining marks left uncomposed
+		{"Al", MsgLength},
+		{"", MsgRequired},
+		{"'Quote", MsgNameCharacters},
+		{"Bad--Name", MsgNameCharacters},
+		{"John3", MsgNameCharacters},
+		{"John\x00Doe", MsgControlCharacters},
+		{"Jo\xffhn", MsgInvalidUTF8},
+		{strings.Repeat("é", maxNameLength), ""},
+		{strings.Repeat("é", maxNameLength+1), MsgLength},
+	}
+	for _, tt := range tests {
+		err := validateName("full_name", tt.name)
+		if got := validationCode(err); got != tt.code {
+			t.Errorf("validateName(%q) = %v, want code %q", tt.name, err, tt.code)
+		}
+	}
+}
+
+func validationCode(err error) string {
+	var vErr *validationError
+	if errors.As(err, &vErr) {
+		return vErr.Code
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func FuzzNormalizeText(f *testing.F) {
+	for _, seed := range []string{"O'Brien", "Siti Nur'aini", "José", " a  b\n", "\xff\xfe", ""} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		once := normalizeText(s)
+		if twice := normalizeText(once); twice != once {
+			t.Fatalf("not idempotent: %q -> %q -> %q", s, once, twice)
+		}
+		if once != strings.TrimSpace(once) || strings.Contains(once, "  ") {
+			t.Fatalf("whitespace not collapsed: %q", once)
+		}
+		if utf8.ValidString(s) && !utf8.ValidString(once) {
+			t.Fatalf("valid input %q became invalid %q", s, once)
+		}
+	})
+}
+
+func FuzzValidateName(f *testing.F) {
+	for _, seed := range []string{"O'Brien", "Siti Nur'aini", "José", "José", "Bad--Name", "\xff", ""} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		name := normalizeText(s)
+		err := validateName("full_name", name)
+		if err == nil {
+			if !utf8.ValidString(name) {
+				t.Fatalf("accepted invalid UTF-8 %q", name)
+			}
+			// Lengths are counted in characters, not bytes.
+			if n := utf8.RuneCountInString(name); n < minNameLength || n > maxNameLength {
+				t.Fatalf("accepted %q of %d characters", name, n)
+			}
+		}
+		if textLength(name) != utf8.RuneCountInString(name) {
+			t.Fatalf("textLength(%q) = %d", name, textLength(name))
+		}
+	})
+}
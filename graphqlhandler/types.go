@@ -1,56 +1,162 @@
 package graphqlhandler
 
 import (
+	"encoding/json"
+	"fmt"
+	"time"
+
 	"github.com/graphql-go/graphql"
 )
 
 // Enum for CollateralCategory
 var collateralCategoryEnum = graphql.NewEnum(graphql.EnumConfig{
-	Name: "CollateralCategory",
-	Values: graphql.EnumValueConfigMap{
-		"CAR": &graphql.EnumValueConfig{
-			Value: "CAR",
-		},
-		"MOTORCYCLE": &graphql.EnumValueConfig{
-			Value: "MOTORCYCLE",
-		},
-	},
+	Name:   "CollateralCategory",
+	Values: enumValues(collateralCategories),
 })
 
+// enumValues maps every value to itself.
+func enumValues(values []string) graphql.EnumValueConfigMap {
+	config := make(graphql.EnumValueConfigMap, len(values))
+	for _, value := range values {
+		config[value] = &graphql.EnumValueConfig{Value: value}
+	}
+	return config
+}
+
 // Custom Scalars (as String for now, validation in resolvers or later custom scalar type)
 var dateScalar = graphql.String  // Placeholder for Date scalar
 var emailScalar = graphql.String // Placeholder for Email scalar
 
+// JSON scalar passes an arbitrary Go value through to the response as a JSON value.
+// It is output only, used for documents such as data subject exports.
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "Arbitrary JSON value (output only)",
+	Serialize: func(value interface{}) interface{} {
+		return value
+	},
+})
+
 // Address Type
 var addressType = graphql.NewObject(graphql.ObjectConfig{
 	Name: "Address",
 	Fields: graphql.Fields{
-		"street":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
-		"city":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
-		"zipcode": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"street":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"sub_district": &graphql.Field{Type: graphql.String, Resolve: addressField(func(a AddressData) string { return a.SubDistrict })},
+		"district":     &graphql.Field{Type: graphql.String, Resolve: addressField(func(a AddressData) string { return a.District })},
+		"city":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"province":     &graphql.Field{Type: graphql.String, Resolve: addressField(func(a AddressData) string { return a.Province })},
+		"zipcode":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"country":      &graphql.Field{Type: graphql.String, Resolve: addressField(func(a AddressData) string { return a.Country })},
+		"geo": &graphql.Field{
+			Type: geoPointType,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if address, ok := p.Source.(AddressData); ok && address.Geo != nil {
+					return *address.Geo, nil
+				}
+				return nil, nil
+			},
+		},
 	},
 })
 
+// addressField resolves an optional field of an address to null when it is empty.
+func addressField(get func(a AddressData) string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if address, ok := p.Source.(AddressData); ok && get(address) != "" {
+			return get(address), nil
+		}
+		return nil, nil
+	}
+}
+
+var geoPointType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "GeoPoint",
+	Fields: graphql.Fields{
+		"latitude":  &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		"longitude": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+	},
+})
+
+var geoPointInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "GeoPointInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"latitude":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Float)},
+		"longitude": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Float)},
+	},
+})
+
+// postalRegionType is a result of lookupPostalCode.
+var postalRegionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PostalRegion",
+	Fields: graphql.Fields{
+		"country":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"province":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"city":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"district":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"sub_district": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"postal_code":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+var genderEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name:   "Gender",
+	Values: enumValues([]string{GenderMale, GenderFemale}),
+})
+
 // Address Input Type
 var addressInputType = graphql.NewInputObject(graphql.InputObjectConfig{
 	Name: "AddressInput",
 	Fields: graphql.InputObjectConfigFieldMap{
-		"street":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
-		"city":    &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
-		"zipcode": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"street":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"sub_district": &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Kelurahan or desa; filled in from the zipcode when it is unambiguous."},
+		"district":     &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Kecamatan; filled in from the zipcode when it is unambiguous."},
+		"city":         &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String), Description: "City or regency; must match the zipcode where a region dataset exists."},
+		"province":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"zipcode":      &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"country":      &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "ISO 3166-1 alpha-2 code; defaults to the customer's country."},
+		"geo":          &graphql.InputObjectFieldConfig{Type: geoPointInputType},
 	},
 })
 
 // Customer Type
+// PII fields are masked for callers without raw access (see rbac.go).
 var customerType = graphql.NewObject(graphql.ObjectConfig{
 	Name: "Customer",
 	Fields: graphql.Fields{
 		"full_name":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
-		"date_of_birth": &graphql.Field{Type: graphql.NewNonNull(dateScalar)},
-		"id_number":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"date_of_birth": &graphql.Field{Type: graphql.NewNonNull(dateScalar), Resolve: customerDateOfBirthResolver},
+		"id_number":     &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: customerIDNumberResolver},
 		"email":         &graphql.Field{Type: emailScalar},
-		"phone":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
-		"address":       &graphql.Field{Type: graphql.NewNonNull(addressType)},
+		"phone":         &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: customerPhoneResolver},
+		"address":       &graphql.Field{Type: graphql.NewNonNull(addressType), Resolve: customerAddressResolver},
+		"country": &graphql.Field{
+			Type: graphql.String,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if customer, ok := customerSource(p.Source); ok && customer.Country != "" {
+					return customer.Country, nil
+				}
+				return nil, nil
+			},
+		},
+		"gender": &graphql.Field{Type: genderEnum, Resolve: customerGenderResolver},
+		"employment_type": &graphql.Field{
+			Type:        employmentTypeEnum,
+			Description: "Financial fields are null without raw PII access or when not provided.",
+			Resolve:     financialCustomerField(func(c *CustomerData) interface{} { return c.EmploymentType }),
+		},
+		"employer": &graphql.Field{
+			Type: graphql.String,
+			Resolve: financialCustomerField(func(c *CustomerData) interface{} {
+				if c.Employer == "" {
+					return nil
+				}
+				return c.Employer
+			}),
+		},
+		"monthly_income":      &graphql.Field{Type: graphql.Float, Resolve: financialCustomerField(func(c *CustomerData) interface{} { return c.MonthlyIncome })},
+		"monthly_obligations": &graphql.Field{Type: graphql.Float, Resolve: financialCustomerField(func(c *CustomerData) interface{} { return c.MonthlyObligations })},
+		"dependents":          &graphql.Field{Type: graphql.Int, Resolve: financialCustomerField(func(c *CustomerData) interface{} { return c.Dependents })},
 	},
 })
 
@@ -58,36 +164,437 @@ var customerType = graphql.NewObject(graphql.ObjectConfig{
 var customerInputType = graphql.NewInputObject(graphql.InputObjectConfig{
 	Name: "CustomerInput",
 	Fields: graphql.InputObjectConfigFieldMap{
-		"full_name":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
-		"date_of_birth": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(dateScalar)},
-		"id_number":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
-		"email":         &graphql.InputObjectFieldConfig{Type: emailScalar},
-		"phone":         &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
-		"address":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(addressInputType)},
+		"full_name":           &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"date_of_birth":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(dateScalar)},
+		"id_number":           &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"email":               &graphql.InputObjectFieldConfig{Type: emailScalar},
+		"phone":               &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"address":             &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(addressInputType)},
+		"country":             &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "ISO 3166-1 alpha-2 code selecting the id_number and phone rules, e.g. ID."},
+		"gender":              &graphql.InputObjectFieldConfig{Type: genderEnum, Description: "Cross-checked against national ID numbers that encode it."},
+		"employment_type":     &graphql.InputObjectFieldConfig{Type: employmentTypeEnum, Description: "Required, like monthly_income, once any financial field is sent."},
+		"employer":            &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Required for SALARIED and CIVIL_SERVANT."},
+		"monthly_income":      &graphql.InputObjectFieldConfig{Type: graphql.Float, Description: "Net monthly income in the loan currency."},
+		"monthly_obligations": &graphql.InputObjectFieldConfig{Type: graphql.Float, Description: "Installments of existing debt per month."},
+		"dependents":          &graphql.InputObjectFieldConfig{Type: graphql.Int},
+	},
+})
+
+var employmentTypeEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name:   "EmploymentType",
+	Values: enumValues(employmentTypes),
+})
+
+// Party Types
+var partyRoleEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "PartyRole",
+	Values: graphql.EnumValueConfigMap{
+		PartyRolePrimary:     &graphql.EnumValueConfig{Value: PartyRolePrimary},
+		PartyRoleCoApplicant: &graphql.EnumValueConfig{Value: PartyRoleCoApplicant},
+		PartyRoleGuarantor:   &graphql.EnumValueConfig{Value: PartyRoleGuarantor},
+	},
+})
+
+var partyConsentInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "PartyConsentInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"credit_check":    &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Boolean)},
+		"data_processing": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Boolean)},
+		"marketing":       &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+	},
+})
+
+var partyInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "PartyInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"role":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(partyRoleEnum)},
+		"customer": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(customerInputType)},
+		"consent":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(partyConsentInputType)},
+	},
+})
+
+var partyConsentType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PartyConsent",
+	Fields: graphql.Fields{
+		"credit_check":    &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"data_processing": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"marketing":       &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"given_at": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Null when no consent was recorded.",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				consent, ok := p.Source.(ConsentData)
+				if !ok || consent.GivenAt.IsZero() {
+					return nil, nil
+				}
+				return consent.GivenAt.Format(time.RFC3339), nil
+			},
+		},
+	},
+})
+
+var partyType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Party",
+	Fields: graphql.Fields{
+		"id":       &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Description: "The application UUID for the PRIMARY party."},
+		"role":     &graphql.Field{Type: graphql.NewNonNull(partyRoleEnum)},
+		"customer": &graphql.Field{Type: graphql.NewNonNull(customerType)},
+		"consent":  &graphql.Field{Type: graphql.NewNonNull(partyConsentType)},
+	},
+})
+
+// Terms Types
+var termsDocumentTypeEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "TermsDocumentType",
+	Values: graphql.EnumValueConfigMap{
+		TermsDocumentLoanAgreement: &graphql.EnumValueConfig{Value: TermsDocumentLoanAgreement, Description: "Terms and conditions of the loan"},
+		TermsDocumentPrivacyNotice: &graphql.EnumValueConfig{Value: TermsDocumentPrivacyNotice, Description: "Consent to the processing of personal data"},
+	},
+})
+
+var consentChannelEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name:   "ConsentChannel",
+	Values: enumValues(consentChannels),
+})
+
+var termsDocumentType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "TermsDocument",
+	Fields: graphql.Fields{
+		"type":    &graphql.Field{Type: graphql.NewNonNull(termsDocumentTypeEnum)},
+		"version": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"url": &graphql.Field{
+			Type: graphql.String,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if doc, ok := p.Source.(TermsDocument); ok && doc.URL != "" {
+					return doc.URL, nil
+				}
+				return nil, nil
+			},
+		},
+	},
+})
+
+var acceptedTermsInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "AcceptedTermsInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"document": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(termsDocumentTypeEnum)},
+		"version":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String), Description: "The version shown to the customer, as returned by termsDocuments."},
+	},
+})
+
+var submissionConsentInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "SubmissionConsentInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"terms":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(acceptedTermsInputType))), Description: "Must include the current version of every terms document of the tenant."},
+		"channel": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(consentChannelEnum)},
+	},
+})
+
+var acceptedTermsType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "AcceptedTerms",
+	Fields: graphql.Fields{
+		"document": &graphql.Field{Type: graphql.NewNonNull(termsDocumentTypeEnum)},
+		"version":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+// termsAcceptanceClientField resolves the client address fields, which are personal data.
+func termsAcceptanceClientField(get func(a *TermsAcceptanceData) string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		acceptance, ok := p.Source.(*TermsAcceptanceData)
+		if !ok || get(acceptance) == "" || !canViewRawPII(p.Context) {
+			return nil, nil
+		}
+		return get(acceptance), nil
+	}
+}
+
+var termsAcceptanceType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "TermsAcceptance",
+	Fields: graphql.Fields{
+		"accepted_at": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				acceptance, _ := p.Source.(*TermsAcceptanceData)
+				return acceptance.AcceptedAt.Format(time.RFC3339), nil
+			},
+		},
+		"accepted_by": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "The user that submitted the application."},
+		"channel":     &graphql.Field{Type: graphql.NewNonNull(consentChannelEnum)},
+		"ip_address": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Null for roles without raw PII access and after the customer's data was erased.",
+			Resolve:     termsAcceptanceClientField(func(a *TermsAcceptanceData) string { return a.IPAddress }),
+		},
+		"user_agent": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Null for roles without raw PII access and after the customer's data was erased.",
+			Resolve:     termsAcceptanceClientField(func(a *TermsAcceptanceData) string { return a.UserAgent }),
+		},
+		"terms": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(acceptedTermsType)))},
+	},
+})
+
+var eligibilityType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Eligibility",
+	Fields: graphql.Fields{
+		"eligible": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"score":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Description: "0 to 100, computed from the combined profile of all parties."},
+		"reasons":  &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))), Description: "Why the application is not eligible; empty when it is."},
+	},
+})
+
+// Collateral Detail Types
+var heavyEquipmentTypeEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name:   "HeavyEquipmentType",
+	Values: enumValues([]string{"EXCAVATOR", "BULLDOZER", "WHEEL_LOADER", "DUMP_TRUCK", "CRANE", "FORKLIFT", "OTHER"}),
+})
+
+var propertyCertificateTypeEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name:   "PropertyCertificateType",
+	Values: enumValues([]string{PropertyCertificateSHM, PropertyCertificateSHGB, PropertyCertificateSHMSRS}),
+})
+
+var goldFormEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name:   "GoldForm",
+	Values: enumValues([]string{"BAR", "COIN", "JEWELRY"}),
+})
+
+var vehicleDetailsInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "VehicleDetailsInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"plate_number":            &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String), Description: "e.g. B 1234 XYZ"},
+		"chassis_number":          &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String), Description: "17 characters (VIN)."},
+		"engine_number":           &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"gross_vehicle_weight_kg": &graphql.InputObjectFieldConfig{Type: graphql.Int, Description: "Required for trucks only."},
+	},
+})
+
+var heavyEquipmentDetailsInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "HeavyEquipmentDetailsInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"equipment_type":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(heavyEquipmentTypeEnum)},
+		"serial_number":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"operating_hours": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var propertyCertificateDetailsInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "PropertyCertificateDetailsInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"certificate_type":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(propertyCertificateTypeEnum)},
+		"certificate_number": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"land_area":          &graphql.InputObjectFieldConfig{Type: graphql.Float, Description: "Square metres. Required except for SHMSRS."},
+		"building_area":      &graphql.InputObjectFieldConfig{Type: graphql.Float, Description: "Square metres. Required for SHMSRS."},
 	},
 })
 
+var goldDetailsInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "GoldDetailsInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"form":               &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(goldFormEnum)},
+		"weight_grams":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Float)},
+		"karat":              &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"certificate_number": &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Assay certificate of bars and coins."},
+	},
+})
+
+var vehicleDetailsType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "VehicleDetails",
+	Fields: graphql.Fields{
+		"plate_number":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"chassis_number": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"engine_number":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"gross_vehicle_weight_kg": &graphql.Field{
+			Type: graphql.Int,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if details, ok := p.Source.(*VehicleDetails); ok && details.GrossVehicleWeightKg > 0 {
+					return details.GrossVehicleWeightKg, nil
+				}
+				return nil, nil
+			},
+		},
+	},
+})
+
+var heavyEquipmentDetailsType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "HeavyEquipmentDetails",
+	Fields: graphql.Fields{
+		"equipment_type":  &graphql.Field{Type: graphql.NewNonNull(heavyEquipmentTypeEnum)},
+		"serial_number":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"operating_hours": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var propertyCertificateDetailsType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PropertyCertificateDetails",
+	Fields: graphql.Fields{
+		"certificate_type":   &graphql.Field{Type: graphql.NewNonNull(propertyCertificateTypeEnum)},
+		"certificate_number": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"land_area":          &graphql.Field{Type: graphql.NewNonNull(graphql.Float), Description: "Square metres, 0 for strata titles."},
+		"building_area":      &graphql.Field{Type: graphql.NewNonNull(graphql.Float), Description: "Square metres, 0 for bare land."},
+	},
+})
+
+var goldDetailsType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "GoldDetails",
+	Fields: graphql.Fields{
+		"form":         &graphql.Field{Type: graphql.NewNonNull(goldFormEnum)},
+		"weight_grams": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		"karat":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"certificate_number": &graphql.Field{
+			Type: graphql.String,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if details, ok := p.Source.(*GoldDetails); ok && details.CertificateNumber != "" {
+					return details.CertificateNumber, nil
+				}
+				return nil, nil
+			},
+		},
+	},
+})
+
+var collateralDetailsUnion = graphql.NewUnion(graphql.UnionConfig{
+	Name:        "CollateralDetails",
+	Description: "The category specific details of a collateral item.",
+	Types:       []*graphql.Object{vehicleDetailsType, heavyEquipmentDetailsType, propertyCertificateDetailsType, goldDetailsType},
+	ResolveType: func(p graphql.ResolveTypeParams) *graphql.Object {
+		switch p.Value.(type) {
+		case *VehicleDetails:
+			return vehicleDetailsType
+		case *HeavyEquipmentDetails:
+			return heavyEquipmentDetailsType
+		case *PropertyCertificateDetails:
+			return propertyCertificateDetailsType
+		case *GoldDetails:
+			return goldDetailsType
+		}
+		return nil
+	},
+})
+
+// collateralField resolves a field of a collateral item to null when get returns a zero value.
+func collateralField(get func(item CollateralData) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		item, ok := p.Source.(CollateralData)
+		if !ok {
+			return nil, nil
+		}
+		switch value := get(item); value {
+		case "", 0, 0.0:
+			return nil, nil
+		default:
+			return value, nil
+		}
+	}
+}
+
 // Collateral Type
 var collateralType = graphql.NewObject(graphql.ObjectConfig{
 	Name: "Collateral",
 	Fields: graphql.Fields{
-		"category":             &graphql.Field{Type: graphql.NewNonNull(collateralCategoryEnum)},
-		"brand":                &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
-		"variant":              &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
-		"manufacturing_year":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
-		"is_document_complete": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"id":       &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"category": &graphql.Field{Type: graphql.NewNonNull(collateralCategoryEnum)},
+		"brand": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Brand, variant and manufacturing_year are null for property certificates and gold.",
+			Resolve:     collateralField(func(item CollateralData) interface{} { return item.Brand }),
+		},
+		"model": &graphql.Field{
+			Type:        graphql.String,
+			Description: "The catalog model; also null for items created before the vehicle catalog.",
+			Resolve:     collateralField(func(item CollateralData) interface{} { return item.Model }),
+		},
+		"variant": &graphql.Field{
+			Type:    graphql.String,
+			Resolve: collateralField(func(item CollateralData) interface{} { return item.Variant }),
+		},
+		"manufacturing_year": &graphql.Field{
+			Type:    graphql.Int,
+			Resolve: collateralField(func(item CollateralData) interface{} { return item.ManufacturingYear }),
+		},
+		"details": &graphql.Field{
+			Type:        collateralDetailsUnion,
+			Description: "Null for cars and motorcycles created without details.",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if item, ok := p.Source.(CollateralData); ok {
+					return item.details(), nil
+				}
+				return nil, nil
+			},
+		},
+		"value": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Appraised value in the loan currency; null when the item was not valued.",
+			Resolve:     collateralField(func(item CollateralData) interface{} { return item.Value }),
+		},
+		"is_document_complete": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Description: "True when every document the category requires is uploaded and not rejected."},
+		"missing_documents": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(documentTypeEnum))),
+			Description: "Required for the item's category but not uploaded, or rejected.",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				item, ok := p.Source.(CollateralData)
+				if !ok || item.MissingDocuments == nil {
+					return []string{}, nil
+				}
+				return item.MissingDocuments, nil
+			},
+		},
 	},
 })
 
 // Collateral Input Type
 var collateralInputType = graphql.NewInputObject(graphql.InputObjectConfig{
-	Name: "CollateralInput",
+	Name:        "CollateralInput",
+	Description: "Send the details matching the category: vehicle for CAR, MOTORCYCLE and TRUCK (optional for CAR and MOTORCYCLE), heavy_equipment, property_certificate or gold.",
 	Fields: graphql.InputObjectConfigFieldMap{
 		"category":             &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(collateralCategoryEnum)},
-		"brand":                &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
-		"variant":              &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
-		"manufacturing_year":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Int)},
-		"is_document_complete": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Boolean)},
+		"brand":                &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Required for vehicles and heavy equipment."},
+		"model":                &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Catalog model; required when the variant name is used by several models of the brand."},
+		"variant":              &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Required for vehicles and heavy equipment; brand, model and variant must be in the vehicle catalog."},
+		"manufacturing_year":   &graphql.InputObjectFieldConfig{Type: graphql.Int, Description: "Required for vehicles and heavy equipment."},
+		"value":                &graphql.InputObjectFieldConfig{Type: graphql.Float, Description: "Appraised value in the loan currency. Required in collaterals."},
+		"vehicle":              &graphql.InputObjectFieldConfig{Type: vehicleDetailsInputType},
+		"heavy_equipment":      &graphql.InputObjectFieldConfig{Type: heavyEquipmentDetailsInputType},
+		"property_certificate": &graphql.InputObjectFieldConfig{Type: propertyCertificateDetailsInputType},
+		"gold":                 &graphql.InputObjectFieldConfig{Type: goldDetailsInputType},
+		"is_document_complete": &graphql.InputObjectFieldConfig{Type: graphql.Boolean, Description: "Deprecated and ignored: derived from the uploaded documents."},
+	},
+})
+
+// Vehicle Variant Type
+var vehicleVariantType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "VehicleVariant",
+	Description: "An entry of the vehicle catalog collaterals are validated against.",
+	Fields: graphql.Fields{
+		"category":  &graphql.Field{Type: graphql.NewNonNull(collateralCategoryEnum)},
+		"brand":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"model":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"variant":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"year_from": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Description: "First production year."},
+		"year_to": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Last production year; null while the variant is still produced.",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				if v, ok := p.Source.(VehicleVariant); ok && v.YearTo != 0 {
+					return v.YearTo, nil
+				}
+				return nil, nil
+			},
+		},
+	},
+})
+
+// Vehicle Variant Input Type
+var vehicleVariantInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "VehicleVariantInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"category":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(collateralCategoryEnum), Description: "CAR, MOTORCYCLE, TRUCK or HEAVY_EQUIPMENT."},
+		"brand":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"model":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"variant":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"year_from": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"year_to":   &graphql.InputObjectFieldConfig{Type: graphql.Int, Description: "Omit while the variant is still produced."},
 	},
 })
 
@@ -95,8 +602,49 @@ var collateralInputType = graphql.NewInputObject(graphql.InputObjectConfig{
 var proposedLoanType = graphql.NewObject(graphql.ObjectConfig{
 	Name: "ProposedLoan",
 	Fields: graphql.Fields{
-		"tenure": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
-		"amount": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		"tenure":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"amount":        &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+		"interest_rate": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)}, // Annual percent from the tenant rate card
+		"monthly_installment": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.Float),
+			Description: "Annuity installment at interest_rate.",
+			Resolve:     monthlyInstallmentResolver,
+		},
+	},
+})
+
+var affordabilityType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "Affordability",
+	Description: "Debt-to-income of the borrowers (primary party and co-applicants) including the proposed loan.",
+	Fields: graphql.Fields{
+		"monthly_installment": &graphql.Field{
+			Type:    graphql.NewNonNull(graphql.Float),
+			Resolve: affordabilityField(func(a Affordability) interface{} { return a.MonthlyInstallment }),
+		},
+		"debt_to_income": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Percent of the combined monthly income; null without income data.",
+			Resolve: affordabilityField(func(a Affordability) interface{} {
+				if !a.computable() {
+					return nil
+				}
+				return a.DebtToIncome
+			}),
+		},
+		"max_debt_to_income": &graphql.Field{
+			Type:    graphql.NewNonNull(graphql.Float),
+			Resolve: affordabilityField(func(a Affordability) interface{} { return a.MaxDebtToIncome }),
+		},
+		"action": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "BLOCK or FLAG, applied above max_debt_to_income.",
+			Resolve:     affordabilityField(func(a Affordability) interface{} { return a.Action }),
+		},
+		"flagged": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.Boolean),
+			Description: "Above the maximum with the FLAG action; needs a manual review.",
+			Resolve:     affordabilityField(func(a Affordability) interface{} { return a.flagged() }),
+		},
 	},
 })
 
@@ -109,6 +657,104 @@ var proposedLoanInputType = graphql.NewInputObject(graphql.InputObjectConfig{
 	},
 })
 
+// Document Types
+var documentTypeEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "DocumentType",
+	Values: graphql.EnumValueConfigMap{
+		DocumentTypeIDCard:              &graphql.EnumValueConfig{Value: DocumentTypeIDCard, Description: "Identity card (KTP)"},
+		DocumentTypeVehicleRegistration: &graphql.EnumValueConfig{Value: DocumentTypeVehicleRegistration, Description: "Vehicle registration certificate (STNK)"},
+		DocumentTypeOwnershipBook:       &graphql.EnumValueConfig{Value: DocumentTypeOwnershipBook, Description: "Vehicle ownership book (BPKB)"},
+		DocumentTypePropertyCertificate: &graphql.EnumValueConfig{Value: DocumentTypePropertyCertificate, Description: "Land or building title (SHM, SHGB, SHMSRS)"},
+		DocumentTypePurchaseInvoice:     &graphql.EnumValueConfig{Value: DocumentTypePurchaseInvoice, Description: "Purchase invoice of heavy equipment or gold"},
+	},
+})
+
+var documentStatusEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "DocumentStatus",
+	Values: graphql.EnumValueConfigMap{
+		DocumentStatusUploaded: &graphql.EnumValueConfig{Value: DocumentStatusUploaded},
+		DocumentStatusVerified: &graphql.EnumValueConfig{Value: DocumentStatusVerified},
+		DocumentStatusRejected: &graphql.EnumValueConfig{Value: DocumentStatusRejected},
+	},
+})
+
+var documentReviewDecisionEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "DocumentReviewDecision",
+	Values: graphql.EnumValueConfigMap{
+		DocumentStatusVerified: &graphql.EnumValueConfig{Value: DocumentStatusVerified},
+		DocumentStatusRejected: &graphql.EnumValueConfig{Value: DocumentStatusRejected},
+	},
+})
+
+// documentField resolves a field of a document, which is a value inside an application
+// and a pointer when returned by a mutation.
+func documentField(get func(doc *DocumentData) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		switch doc := p.Source.(type) {
+		case *DocumentData:
+			return get(doc), nil
+		case DocumentData:
+			return get(&doc), nil
+		}
+		return nil, nil
+	}
+}
+
+var loanApplicationDocumentType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "LoanApplicationDocument",
+	Fields: graphql.Fields{
+		"id":           &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"type":         &graphql.Field{Type: graphql.NewNonNull(documentTypeEnum)},
+		"status":       &graphql.Field{Type: graphql.NewNonNull(documentStatusEnum)},
+		"file_name":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"content_type": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"size":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"sha256":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"collateral_id": &graphql.Field{
+			Type:        graphql.ID,
+			Description: "The collateral item the document describes; null for ID_CARD.",
+			Resolve: documentField(func(doc *DocumentData) interface{} {
+				if doc.CollateralID == "" {
+					return nil
+				}
+				return doc.CollateralID
+			}),
+		},
+		"uploaded_by": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"uploaded_at": &graphql.Field{
+			Type:    graphql.NewNonNull(graphql.String),
+			Resolve: documentField(func(doc *DocumentData) interface{} { return doc.UploadedAt.Format(time.RFC3339) }),
+		},
+		"reviewed_by": &graphql.Field{
+			Type: graphql.String,
+			Resolve: documentField(func(doc *DocumentData) interface{} {
+				if doc.ReviewedBy == "" {
+					return nil
+				}
+				return doc.ReviewedBy
+			}),
+		},
+		"reviewed_at": &graphql.Field{
+			Type: graphql.String,
+			Resolve: documentField(func(doc *DocumentData) interface{} {
+				if doc.ReviewedAt == nil {
+					return nil
+				}
+				return doc.ReviewedAt.Format(time.RFC3339)
+			}),
+		},
+		"rejection_reason": &graphql.Field{
+			Type: graphql.String,
+			Resolve: documentField(func(doc *DocumentData) interface{} {
+				if doc.RejectionReason == "" {
+					return nil
+				}
+				return doc.RejectionReason
+			}),
+		},
+	},
+})
+
 // Loan Application Type
 var loanApplicationType *graphql.Object // Forward declaration for potential self-reference or ordering
 
@@ -116,13 +762,23 @@ func init() { // Use init to resolve potential circular dependencies if any type
 	loanApplicationType = graphql.NewObject(graphql.ObjectConfig{
 		Name: "LoanApplication",
 		Fields: graphql.Fields{
-			"uuid":          &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
-			"status":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
-			"proposed_loan": &graphql.Field{Type: graphql.NewNonNull(proposedLoanType)},
-			"collateral":    &graphql.Field{Type: graphql.NewNonNull(collateralType)},
-			"customer":      &graphql.Field{Type: graphql.NewNonNull(customerType)},
-			"created_at":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)}, // Using String for simplicity
-			"updated_at":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)}, // Using String for simplicity
+			"uuid":              &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"status":            &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"version":           &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Description: "Incremented on every change; pass it as expectedVersion to detect concurrent edits."},
+			"proposed_loan":     &graphql.Field{Type: graphql.NewNonNull(proposedLoanType)},
+			"collateral":        &graphql.Field{Type: graphql.NewNonNull(collateralType), Resolve: primaryCollateralResolver, DeprecationReason: "Use collaterals."},
+			"collaterals":       &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(collateralType))), Resolve: collateralsResolver},
+			"collateral_value":  &graphql.Field{Type: graphql.NewNonNull(graphql.Float), Resolve: collateralValueResolver, Description: "Aggregate appraised value of the collateral items."},
+			"loan_to_value":     &graphql.Field{Type: graphql.Float, Resolve: loanToValueResolver, Description: "Proposed amount as a percentage of collateral_value; null while an item is not valued."},
+			"customer":          &graphql.Field{Type: graphql.NewNonNull(customerType), Description: "The PRIMARY party."},
+			"parties":           &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(partyType))), Resolve: partiesResolver},
+			"eligibility":       &graphql.Field{Type: graphql.NewNonNull(eligibilityType), Resolve: eligibilityResolver},
+			"affordability":     &graphql.Field{Type: graphql.NewNonNull(affordabilityType), Resolve: affordabilityResolver},
+			"documents":         &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(loanApplicationDocumentType))), Resolve: documentsResolver},
+			"missing_documents": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(documentTypeEnum))), Description: "Required document types not yet uploaded, or rejected.", Resolve: missingDocumentsResolver},
+			"terms_acceptance":  &graphql.Field{Type: termsAcceptanceType, Description: "The terms accepted at submission; null before."},
+			"created_at":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)}, // Using String for simplicity
+			"updated_at":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)}, // Using String for simplicity
 		},
 	})
 }
@@ -132,8 +788,11 @@ var loanApplicationDraftInputType = graphql.NewInputObject(graphql.InputObjectCo
 	Name: "LoanApplicationDraftInput",
 	Fields: graphql.InputObjectConfigFieldMap{
 		"proposed_loan": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(proposedLoanInputType)},
-		"collateral":    &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(collateralInputType)},
-		"customer":      &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(customerInputType)},
+		"collateral":    &graphql.InputObjectFieldConfig{Type: collateralInputType, Description: "Deprecated: send collaterals instead. Its value is optional."},
+		"collaterals":   &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(collateralInputType)), Description: "1 to 10 valued items; the first decides the rate card category."},
+		"customer":      &graphql.InputObjectFieldConfig{Type: customerInputType, Description: "Deprecated: send parties instead. Becomes the PRIMARY party, with the consent given in consent."},
+		"consent":       &graphql.InputObjectFieldConfig{Type: partyConsentInputType, Description: "Deprecated with customer, and only sent with it: the consent of that customer. Without it no consent is recorded and the application is not eligible."},
+		"parties":       &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(partyInputType)), Description: "Exactly one PRIMARY party plus co-applicants and guarantors, up to 4 in total."},
 	},
 })
 
@@ -148,15 +807,121 @@ func GetLoanApplicationType() *graphql.Object {
 		loanApplicationType = graphql.NewObject(graphql.ObjectConfig{
 			Name: "LoanApplication",
 			Fields: graphql.Fields{
-				"uuid":          &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
-				"status":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
-				"proposed_loan": &graphql.Field{Type: graphql.NewNonNull(proposedLoanType)},
-				"collateral":    &graphql.Field{Type: graphql.NewNonNull(collateralType)},
-				"customer":      &graphql.Field{Type: graphql.NewNonNull(customerType)},
-				"created_at":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)}, 
-				"updated_at":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)}, 
+				"uuid":              &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"status":            &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"version":           &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Description: "Incremented on every change; pass it as expectedVersion to detect concurrent edits."},
+				"proposed_loan":     &graphql.Field{Type: graphql.NewNonNull(proposedLoanType)},
+				"collateral":        &graphql.Field{Type: graphql.NewNonNull(collateralType), Resolve: primaryCollateralResolver, DeprecationReason: "Use collaterals."},
+				"collaterals":       &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(collateralType))), Resolve: collateralsResolver},
+				"collateral_value":  &graphql.Field{Type: graphql.NewNonNull(graphql.Float), Resolve: collateralValueResolver, Description: "Aggregate appraised value of the collateral items."},
+				"loan_to_value":     &graphql.Field{Type: graphql.Float, Resolve: loanToValueResolver, Description: "Proposed amount as a percentage of collateral_value; null while an item is not valued."},
+				"customer":          &graphql.Field{Type: graphql.NewNonNull(customerType), Description: "The PRIMARY party."},
+				"parties":           &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(partyType))), Resolve: partiesResolver},
+				"eligibility":       &graphql.Field{Type: graphql.NewNonNull(eligibilityType), Resolve: eligibilityResolver},
+				"affordability":     &graphql.Field{Type: graphql.NewNonNull(affordabilityType), Resolve: affordabilityResolver},
+				"documents":         &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(loanApplicationDocumentType))), Resolve: documentsResolver},
+				"missing_documents": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(documentTypeEnum))), Description: "Required document types not yet uploaded, or rejected.", Resolve: missingDocumentsResolver},
+				"terms_acceptance":  &graphql.Field{Type: termsAcceptanceType, Description: "The terms accepted at submission; null before."},
+				"created_at":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"updated_at":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
 			},
 		})
 	}
 	return loanApplicationType
 }
+
+// Loan Application Event Type (subscriptions)
+var loanApplicationEventType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "LoanApplicationEvent",
+	Fields: graphql.Fields{
+		"id":               &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"application_uuid": &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"previous_status": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Null when the application was just created.",
+			Resolve: eventField(func(e *LoanApplicationEvent) interface{} {
+				if e.PreviousStatus == "" {
+					return nil
+				}
+				return e.PreviousStatus
+			}),
+		},
+		"status":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"version":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"changed_by": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"changed_at": &graphql.Field{
+			Type:    graphql.NewNonNull(graphql.String),
+			Resolve: eventField(func(e *LoanApplicationEvent) interface{} { return e.ChangedAt.Format(time.RFC3339) }),
+		},
+	},
+})
+
+func eventField(get func(*LoanApplicationEvent) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		event, ok := p.Source.(*LoanApplicationEvent)
+		if !ok {
+			return nil, fmt.Errorf("unexpected event source %T", p.Source)
+		}
+		return get(event), nil
+	}
+}
+
+var loanApplicationEventFilterType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "LoanApplicationEventFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"application_uuid": &graphql.InputObjectFieldConfig{Type: graphql.ID},
+		"statuses":         &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String)), Description: "Only events entering one of these statuses."},
+	},
+})
+
+// Webhook Types
+var webhookType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Webhook",
+	Fields: graphql.Fields{
+		"id":     &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"url":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"events": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+		"created_at": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*Webhook).CreatedAt.Format(time.RFC3339), nil
+			},
+		},
+		"created_by": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+var webhookRegistrationType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "WebhookRegistration",
+	Fields: graphql.Fields{
+		"webhook": &graphql.Field{Type: graphql.NewNonNull(webhookType)},
+		"secret":  &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "HMAC signing secret. It is only returned once."},
+	},
+})
+
+var webhookDeadLetterType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "WebhookDeadLetter",
+	Fields: graphql.Fields{
+		"id":         &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"webhook_id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"url":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"event_id":   &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"event_type": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"payload": &graphql.Field{
+			Type: graphql.NewNonNull(jsonScalar),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				var payload interface{}
+				err := json.Unmarshal(p.Source.(*WebhookDeadLetter).Payload, &payload)
+				return payload, err
+			},
+		},
+		"attempts":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"last_error": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"failed_at": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*WebhookDeadLetter).FailedAt.Format(time.RFC3339), nil
+			},
+		},
+	},
+})
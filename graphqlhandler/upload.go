@@ -0,0 +1,192 @@
+package graphqlhandler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// DefaultMaxUploadBytes is the largest file accepted by UploadMiddleware unless configured otherwise.
+const DefaultMaxUploadBytes = 10 << 20
+
+// MaxRequestBytes is the body limit of a request carrying a file of up to maxUploadBytes:
+// the operations and map parts get room next to the file itself.
+func MaxRequestBytes(maxUploadBytes int64) int64 {
+	return maxUploadBytes + 1<<20
+}
+
+// Upload is a file sent with a GraphQL multipart request.
+type Upload struct {
+	FileName string
+	Size     int64
+	header   *multipart.FileHeader
+}
+
+// Open returns the content of the file.
+func (u *Upload) Open() (multipart.File, error) {
+	return u.header.Open()
+}
+
+// digest returns the hex SHA-256 of the content of the file.
+func (u *Upload) digest() (string, error) {
+	file, err := u.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+type uploadsKey struct{}
+
+// uploadScalar is the Upload type of the multipart request spec. Its value is the key of
+// the file in the request's "map" part, which UploadMiddleware puts into the variables;
+// resolvers turn it into the file with uploadFromContext.
+var uploadScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Upload",
+	Description: "A file sent in a multipart request (https://github.com/jaydenseric/graphql-multipart-request-spec). Input only.",
+	Serialize: func(value interface{}) interface{} {
+		return nil
+	},
+	ParseValue: func(value interface{}) interface{} {
+		if ref, ok := value.(string); ok {
+			return ref
+		}
+		return nil
+	},
+	ParseLiteral: func(valueAST ast.Value) interface{} {
+		return nil // files can only be sent as variables
+	},
+})
+
+// uploadFromContext returns the file an Upload argument refers to.
+func uploadFromContext(ctx context.Context, ref interface{}) (*Upload, error) {
+	key, _ := ref.(string)
+	uploads, _ := ctx.Value(uploadsKey{}).(map[string]*Upload)
+	upload, ok := uploads[key]
+	if !ok {
+		return nil, newLocalizedAPIError(ErrCodeBadRequest, MsgMultipartUploadRequired, "file")
+	}
+	return upload, nil
+}
+
+// UploadMiddleware accepts GraphQL multipart requests: the "operations" part holds the
+// usual JSON request, the "map" part names the variables each file part is bound to.
+// The request is rewritten to a plain JSON request for the next handler, with the files
+// kept in the context. Files larger than maxBytes are rejected with 413. Batched
+// operations are not supported.
+func UploadMiddleware(maxBytes int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if r.Method != http.MethodPost || mediaType != "multipart/form-data" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBytes(maxBytes))
+		if err := r.ParseMultipartForm(8 << 20); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeHTTPError(w, r, http.StatusRequestEntityTooLarge, newLocalizedAPIError(ErrCodeBadRequest, MsgUploadTooLarge, maxBytes))
+				return
+			}
+			writeHTTPError(w, r, http.StatusBadRequest, newLocalizedAPIError(ErrCodeBadRequest, MsgInvalidMultipart, err.Error()))
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		var operations map[string]interface{}
+		if err := json.Unmarshal([]byte(r.FormValue("operations")), &operations); err != nil {
+			writeHTTPError(w, r, http.StatusBadRequest, newLocalizedAPIError(ErrCodeBadRequest, MsgMultipartOperations))
+			return
+		}
+		var fileMap map[string][]string
+		if err := json.Unmarshal([]byte(r.FormValue("map")), &fileMap); err != nil {
+			writeHTTPError(w, r, http.StatusBadRequest, newLocalizedAPIError(ErrCodeBadRequest, MsgMultipartMap))
+			return
+		}
+
+		uploads := make(map[string]*Upload, len(fileMap))
+		for key, paths := range fileMap {
+			files := r.MultipartForm.File[key]
+			if len(files) != 1 {
+				writeHTTPError(w, r, http.StatusBadRequest, newLocalizedAPIError(ErrCodeBadRequest, MsgMultipartFileCount, key))
+				return
+			}
+			if files[0].Size > maxBytes {
+				writeHTTPError(w, r, http.StatusRequestEntityTooLarge, newLocalizedAPIError(ErrCodeBadRequest, MsgUploadTooLarge, maxBytes))
+				return
+			}
+			uploads[key] = &Upload{FileName: files[0].Filename, Size: files[0].Size, header: files[0]}
+			for _, path := range paths {
+				if err := setOperationPath(operations, path, key); err != nil {
+					writeHTTPError(w, r, http.StatusBadRequest, newLocalizedAPIError(ErrCodeBadRequest, MsgMultipartMapPath, path, err.Error()))
+					return
+				}
+			}
+		}
+
+		body, err := json.Marshal(operations)
+		if err != nil {
+			writeHTTPError(w, r, http.StatusBadRequest, newLocalizedAPIError(ErrCodeBadRequest, MsgMultipartOperations))
+			return
+		}
+		rewritten := r.Clone(context.WithValue(r.Context(), uploadsKey{}, uploads))
+		rewritten.Body = io.NopCloser(bytes.NewReader(body))
+		rewritten.ContentLength = int64(len(body))
+		rewritten.Header.Set("Content-Type", "application/json")
+		rewritten.MultipartForm = nil
+		next.ServeHTTP(w, rewritten)
+	})
+}
+
+// setOperationPath sets the value at a dotted path such as "variables.files.0".
+// Only paths below "variables" can be set.
+func setOperationPath(operations map[string]interface{}, path string, value interface{}) error {
+	segments := strings.Split(path, ".")
+	if len(segments) < 2 || segments[0] != "variables" {
+		return fmt.Errorf("files can only be bound to variables")
+	}
+	var current interface{} = operations
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch node := current.(type) {
+		case map[string]interface{}:
+			if last {
+				node[segment] = value
+				return nil
+			}
+			current = node[segment]
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return fmt.Errorf("no list element %q", segment)
+			}
+			if last {
+				node[index] = value
+				return nil
+			}
+			current = node[index]
+		default:
+			return fmt.Errorf("no field %q", segment)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,364 @@
+package graphqlhandler
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// bundledVehicleCatalog is the catalog used until LoadVehicleCatalogFile is called.
+//
+//go:embed catalog/vehicles.json
+var bundledVehicleCatalog []byte
+
+// VehicleVariant is one entry of the vehicle catalog: a variant of a brand's model in
+// a collateral category and the years it was produced.
+type VehicleVariant struct {
+	Category string `json:"category"`
+	Brand    string `json:"brand"`
+	Model    string `json:"model"`
+	Variant  string `json:"variant"`
+	YearFrom int    `json:"year_from"`
+	YearTo   int    `json:"year_to,omitempty"` // 0 while still in production
+}
+
+// producedIn reports whether the variant was produced in the year.
+func (v VehicleVariant) producedIn(year int) bool {
+	return year >= v.YearFrom && (v.YearTo == 0 || year <= v.YearTo)
+}
+
+// key identifies the variant regardless of spelling.
+func (v VehicleVariant) key() string {
+	return strings.Join([]string{v.Category, catalogNameKey(v.Brand), catalogNameKey(v.Model), catalogNameKey(v.Variant)}, "|")
+}
+
+// catalogNameKey compares catalog names without case and whitespace differences, so
+// "TOYOTA" finds "Toyota".
+func catalogNameKey(name string) string {
+	return strings.ToLower(normalizeText(name))
+}
+
+// vehicleCatalog holds the variants of every category. When it was loaded from a file,
+// edits are written back to it.
+type vehicleCatalog struct {
+	mu       sync.RWMutex
+	variants []VehicleVariant // sorted by category, brand, model and variant
+	path     string
+}
+
+var vehicles = &vehicleCatalog{}
+
+func init() {
+	if err := vehicles.load(bundledVehicleCatalog, ""); err != nil {
+		panic(fmt.Sprintf("bundled vehicle catalog: %v", err))
+	}
+}
+
+// LoadVehicleCatalogFile replaces the vehicle catalog with the file's content. Changes
+// made through the admin mutations are saved to the file.
+func LoadVehicleCatalogFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("vehicle catalog: read file: %w", err)
+	}
+	if err := vehicles.load(raw, path); err != nil {
+		return fmt.Errorf("vehicle catalog: %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *vehicleCatalog) load(raw []byte, path string) error {
+	var file struct {
+		Variants []VehicleVariant `json:"variants"`
+	}
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return fmt.Errorf("parse file: %w", err)
+	}
+	seen := make(map[string]bool, len(file.Variants))
+	for i, variant := range file.Variants {
+		if err := validateVehicleVariant(variant); err != nil {
+			return fmt.Errorf("variants[%d]: %w", i, err)
+		}
+		if seen[variant.key()] {
+			return fmt.Errorf("variants[%d]: duplicate %s %s %s", i, variant.Brand, variant.Model, variant.Variant)
+		}
+		seen[variant.key()] = true
+	}
+	sortVehicleVariants(file.Variants)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.variants = file.Variants
+	c.path = path
+	return nil
+}
+
+func sortVehicleVariants(variants []VehicleVariant) {
+	sort.Slice(variants, func(i, j int) bool { return variants[i].key() < variants[j].key() })
+}
+
+// validateVehicleVariant checks a catalog entry.
+func validateVehicleVariant(v VehicleVariant) error {
+	if !hasMakeAndModel(v.Category) {
+		return invalidInput("category", MsgNotForCategory, "brand", v.Category)
+	}
+	for _, field := range []struct{ name, value string }{{"brand", v.Brand}, {"model", v.Model}, {"variant", v.Variant}} {
+		if err := validateText(field.name, field.value, 1, 100); err != nil {
+			return err
+		}
+	}
+	maxYear := time.Now().Year() + 1
+	if v.YearFrom < 1900 || v.YearFrom > maxYear {
+		return invalidInput("year_from", MsgOutOfRange, "year_from", 1900, maxYear)
+	}
+	if v.YearTo != 0 && (v.YearTo < v.YearFrom || v.YearTo > maxYear) {
+		return invalidInput("year_to", MsgOutOfRange, "year_to", v.YearFrom, maxYear)
+	}
+	return nil
+}
+
+// match finds the catalog variant of a collateral item. The model may be omitted when
+// the variant name identifies it within the brand.
+func (c *vehicleCatalog) match(category, brand, model, variant string) (VehicleVariant, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var brandFound, modelFound bool
+	var matches []VehicleVariant
+	for _, v := range c.variants {
+		if v.Category != category || catalogNameKey(v.Brand) != catalogNameKey(brand) {
+			continue
+		}
+		brandFound = true
+		if model != "" && catalogNameKey(v.Model) != catalogNameKey(model) {
+			continue
+		}
+		modelFound = true
+		if catalogNameKey(v.Variant) == catalogNameKey(variant) {
+			matches = append(matches, v)
+		}
+	}
+	switch {
+	case !brandFound:
+		return VehicleVariant{}, invalidInput("brand", MsgUnknownBrand, brand, category)
+	case !modelFound:
+		return VehicleVariant{}, invalidInput("model", MsgUnknownModel, model, brand)
+	case len(matches) == 0:
+		return VehicleVariant{}, invalidInput("variant", MsgUnknownVariant, variant, strings.TrimSpace(brand+" "+model))
+	case len(matches) > 1:
+		return VehicleVariant{}, invalidInput("model", MsgRequired, "model")
+	}
+	return matches[0], nil
+}
+
+// search returns the variants of a category whose brand, model and variant contain
+// the given terms; empty terms match everything.
+func (c *vehicleCatalog) search(category, brand, model, variant string) []VehicleVariant {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var found []VehicleVariant
+	for _, v := range c.variants {
+		if v.Category == category &&
+			strings.Contains(catalogNameKey(v.Brand), catalogNameKey(brand)) &&
+			strings.Contains(catalogNameKey(v.Model), catalogNameKey(model)) &&
+			strings.Contains(catalogNameKey(v.Variant), catalogNameKey(variant)) {
+			found = append(found, v)
+		}
+	}
+	return found
+}
+
+// upsert adds or replaces a variant. An existing brand or model keeps its spelling so
+// that "TOYOTA" does not start a second Toyota.
+func (c *vehicleCatalog) upsert(variant VehicleVariant) (VehicleVariant, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, v := range c.variants {
+		if v.Category != variant.Category || catalogNameKey(v.Brand) != catalogNameKey(variant.Brand) {
+			continue
+		}
+		variant.Brand = v.Brand
+		if catalogNameKey(v.Model) == catalogNameKey(variant.Model) {
+			variant.Model = v.Model
+		}
+	}
+	variants := make([]VehicleVariant, 0, len(c.variants)+1)
+	for _, v := range c.variants {
+		if v.key() != variant.key() {
+			variants = append(variants, v)
+		}
+	}
+	variants = append(variants, variant)
+	sortVehicleVariants(variants)
+	if err := c.save(variants); err != nil {
+		return VehicleVariant{}, err
+	}
+	c.variants = variants
+	return variant, nil
+}
+
+// remove deletes a variant and reports whether it existed.
+func (c *vehicleCatalog) remove(variant VehicleVariant) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	variants := make([]VehicleVariant, 0, len(c.variants))
+	for _, v := range c.variants {
+		if v.key() != variant.key() {
+			variants = append(variants, v)
+		}
+	}
+	if len(variants) == len(c.variants) {
+		return false, nil
+	}
+	if err := c.save(variants); err != nil {
+		return false, err
+	}
+	c.variants = variants
+	return true, nil
+}
+
+// save writes the variants to the catalog file, if there is one. The file is replaced
+// atomically so that a crash cannot leave it half written.
+func (c *vehicleCatalog) save(variants []VehicleVariant) error {
+	if c.path == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(map[string]interface{}{"variants": variants}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return fmt.Errorf("vehicle catalog: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(raw, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("vehicle catalog: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("vehicle catalog: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("vehicle catalog: %w", err)
+	}
+	return nil
+}
+
+// validateCatalogVehicle checks the brand, model, variant and manufacturing year of a
+// collateral item against the catalog.
+func validateCatalogVehicle(input map[string]interface{}) error {
+	category, _ := input["category"].(string)
+	brand, _ := input["brand"].(string)
+	model, _ := input["model"].(string)
+	variant, _ := input["variant"].(string)
+	year, _ := input["manufacturing_year"].(int)
+	entry, err := vehicles.match(category, brand, model, variant)
+	if err != nil {
+		return err
+	}
+	if !entry.producedIn(year) {
+		if entry.YearTo == 0 {
+			return invalidInput("manufacturing_year", MsgProducedSince, entry.Brand, entry.Model, entry.Variant, entry.YearFrom)
+		}
+		return invalidInput("manufacturing_year", MsgProducedBetween, entry.Brand, entry.Model, entry.Variant, entry.YearFrom, entry.YearTo)
+	}
+	return nil
+}
+
+// limitCatalogResults applies the limit argument of the catalog queries.
+func limitCatalogResults(p graphql.ResolveParams, count int) (int, error) {
+	limit, _ := p.Args["limit"].(int)
+	if limit < 1 || limit > 100 {
+		return 0, invalidInput("limit", MsgOutOfRange, "limit", 1, 100)
+	}
+	return min(limit, count), nil
+}
+
+// vehicleBrandsResolver returns the brands of a category matching the search text,
+// for typeahead.
+var vehicleBrandsResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	category, _ := p.Args["category"].(string)
+	search, _ := p.Args["search"].(string)
+	brands := []string{}
+	for _, v := range vehicles.search(category, search, "", "") {
+		if len(brands) == 0 || brands[len(brands)-1] != v.Brand {
+			brands = append(brands, v.Brand)
+		}
+	}
+	limit, err := limitCatalogResults(p, len(brands))
+	if err != nil {
+		return nil, err
+	}
+	return brands[:limit], nil
+}
+
+// vehicleVariantsResolver returns the variants of a brand, optionally narrowed to a
+// model, a search text and a production year.
+var vehicleVariantsResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	category, _ := p.Args["category"].(string)
+	brand, _ := p.Args["brand"].(string)
+	model, _ := p.Args["model"].(string)
+	search, _ := p.Args["search"].(string)
+	year, hasYear := p.Args["year"].(int)
+	variants := []VehicleVariant{}
+	for _, v := range vehicles.search(category, "", "", search) {
+		if catalogNameKey(v.Brand) != catalogNameKey(brand) ||
+			(model != "" && catalogNameKey(v.Model) != catalogNameKey(model)) ||
+			(hasYear && !v.producedIn(year)) {
+			continue
+		}
+		variants = append(variants, v)
+	}
+	limit, err := limitCatalogResults(p, len(variants))
+	if err != nil {
+		return nil, err
+	}
+	return variants[:limit], nil
+}
+
+// vehicleVariantArgs maps the arguments identifying a catalog variant.
+func vehicleVariantArgs(args map[string]interface{}) VehicleVariant {
+	normalizeInput(args)
+	category, _ := args["category"].(string)
+	brand, _ := args["brand"].(string)
+	model, _ := args["model"].(string)
+	variant, _ := args["variant"].(string)
+	yearFrom, _ := args["year_from"].(int)
+	yearTo, _ := args["year_to"].(int)
+	return VehicleVariant{Category: category, Brand: brand, Model: model, Variant: variant, YearFrom: yearFrom, YearTo: yearTo}
+}
+
+var upsertVehicleVariantResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	input, _ := p.Args["input"].(map[string]interface{})
+	variant := vehicleVariantArgs(input)
+	if err := validateVehicleVariant(variant); err != nil {
+		return nil, invalidField("input", err)
+	}
+	saved, err := vehicles.upsert(variant)
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(p.Context, "vehicle variant saved", "category", saved.Category, "brand", saved.Brand, "model", saved.Model, "variant", saved.Variant)
+	return saved, nil
+}
+
+var deleteVehicleVariantResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	variant := vehicleVariantArgs(p.Args)
+	removed, err := vehicles.remove(variant)
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		return nil, newLocalizedAPIError(ErrCodeNotFound, MsgVehicleVariantNotFound, variant.Brand, variant.Model, variant.Variant)
+	}
+	slog.InfoContext(p.Context, "vehicle variant deleted", "category", variant.Category, "brand", variant.Brand, "model", variant.Model, "variant", variant.Variant)
+	return true, nil
+}
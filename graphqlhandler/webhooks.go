@@ -0,0 +1,656 @@
+package graphqlhandler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+)
+
+// Headers sent with every webhook delivery.
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature" // "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookIDHeader        = "X-Webhook-ID" // Event ID, stable across retries
+)
+
+// Webhook is a partner endpoint subscribed to lifecycle events of one tenant.
+type Webhook struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"` // HMAC key, only revealed once at registration
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy string    `json:"created_by"`
+}
+
+func (w *Webhook) subscribedTo(eventType string) bool {
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDeadLetter is a delivery that failed on every attempt.
+type WebhookDeadLetter struct {
+	ID        string          `json:"id"`
+	TenantID  string          `json:"tenant_id"`
+	WebhookID string          `json:"webhook_id"`
+	URL       string          `json:"url"`
+	EventID   string          `json:"event_id"`
+	EventType string          `json:"event_type"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error"`
+	FailedAt  time.Time       `json:"failed_at"`
+}
+
+// webhookRegistry holds webhook subscriptions and dead letters of every tenant.
+type webhookRegistry struct {
+	mu          sync.RWMutex
+	hooks       map[string]*Webhook
+	deadLetters []*WebhookDeadLetter
+	redeliver   []*WebhookDeadLetter // dead letters queued for another round of attempts
+}
+
+var webhooks = &webhookRegistry{hooks: make(map[string]*Webhook)}
+
+func (r *webhookRegistry) register(hook *Webhook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks[hook.ID] = hook
+}
+
+func (r *webhookRegistry) remove(tenantID, id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hook, ok := r.hooks[id]
+	if !ok || hook.TenantID != tenantID {
+		return false
+	}
+	delete(r.hooks, id)
+	return true
+}
+
+func (r *webhookRegistry) get(id string) (*Webhook, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	hook, ok := r.hooks[id]
+	return hook, ok
+}
+
+// forTenant returns the tenant's webhooks, oldest first.
+func (r *webhookRegistry) forTenant(tenantID string) []*Webhook {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var hooks []*Webhook
+	for _, hook := range r.hooks {
+		if hook.TenantID == tenantID {
+			hooks = append(hooks, hook)
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].CreatedAt.Before(hooks[j].CreatedAt) })
+	return hooks
+}
+
+func (r *webhookRegistry) addDeadLetter(letter *WebhookDeadLetter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deadLetters = append(r.deadLetters, letter)
+}
+
+// deadLettersForTenant returns the tenant's dead letters, newest first.
+func (r *webhookRegistry) deadLettersForTenant(tenantID string) []*WebhookDeadLetter {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var letters []*WebhookDeadLetter
+	for i := len(r.deadLetters) - 1; i >= 0; i-- {
+		if r.deadLetters[i].TenantID == tenantID {
+			letters = append(letters, r.deadLetters[i])
+		}
+	}
+	return letters
+}
+
+// queueRedelivery moves a dead letter back to the dispatcher.
+func (r *webhookRegistry) queueRedelivery(tenantID, id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, letter := range r.deadLetters {
+		if letter.ID == id && letter.TenantID == tenantID {
+			r.deadLetters = append(r.deadLetters[:i], r.deadLetters[i+1:]...)
+			r.redeliver = append(r.redeliver, letter)
+			return true
+		}
+	}
+	return false
+}
+
+func (r *webhookRegistry) takeRedeliveries() []*WebhookDeadLetter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	letters := r.redeliver
+	r.redeliver = nil
+	return letters
+}
+
+// signWebhookPayload computes the signature header value for a payload sent at t.
+// Receivers recompute the HMAC over "<t>.<body>" and should reject stale timestamps.
+func signWebhookPayload(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookPayload is the JSON body of a delivery. It carries no customer data.
+func webhookPayload(event OutboxEvent) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"id":          event.ID,
+		"type":        event.Type,
+		"occurred_at": event.OccurredAt.Format(time.RFC3339),
+		"tenant_id":   event.TenantID,
+		"data": map[string]interface{}{
+			"application_uuid": event.ApplicationUUID,
+			"previous_status":  event.PreviousStatus,
+			"status":           event.Status,
+			"version":          event.Version,
+			"actor":            event.Actor,
+		},
+	})
+}
+
+// webhookMetrics is published at /debug/vars under "webhooks".
+var webhookMetrics = expvar.NewMap("webhooks")
+
+// webhookDelivery is one event on its way to one webhook.
+type webhookDelivery struct {
+	eventID     string
+	eventType   string
+	tenantID    string
+	webhookID   string
+	payload     []byte
+	attempts    int
+	nextAttempt time.Time
+	lastError   string
+}
+
+// WebhookDispatcher drains the outbox of every tenant and delivers each event to the
+// subscribed webhooks, retrying failures with exponential backoff. An outbox event is
+// acknowledged once every delivery succeeded or was moved to the dead-letter list.
+type WebhookDispatcher struct {
+	Client      *http.Client
+	Clock       Clock
+	Interval    time.Duration
+	MaxAttempts int
+	BaseBackoff time.Duration // delay after the first failure, doubled on each further failure
+	MaxBackoff  time.Duration
+	// MaxConcurrentPerEndpoint bounds the deliveries in flight to one webhook, so a slow
+	// endpoint holds up only its own backlog.
+	MaxConcurrentPerEndpoint int
+
+	deliveries []*webhookDelivery
+	remaining  map[string]int // outstanding deliveries per outbox event
+}
+
+// NewWebhookDispatcher returns a dispatcher making 8 attempts spread over about an hour,
+// with up to 4 deliveries in flight per endpoint.
+func NewWebhookDispatcher(interval time.Duration) *WebhookDispatcher {
+	registerOutboxConsumer(outboxConsumerWebhooks)
+	return &WebhookDispatcher{
+		Client:                   newWebhookClient(),
+		Clock:                    SystemClock{},
+		Interval:                 interval,
+		MaxAttempts:              8,
+		BaseBackoff:              30 * time.Second,
+		MaxBackoff:               time.Hour,
+		MaxConcurrentPerEndpoint: 4,
+		remaining:                make(map[string]int),
+	}
+}
+
+// nonPublicPrefixes are the special-purpose ranges of the IANA IPv4 and IPv6 registries
+// that global unicast addresses may still fall into. None of them reaches a partner:
+// they are shared (CGNAT, where some cloud metadata services live), reserved,
+// benchmarking, documentation or translation ranges that lead into internal networks.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.88.99.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/96"),          // unspecified, loopback and IPv4-compatible
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/23"), // includes Teredo
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"), // 6to4
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("fec0::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// isPublicAddress reports whether a webhook may be delivered to ip: a global unicast
+// address outside nonPublicPrefixes. IPv4-mapped IPv6 addresses are checked as IPv4.
+func isPublicAddress(ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// newWebhookClient returns an HTTP client that only connects to public addresses. The
+// check runs on the address of every connection, after name resolution, so a host
+// that passed registration cannot later be pointed at an internal service (DNS
+// rebinding). Proxies and redirects are not followed.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicAddress(ip) {
+				return fmt.Errorf("webhook address %s is not public", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Run dispatches immediately and then every Interval until ctx is cancelled.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		d.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt after the given number of failures.
+func (d *WebhookDispatcher) backoff(failures int) time.Duration {
+	delay := d.BaseBackoff
+	for i := 1; i < failures && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.MaxBackoff {
+		delay = d.MaxBackoff
+	}
+	return delay
+}
+
+// RunOnce picks up new outbox events and attempts every delivery that is due.
+// It is not safe for concurrent use.
+func (d *WebhookDispatcher) RunOnce(ctx context.Context) {
+	now := d.Clock.Now()
+	for id, store := range tenants.allStores() {
+		// Events still being retried stay pending, so look past them for new ones.
+		for _, event := range store.PendingEvents(outboxConsumerWebhooks, len(d.remaining)+100) {
+			if _, queued := d.remaining[event.ID]; queued {
+				continue
+			}
+			d.enqueue(ctx, id, store, event, now)
+		}
+	}
+	for _, letter := range webhooks.takeRedeliveries() {
+		d.deliveries = append(d.deliveries, &webhookDelivery{
+			eventID:     letter.EventID,
+			eventType:   letter.EventType,
+			tenantID:    letter.TenantID,
+			webhookID:   letter.WebhookID,
+			payload:     letter.Payload,
+			nextAttempt: now,
+		})
+	}
+
+	var due, pending []*webhookDelivery
+	for _, delivery := range d.deliveries {
+		if ctx.Err() != nil || delivery.nextAttempt.After(now) {
+			pending = append(pending, delivery)
+		} else {
+			due = append(due, delivery)
+		}
+	}
+	accepted := d.attemptAll(ctx, due)
+	for i, delivery := range due {
+		switch {
+		case accepted[i]:
+			d.done(delivery)
+		case delivery.attempts >= d.MaxAttempts:
+			d.deadLetter(ctx, delivery)
+			d.done(delivery)
+		default:
+			delivery.nextAttempt = now.Add(d.backoff(delivery.attempts))
+			pending = append(pending, delivery)
+		}
+	}
+	d.deliveries = pending
+}
+
+// attemptAll sends the deliveries concurrently, with at most MaxConcurrentPerEndpoint
+// of them in flight to one webhook, and reports which ones were accepted.
+func (d *WebhookDispatcher) attemptAll(ctx context.Context, deliveries []*webhookDelivery) []bool {
+	accepted := make([]bool, len(deliveries))
+	slots := make(map[string]chan struct{})
+	var wg sync.WaitGroup
+	for i, delivery := range deliveries {
+		slot, ok := slots[delivery.webhookID]
+		if !ok {
+			slot = make(chan struct{}, max(d.MaxConcurrentPerEndpoint, 1))
+			slots[delivery.webhookID] = slot
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slot <- struct{}{}
+			defer func() { <-slot }()
+			accepted[i] = d.attempt(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+	return accepted
+}
+
+// enqueue fans an outbox event out to the tenant's subscribed webhooks.
+func (d *WebhookDispatcher) enqueue(ctx context.Context, tenantID string, store LoanApplicationStore, event OutboxEvent, now time.Time) {
+	payload, err := webhookPayload(event)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to encode webhook payload", "event_id", event.ID, "error", err)
+		return
+	}
+	count := 0
+	for _, hook := range webhooks.forTenant(tenantID) {
+		if !hook.subscribedTo(event.Type) {
+			continue
+		}
+		d.deliveries = append(d.deliveries, &webhookDelivery{
+			eventID:     event.ID,
+			eventType:   event.Type,
+			tenantID:    tenantID,
+			webhookID:   hook.ID,
+			payload:     payload,
+			nextAttempt: now,
+		})
+		count++
+	}
+	if count == 0 {
+		store.AckEvent(outboxConsumerWebhooks, event.ID)
+		return
+	}
+	d.remaining[event.ID] = count
+}
+
+// done acknowledges the outbox event once its last delivery has finished.
+func (d *WebhookDispatcher) done(delivery *webhookDelivery) {
+	count, ok := d.remaining[delivery.eventID]
+	if !ok {
+		return // a redelivered dead letter; its event was acknowledged already
+	}
+	if count > 1 {
+		d.remaining[delivery.eventID] = count - 1
+		return
+	}
+	delete(d.remaining, delivery.eventID)
+	if store := tenants.store(delivery.tenantID); store != nil {
+		store.AckEvent(outboxConsumerWebhooks, delivery.eventID)
+	}
+}
+
+// attempt sends one delivery and reports whether the endpoint accepted it with a 2xx status.
+func (d *WebhookDispatcher) attempt(ctx context.Context, delivery *webhookDelivery) bool {
+	delivery.attempts++
+	webhookMetrics.Add("attempts", 1)
+	hook, ok := webhooks.get(delivery.webhookID)
+	if !ok {
+		// The webhook was deleted; there is nobody left to deliver to.
+		return true
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.payload))
+	if err != nil {
+		delivery.lastError = err.Error()
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.eventType)
+	req.Header.Set(WebhookIDHeader, delivery.eventID)
+	req.Header.Set(WebhookSignatureHeader, signWebhookPayload(hook.Secret, d.Clock.Now(), delivery.payload))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		delivery.lastError = err.Error()
+	} else {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			webhookMetrics.Add("delivered", 1)
+			return true
+		}
+		delivery.lastError = fmt.Sprintf("endpoint answered %s", resp.Status)
+	}
+	webhookMetrics.Add("failures", 1)
+	slog.WarnContext(ctx, "webhook delivery failed",
+		"webhook_id", hook.ID, "event_id", delivery.eventID, "attempt", delivery.attempts, "error", delivery.lastError)
+	return false
+}
+
+func (d *WebhookDispatcher) deadLetter(ctx context.Context, delivery *webhookDelivery) {
+	hook, _ := webhooks.get(delivery.webhookID)
+	letter := &WebhookDeadLetter{
+		ID:        uuid.New().String(),
+		TenantID:  delivery.tenantID,
+		WebhookID: delivery.webhookID,
+		EventID:   delivery.eventID,
+		EventType: delivery.eventType,
+		Payload:   delivery.payload,
+		Attempts:  delivery.attempts,
+		LastError: delivery.lastError,
+		FailedAt:  d.Clock.Now(),
+	}
+	if hook != nil {
+		letter.URL = hook.URL
+	}
+	webhooks.addDeadLetter(letter)
+	webhookMetrics.Add("dead_lettered", 1)
+	slog.ErrorContext(ctx, "webhook delivery dead-lettered", "webhook_id", delivery.webhookID, "event_id", delivery.eventID, "attempts", delivery.attempts)
+}
+
+// --- Resolvers ---
+
+// validateWebhookURL accepts absolute https URLs whose host resolves only to public
+// addresses. Deliveries check the address again when they connect.
+func validateWebhookURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return invalidInput("url", MsgWebhookURL)
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return invalidInput("url", MsgWebhookHostUnresolved, u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !isPublicAddress(addr.IP) {
+			return invalidInput("url", MsgWebhookHostNotPublic, u.Hostname())
+		}
+	}
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+var registerWebhookResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	principal, err := requirePrincipal(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	tenant, _, err := requireTenant(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	rawURL, _ := p.Args["url"].(string)
+	if err := validateWebhookURL(p.Context, rawURL); err != nil {
+		return nil, err
+	}
+	list, _ := p.Args["events"].([]interface{})
+	if len(list) == 0 {
+		return nil, invalidInput("events", MsgRequired, "events")
+	}
+	var events []string
+	for _, item := range list {
+		eventType := item.(string)
+		known := false
+		for _, t := range lifecycleEventTypes {
+			known = known || t == eventType
+		}
+		if !known {
+			return nil, invalidInput("events", MsgUnknownEventType, eventType)
+		}
+		events = append(events, eventType)
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	hook := &Webhook{
+		ID:        uuid.New().String(),
+		TenantID:  tenant.ID,
+		URL:       rawURL,
+		Events:    events,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+		CreatedBy: principal.UserID,
+	}
+	webhooks.register(hook)
+	slog.InfoContext(p.Context, "webhook registered", "webhook_id", hook.ID, "events", events)
+	return map[string]interface{}{"webhook": hook, "secret": secret}, nil
+}
+
+var deleteWebhookResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	tenant, _, err := requireTenant(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := p.Args["id"].(string)
+	if !webhooks.remove(tenant.ID, id) {
+		return nil, newLocalizedAPIError(ErrCodeNotFound, MsgWebhookNotFound, id)
+	}
+	slog.InfoContext(p.Context, "webhook deleted", "webhook_id", id)
+	return true, nil
+}
+
+var webhooksResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	tenant, _, err := requireTenant(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	hooks := webhooks.forTenant(tenant.ID)
+	if hooks == nil {
+		hooks = []*Webhook{}
+	}
+	return hooks, nil
+}
+
+var webhookDeadLettersResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	tenant, _, err := requireTenant(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	limit, _ := p.Args["limit"].(int)
+	offset, _ := p.Args["offset"].(int)
+	if limit < 1 || limit > 100 {
+		return nil, invalidInput("limit", MsgOutOfRange, "limit", 1, 100)
+	}
+	if offset < 0 {
+		return nil, invalidInput("offset", MsgNotNegative, "offset")
+	}
+	letters := webhooks.deadLettersForTenant(tenant.ID)
+	if offset >= len(letters) {
+		return []*WebhookDeadLetter{}, nil
+	}
+	letters = letters[offset:]
+	if len(letters) > limit {
+		letters = letters[:limit]
+	}
+	return letters, nil
+}
+
+var redeliverWebhookDeadLetterResolver = func(p graphql.ResolveParams) (interface{}, error) {
+	tenant, _, err := requireTenant(p.Context)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := p.Args["id"].(string)
+	if !webhooks.queueRedelivery(tenant.ID, id) {
+		return nil, newLocalizedAPIError(ErrCodeNotFound, MsgDeadLetterNotFound, id)
+	}
+	return true, nil
+}
@@ -0,0 +1,242 @@
+package graphqlhandler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// verifySignature checks a signature header the way a receiver would.
+func verifySignature(secret, header string, body []byte) bool {
+	ts, mac, ok := strings.Cut(header, ",v1=")
+	ts, found := strings.CutPrefix(ts, "t=")
+	if !ok || !found {
+		return false
+	}
+	expected := hmac.New(sha256.New, []byte(secret))
+	expected.Write([]byte(ts + "." + string(body)))
+	got, err := hex.DecodeString(mac)
+	return err == nil && hmac.Equal(got, expected.Sum(nil))
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"1"}`)
+	header := signWebhookPayload("whsec_test", at, body)
+	if !strings.HasPrefix(header, "t="+strconv.FormatInt(at.Unix(), 10)+",v1=") {
+		t.Fatalf("header %q", header)
+	}
+	if !verifySignature("whsec_test", header, body) {
+		t.Fatal("signature does not verify")
+	}
+	if verifySignature("whsec_other", header, body) || verifySignature("whsec_test", header, []byte(`{"id":"2"}`)) {
+		t.Fatal("signature verifies with another secret or body")
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	d := &WebhookDispatcher{BaseBackoff: 30 * time.Second, MaxBackoff: time.Hour}
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute,
+		16 * time.Minute, 32 * time.Minute, time.Hour, time.Hour}
+	for i, w := range want {
+		if got := d.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://93.184.216.34/hooks", true},
+		{"http://93.184.216.34/hooks", false},
+		{"/hooks", false},
+		{"https://127.0.0.1/hooks", false},
+		{"https://localhost/hooks", false},
+		{"https://[::1]/hooks", false},
+		{"https://10.0.0.8/hooks", false},
+		{"https://192.168.1.1/hooks", false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"https://0.0.0.0/hooks", false},
+		{"https://[::ffff:127.0.0.1]/hooks", false},
+	}
+	for _, tt := range tests {
+		if err := validateWebhookURL(context.Background(), tt.url); (err == nil) != tt.ok {
+			t.Errorf("validateWebhookURL(%q) = %v", tt.url, err)
+		}
+	}
+}
+
+func TestWebhookClientRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	if resp, err := newWebhookClient().Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("client connected to a loopback address")
+	}
+
+	for _, host := range []string{
+		"100.64.0.1", "100.100.100.200", "0.1.2.3", "198.18.0.1", "198.19.255.254", "192.0.0.170",
+		"240.0.0.1", "255.255.255.255", "[64:ff9b::a00:1]", "[64:ff9b::7f00:1]", "[64:ff9b:1::a00:1]",
+		"[fd00::1]", "[fc00::1]", "[::ffff:100.100.100.200]", "[::a00:1]", "[2002:a00:1::1]", "[2001::1]",
+	} {
+		if resp, err := newWebhookClient().Get("https://" + host + "/hooks"); err == nil {
+			resp.Body.Close()
+			t.Errorf("client connected to %s", host)
+		} else if !strings.Contains(err.Error(), "is not public") {
+			t.Errorf("%s: %v, want the address refused", host, err)
+		}
+	}
+	for _, ip := range []string{"93.184.216.34", "100.128.0.1", "2606:4700:4700::1111"} {
+		if !isPublicAddress(net.ParseIP(ip)) {
+			t.Errorf("%s refused", ip)
+		}
+	}
+}
+
+// useWebhooks gives a test an empty webhook registry and a tenant "acme" whose
+// outbox is consumed by the dispatcher.
+func useWebhooks(t *testing.T) {
+	t.Helper()
+	previous := webhooks
+	webhooks = &webhookRegistry{hooks: make(map[string]*Webhook)}
+	t.Cleanup(func() { webhooks = previous })
+	registerOutboxConsumer(outboxConsumerWebhooks)
+	useTenants(t, "acme")
+}
+
+// testDispatcher returns a dispatcher posting through client, driven by clock.
+func testDispatcher(client *http.Client, clock Clock) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		Client:                   client,
+		Clock:                    clock,
+		MaxAttempts:              3,
+		BaseBackoff:              time.Minute,
+		MaxBackoff:               time.Hour,
+		MaxConcurrentPerEndpoint: 2,
+		remaining:                make(map[string]int),
+	}
+}
+
+func registerTestWebhook(url string) *Webhook {
+	hook := &Webhook{ID: "hook-" + url, TenantID: "acme", URL: url, Events: []string{EventApplicationCreated},
+		Secret: "whsec_test", CreatedAt: time.Now()}
+	webhooks.register(hook)
+	return hook
+}
+
+func TestWebhookDispatcherDeliversSignedEvents(t *testing.T) {
+	useWebhooks(t)
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !verifySignature("whsec_test", r.Header.Get(WebhookSignatureHeader), body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		received = append(received, r.Header.Get(WebhookEventHeader))
+		mu.Unlock()
+	}))
+	defer server.Close()
+	registerTestWebhook(server.URL)
+	createDraft(t, requestContext(t, agentOf("acme"), ""), testDraft("ID400000001"))
+
+	testDispatcher(server.Client(), &fakeClock{now: time.Now()}).RunOnce(context.Background())
+	if len(received) != 1 || received[0] != EventApplicationCreated {
+		t.Fatalf("received %v", received)
+	}
+	if pending := tenants.store("acme").PendingEvents(outboxConsumerWebhooks, 10); len(pending) != 0 {
+		t.Fatalf("delivered event not acknowledged: %v", pending)
+	}
+}
+
+func TestWebhookDispatcherDeadLettersAfterMaxAttempts(t *testing.T) {
+	useWebhooks(t)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	hook := registerTestWebhook(server.URL)
+	createDraft(t, requestContext(t, agentOf("acme"), ""), testDraft("ID400000002"))
+
+	clock := &fakeClock{now: time.Now()}
+	dispatcher := testDispatcher(server.Client(), clock)
+	// Attempts at 0, then after 1 and 2 more minutes of backoff; none before they are due.
+	for _, step := range []struct {
+		advance  time.Duration
+		requests int32
+	}{{0, 1}, {59 * time.Second, 1}, {time.Second, 2}, {time.Minute, 2}, {time.Minute, 3}} {
+		clock.now = clock.now.Add(step.advance)
+		dispatcher.RunOnce(context.Background())
+		if got := requests.Load(); got != step.requests {
+			t.Fatalf("after %v: %d requests, want %d", step.advance, got, step.requests)
+		}
+	}
+
+	letters := webhooks.deadLettersForTenant("acme")
+	if len(letters) != 1 {
+		t.Fatalf("%d dead letters, want 1", len(letters))
+	}
+	if letter := letters[0]; letter.Attempts != 3 || letter.WebhookID != hook.ID || !strings.Contains(letter.LastError, "500") {
+		t.Fatalf("dead letter %+v", letter)
+	}
+	if pending := tenants.store("acme").PendingEvents(outboxConsumerWebhooks, 10); len(pending) != 0 {
+		t.Fatalf("dead-lettered event not acknowledged: %v", pending)
+	}
+
+	if !webhooks.queueRedelivery("acme", letters[0].ID) {
+		t.Fatal("dead letter not queued for redelivery")
+	}
+	dispatcher.RunOnce(context.Background())
+	if got := requests.Load(); got != 4 {
+		t.Fatalf("redelivery made %d requests in total, want 4", got)
+	}
+}
+
+func TestWebhookDispatcherBoundsConcurrencyPerEndpoint(t *testing.T) {
+	useWebhooks(t)
+	var inFlight, peak, delivered atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		inFlight.Add(-1)
+		delivered.Add(1)
+	}))
+	defer server.Close()
+	registerTestWebhook(server.URL)
+	ctx := requestContext(t, agentOf("acme"), "")
+	for _, id := range []string{"ID400000011", "ID400000012", "ID400000013", "ID400000014", "ID400000015"} {
+		createDraft(t, ctx, testDraft(id))
+	}
+
+	testDispatcher(server.Client(), &fakeClock{now: time.Now()}).RunOnce(context.Background())
+	if delivered.Load() != 5 {
+		t.Fatalf("delivered %d events, want 5", delivered.Load())
+	}
+	if peak.Load() != 2 {
+		t.Fatalf("%d deliveries in flight at once, want 2", peak.Load())
+	}
+}
@@ -0,0 +1,384 @@
+package graphqlhandler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// graphqlTransportWS is the WebSocket subprotocol defined by
+// https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+const graphqlTransportWS = "graphql-transport-ws"
+
+// Close codes of the graphql-transport-ws protocol.
+const (
+	closeInvalidMessage           = 4400
+	closeUnauthorized             = 4401
+	closeForbidden                = 4403
+	closeSubprotocolNotAcceptable = 4406
+	closeInitTimeout              = 4408
+	closeSubscriberExists         = 4409
+	closeTooManyInitRequests      = 4429
+)
+
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type wsSubscribePayload struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// SubscriptionHandler serves GraphQL operations, subscriptions in particular, over
+// WebSocket using graphql-transport-ws. It expects the same middleware as the HTTP
+// handler; the principal and tenant of the upgrade request apply to every operation.
+type SubscriptionHandler struct {
+	schema *graphql.Schema
+	// authenticator validates an "Authorization" entry of the connection_init payload,
+	// for clients such as browsers that cannot set headers on the upgrade request.
+	authenticator *Authenticator
+	upgrader      websocket.Upgrader
+
+	// InitTimeout is how long a client may take to send connection_init.
+	InitTimeout time.Duration
+	// MaxMessageBytes is the largest message a client may send; larger messages close
+	// the connection. It defaults to the body limit of HTTP requests.
+	MaxMessageBytes int64
+}
+
+// NewSubscriptionHandler returns a handler for schema. authenticator may be nil when
+// authentication is disabled. With no allowedOrigins, only same-origin browser
+// connections are accepted.
+func NewSubscriptionHandler(schema *graphql.Schema, authenticator *Authenticator, allowedOrigins []string) *SubscriptionHandler {
+	h := &SubscriptionHandler{
+		schema:          schema,
+		authenticator:   authenticator,
+		upgrader:        websocket.Upgrader{Subprotocols: []string{graphqlTransportWS}},
+		InitTimeout:     10 * time.Second,
+		MaxMessageBytes: MaxRequestBytes(DefaultMaxUploadBytes),
+	}
+	if len(allowedOrigins) > 0 {
+		h.upgrader.CheckOrigin = func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			for _, allowed := range allowedOrigins {
+				if origin == "" || origin == allowed {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return h
+}
+
+// WithSubscriptions sends WebSocket upgrade requests to ws and everything else to next.
+func WithSubscriptions(ws http.Handler, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			ws.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *SubscriptionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade has already answered the request
+	}
+	defer conn.Close()
+	conn.SetReadLimit(h.MaxMessageBytes)
+
+	c := &wsConnection{handler: h, conn: conn, ctx: r.Context(), operations: make(map[string]context.CancelFunc)}
+	if conn.Subprotocol() != graphqlTransportWS {
+		c.close(closeSubprotocolNotAcceptable, "Subprotocol not acceptable")
+		return
+	}
+	c.run(r.Header.Get(TenantHeader))
+}
+
+// wsConnection is the state of one graphql-transport-ws connection.
+type wsConnection struct {
+	handler *SubscriptionHandler
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+
+	mu           sync.Mutex
+	ctx          context.Context // carries principal and tenant once the connection is initialised
+	acknowledged bool
+	operations   map[string]context.CancelFunc
+	wg           sync.WaitGroup
+}
+
+func (c *wsConnection) send(msg wsMessage) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.conn.WriteJSON(msg); err != nil {
+		slog.DebugContext(c.ctx, "websocket write failed", "error", err)
+	}
+}
+
+func (c *wsConnection) sendPayload(id, msgType string, payload interface{}) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		slog.ErrorContext(c.ctx, "failed to encode websocket payload", "error", err)
+		return
+	}
+	c.send(wsMessage{ID: id, Type: msgType, Payload: raw})
+}
+
+func (c *wsConnection) close(code int, reason string) {
+	deadline := time.Now().Add(time.Second)
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	c.conn.Close()
+}
+
+func (c *wsConnection) run(requestedTenant string) {
+	initTimer := time.AfterFunc(c.handler.InitTimeout, func() {
+		c.mu.Lock()
+		acknowledged := c.acknowledged
+		c.mu.Unlock()
+		if !acknowledged {
+			c.close(closeInitTimeout, "Connection initialisation timeout")
+		}
+	})
+	defer initTimer.Stop()
+	defer func() {
+		// Stop every running operation and wait for it before the connection goes away.
+		c.mu.Lock()
+		for _, cancel := range c.operations {
+			cancel()
+		}
+		c.mu.Unlock()
+		c.wg.Wait()
+	}()
+
+	initReceived := false
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		received := time.Now()
+		var msg wsMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.close(closeInvalidMessage, "Invalid message received")
+			return
+		}
+		switch msg.Type {
+		case "connection_init":
+			if initReceived {
+				c.close(closeTooManyInitRequests, "Too many initialisation requests")
+				return
+			}
+			initReceived = true
+			if err := c.initialise(msg.Payload, requestedTenant); err != nil {
+				slog.WarnContext(c.ctx, "rejected websocket connection", "error", err)
+				c.close(closeForbidden, "Forbidden")
+				return
+			}
+			c.send(wsMessage{Type: "connection_ack"})
+		case "ping":
+			c.send(wsMessage{Type: "pong", Payload: msg.Payload})
+		case "pong":
+		case "subscribe":
+			c.mu.Lock()
+			acknowledged := c.acknowledged
+			_, exists := c.operations[msg.ID]
+			c.mu.Unlock()
+			if !acknowledged {
+				c.close(closeUnauthorized, "Unauthorized")
+				return
+			}
+			if msg.ID == "" {
+				c.close(closeInvalidMessage, "Invalid message received")
+				return
+			}
+			if exists {
+				c.close(closeSubscriberExists, fmt.Sprintf("Subscriber for %s already exists", msg.ID))
+				return
+			}
+			var payload wsSubscribePayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil || payload.Query == "" {
+				c.close(closeInvalidMessage, "Invalid message received")
+				return
+			}
+			c.start(msg.ID, payload, received)
+		case "complete":
+			c.mu.Lock()
+			if cancel, ok := c.operations[msg.ID]; ok {
+				cancel()
+			}
+			c.mu.Unlock()
+		default:
+			c.close(closeInvalidMessage, "Invalid message received")
+			return
+		}
+	}
+}
+
+// initialise handles connection_init. A token in the payload replaces the principal of
+// the upgrade request, and the tenant is resolved again for the new principal. An
+// Accept-Language entry selects the locale of error messages.
+func (c *wsConnection) initialise(raw json.RawMessage, requestedTenant string) error {
+	var payload map[string]interface{}
+	if len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return fmt.Errorf("invalid connection_init payload: %w", err)
+		}
+	}
+	ctx := c.ctx
+	header, _ := payloadValue(payload, "Authorization").(string)
+	if header != "" {
+		if c.handler.authenticator == nil {
+			return fmt.Errorf("token authentication is not configured")
+		}
+		raw, found := strings.CutPrefix(header, "Bearer ")
+		if !found {
+			return fmt.Errorf("authorization must use the Bearer scheme")
+		}
+		principal, err := c.handler.authenticator.Authenticate(strings.TrimSpace(raw))
+		if err != nil {
+			return err
+		}
+		ctx = WithPrincipal(ctx, principal)
+	}
+	if acceptLanguage, _ := payloadValue(payload, "Accept-Language").(string); acceptLanguage != "" {
+		ctx = WithLocale(ctx, negotiateLocale(acceptLanguage))
+	}
+	if tenant, _ := payloadValue(payload, TenantHeader).(string); tenant != "" {
+		requestedTenant = tenant
+	}
+	if header != "" || requestedTenant != "" {
+		principal, _ := PrincipalFromContext(ctx)
+		cfg, apiErr := resolveTenant(principal, requestedTenant)
+		if apiErr != nil {
+			return apiErr
+		}
+		if cfg != nil {
+			ctx = context.WithValue(ctx, tenantKey{}, cfg)
+		}
+	}
+
+	c.mu.Lock()
+	c.ctx = ctx
+	c.acknowledged = true
+	c.mu.Unlock()
+	return nil
+}
+
+// payloadValue looks up a connection_init entry case-insensitively.
+func payloadValue(payload map[string]interface{}, key string) interface{} {
+	for k, v := range payload {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+// start runs one operation. Queries and mutations yield a single result; subscriptions
+// stream results until the client sends complete or disconnects. Each operation gets its
+// own logging state, so its duration counts from the subscribe message received at
+// started rather than from the upgrade request.
+func (c *wsConnection) start(id string, payload wsSubscribePayload, started time.Time) {
+	c.mu.Lock()
+	ctx, cancel := context.WithCancel(c.ctx)
+	if info := requestInfoFromContext(ctx); info != nil {
+		ctx = context.WithValue(ctx, requestInfoKey{}, &requestInfo{ID: info.ID, Started: started, ClientIP: info.ClientIP, UserAgent: info.UserAgent})
+	}
+	c.operations[id] = cancel
+	c.wg.Add(1)
+	c.mu.Unlock()
+
+	go func() {
+		defer c.wg.Done()
+		defer func() {
+			c.mu.Lock()
+			delete(c.operations, id)
+			c.mu.Unlock()
+			cancel()
+		}()
+
+		doc, err := parser.Parse(parser.ParseParams{Source: payload.Query})
+		if err != nil {
+			c.sendPayload(id, "error", gqlerrors.FormatErrors(err))
+			return
+		}
+		if validation := graphql.ValidateDocument(c.handler.schema, doc, nil); !validation.IsValid {
+			c.sendPayload(id, "error", validation.Errors)
+			return
+		}
+
+		params := graphql.ExecuteParams{
+			Schema:        *c.handler.schema,
+			AST:           doc,
+			OperationName: payload.OperationName,
+			Args:          payload.Variables,
+			Context:       ctx,
+		}
+		if !isSubscription(doc, payload.OperationName) {
+			result := graphql.Execute(params)
+			LogResult(ctx, &graphql.Params{RequestString: payload.Query, OperationName: payload.OperationName, VariableValues: payload.Variables}, result, nil)
+			c.sendPayload(id, "next", result)
+			c.send(wsMessage{ID: id, Type: "complete"})
+			return
+		}
+
+		slog.InfoContext(ctx, "subscription started", "id", id, "fields", operationFields(payload.Query, payload.OperationName))
+		for result := range graphql.ExecuteSubscription(params) {
+			if ctx.Err() != nil {
+				continue // drain until the executor notices the cancellation
+			}
+			c.sendPayload(id, "next", withErrorCodes(result))
+		}
+		slog.InfoContext(ctx, "subscription finished", "id", id, "duration", time.Since(started))
+		if ctx.Err() == nil {
+			c.send(wsMessage{ID: id, Type: "complete"})
+		}
+	}()
+}
+
+// withErrorCodes restores the extensions of errors returned by subscribers, which
+// graphql-go formats without them.
+func withErrorCodes(result *graphql.Result) *graphql.Result {
+	for i, e := range result.Errors {
+		if e.Extensions != nil {
+			continue
+		}
+		if extended, ok := e.OriginalError().(gqlerrors.ExtendedError); ok {
+			result.Errors[i].Extensions = extended.Extensions()
+		}
+	}
+	return result
+}
+
+func isSubscription(doc *ast.Document, operationName string) bool {
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName != "" && (op.Name == nil || op.Name.Value != operationName) {
+			continue
+		}
+		return op.Operation == ast.OperationTypeSubscription
+	}
+	return false
+}
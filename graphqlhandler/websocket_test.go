@@ -0,0 +1,39 @@
+package graphqlhandler
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSubscriptionMessageLimit(t *testing.T) {
+	h := NewSubscriptionHandler(&Schema, nil, nil)
+	h.MaxMessageBytes = 1 << 10
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{graphqlTransportWS}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.WriteJSON(wsMessage{Type: "connection_init"}); err != nil {
+		t.Fatal(err)
+	}
+	var ack wsMessage
+	if err := conn.ReadJSON(&ack); err != nil || ack.Type != "connection_ack" {
+		t.Fatalf("got %+v, %v, want connection_ack", ack, err)
+	}
+
+	query := `{"query":"{ __typename }","variables":{"padding":"` + strings.Repeat("x", 2<<10) + `"}}`
+	if err := conn.WriteJSON(wsMessage{ID: "1", Type: "subscribe", Payload: []byte(query)}); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Fatalf("oversized message: got %v, want close %d", err, websocket.CloseMessageTooBig)
+	}
+}